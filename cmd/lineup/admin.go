@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/nikitkaralius/lineup/internal/chats"
+	"github.com/nikitkaralius/lineup/internal/polls"
+	"github.com/nikitkaralius/lineup/internal/queue"
+	"github.com/nikitkaralius/lineup/internal/utils"
+	"github.com/nikitkaralius/lineup/internal/voters"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/riverdriver/riverpgxv5"
+)
+
+// listLimit is how many polls `polls list` shows.
+const listLimit = 50
+
+// pollsCommand lists polls and closes them, for operators without access to the chats.
+func pollsCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("polls needs list or close")
+	}
+	switch action, args := args[0], args[1:]; action {
+	case "list":
+		status := ""
+		if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+			status, args = args[0], args[1:]
+		}
+		return listPolls(ctx, status, args)
+	case "close":
+		if len(args) == 0 || args[0] == "" || args[0][0] == '-' {
+			return errors.New("polls close needs a poll ID")
+		}
+		return closePoll(ctx, args[0], args[1:])
+	default:
+		return fmt.Errorf("unknown polls command %q, want list or close", action)
+	}
+}
+
+// queueCommand shows the lineup of a poll.
+func queueCommand(ctx context.Context, args []string) error {
+	if len(args) < 2 || args[0] != "show" || args[1] == "" || args[1][0] == '-' {
+		return errors.New("usage: lineup queue show <poll>")
+	}
+	return showQueue(ctx, args[1], args[2:])
+}
+
+func listPolls(ctx context.Context, status string, args []string) error {
+	switch status {
+	case "", polls.StatusActive, polls.StatusPublishing, polls.StatusProcessed, polls.StatusCancelled, polls.StatusDeleted:
+	default:
+		return fmt.Errorf("unknown status %q, want %s, %s, %s, %s or %s", status,
+			polls.StatusActive, polls.StatusPublishing, polls.StatusProcessed, polls.StatusCancelled, polls.StatusDeleted)
+	}
+	_, db, err := connect(ctx, "polls list", args)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	list, err := polls.NewRepository(db).ListPolls(ctx, status, listLimit)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "POLL\tCHAT\tSTATUS\tENDS AT\tTOPIC")
+	for _, p := range list {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", p.PollID, p.ChatID, p.Status, p.EndsAt.UTC().Format(time.RFC3339), p.Topic)
+	}
+	return w.Flush()
+}
+
+// closePoll moves the end of an active poll to now. The running workers then post its lineup.
+func closePoll(ctx context.Context, pollID string, args []string) error {
+	_, db, err := connect(ctx, "polls close", args)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// An insert-only client: the finish job is run by `lineup work`
+	riverClient, err := river.NewClient(riverpgxv5.New(db), &river.Config{})
+	if err != nil {
+		return fmt.Errorf("create river client: %w", err)
+	}
+	// Closing sends nothing to Telegram, so the manager gets no bot
+	manager := polls.NewManager(polls.NewRepository(db), polls.NewPollsService(riverClient), nil, nil)
+	switch err := manager.ClosePoll(ctx, pollID); {
+	case errors.Is(err, pgx.ErrNoRows):
+		return fmt.Errorf("poll %s not found", pollID)
+	case err != nil:
+		return err
+	}
+	fmt.Printf("poll %s closed, its lineup is posted once a worker runs the finish job\n", pollID)
+	return nil
+}
+
+func showQueue(ctx context.Context, pollID string, args []string) error {
+	cfg, db, err := connect(ctx, "queue show", args)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	poll, err := polls.NewRepository(db).GetPoll(ctx, pollID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("poll %s not found", pollID)
+	}
+	if err != nil {
+		return err
+	}
+	votersRepo := voters.NewRepository(db)
+	queueUserIDs, err := votersRepo.GetQueueUserIDs(ctx, pollID)
+	if err != nil {
+		return err
+	}
+	votersMap, err := votersRepo.GetVotersInfo(ctx, pollID, queueUserIDs)
+	if err != nil {
+		return err
+	}
+
+	settings := chats.NewRepository(db, cfg.ChatDefaults()).GetSettingsOrDefault(ctx, poll.ChatID, poll.MessageThreadID)
+	data := queue.NewTemplateData(settings.Language, utils.LoadLocation(settings.Timezone), poll, queueUserIDs, votersMap)
+
+	fmt.Printf("%s (%s, chat %d)\n", poll.Topic, poll.Status, poll.ChatID)
+	if data.Empty {
+		fmt.Println("the queue is empty")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tSLOT\tUSER ID\tVOTER")
+	for _, e := range data.Entries {
+		fmt.Fprintf(w, "%d\t%s\t%d\t%s\n", e.Position, e.Slot, e.UserID, e.Display)
+	}
+	return w.Flush()
+}
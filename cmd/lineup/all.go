@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/nikitkaralius/lineup/internal/config"
+)
+
+// allCommand serves and works in one process with one River client and one HTTP server.
+func allCommand(ctx context.Context, args []string) error {
+	cfg, err := loadConfig("all", args, config.Default(), (*config.Config).ValidateService)
+	if err != nil {
+		return err
+	}
+	a, err := newApp(ctx, cfg, "lineup")
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	riverClient, err := newWorkerClient(a)
+	if err != nil {
+		return err
+	}
+	stopUpdates, err := startService(ctx, a, riverClient)
+	if err != nil {
+		return err
+	}
+	a.listen()
+	// Not ctx, cancelling it would stop the client without waiting for jobs
+	if err := riverClient.Start(context.WithoutCancel(ctx)); err != nil {
+		return fmt.Errorf("start river client: %w", err)
+	}
+	slog.Info("service and worker started")
+
+	<-ctx.Done()
+	slog.Info("shutting down")
+	ctxShutdown, cancel := context.WithTimeout(context.Background(), cfg.Updates.ShutdownTimeout)
+	defer cancel()
+
+	// Updates first, their handlers insert jobs
+	a.shutdownHTTP(ctxShutdown)
+	stopUpdates(ctxShutdown)
+	stopWorkers(riverClient, cfg.Updates.ShutdownTimeout)
+	return nil
+}
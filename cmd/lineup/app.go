@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nikitkaralius/lineup/internal/chats"
+	"github.com/nikitkaralius/lineup/internal/config"
+	"github.com/nikitkaralius/lineup/internal/health"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/messenger"
+	"github.com/nikitkaralius/lineup/internal/metrics"
+	"github.com/nikitkaralius/lineup/internal/migrate"
+	"github.com/nikitkaralius/lineup/internal/polls"
+	"github.com/nikitkaralius/lineup/internal/ratelimit"
+	"github.com/nikitkaralius/lineup/internal/tracing"
+	"github.com/nikitkaralius/lineup/internal/updates"
+	"github.com/nikitkaralius/lineup/internal/voters"
+	"github.com/nikitkaralius/lineup/internal/webhooks"
+)
+
+// app is what serve, work and all share: tracing, the database, the bot, the
+// repositories and the HTTP server with probes and metrics.
+type app struct {
+	cfg *config.Config
+	db  *pgxpool.Pool
+	bot *tgbotapi.BotAPI
+	tg  messenger.Messenger
+
+	pollsRepo    polls.Repository
+	votersRepo   voters.Repository
+	chatsRepo    *chats.Repository
+	webhooksRepo *webhooks.Repository
+	updatesRepo  *updates.Repository
+	turnsRepo    *ratelimit.Repository
+
+	mux    *http.ServeMux
+	probes *health.Handler
+	srv    *http.Server
+
+	shutdownTracing func(context.Context) error
+}
+
+// newApp sets up tracing as serviceName, connects to the database, migrates it
+// if configured to, and creates the bot.
+func newApp(ctx context.Context, cfg *config.Config, serviceName string) (*app, error) {
+	a := &app{cfg: cfg, mux: http.NewServeMux(), probes: health.NewHandler()}
+
+	var err error
+	if a.shutdownTracing, err = tracing.Setup(ctx, serviceName); err != nil {
+		return nil, fmt.Errorf("set up tracing: %w", err)
+	}
+	if a.db, err = tracing.NewPool(ctx, cfg.Database.DSN); err != nil {
+		a.Close()
+		return nil, fmt.Errorf("create db pool: %w", err)
+	}
+	if cfg.Database.AutoMigrate {
+		if err := migrate.Up(ctx, a.db); err != nil {
+			a.Close()
+			return nil, fmt.Errorf("migrate database: %w", err)
+		}
+	}
+
+	if err := tgbotapi.SetLogger(logging.BotLogger{}); err != nil {
+		a.Close()
+		return nil, fmt.Errorf("set bot logger: %w", err)
+	}
+	if a.bot, err = tgbotapi.NewBotAPI(cfg.Telegram.BotToken); err != nil {
+		a.Close()
+		return nil, fmt.Errorf("create bot: %w", err)
+	}
+	a.bot.Debug = cfg.LogLevel() <= slog.LevelDebug
+	slog.Info("authorized on account", slog.String("username", a.bot.Self.UserName))
+	a.pollsRepo = polls.NewRepository(a.db)
+	a.votersRepo = voters.NewRepository(a.db)
+	a.chatsRepo = chats.NewRepository(a.db, cfg.ChatDefaults())
+	a.webhooksRepo = webhooks.NewRepository(a.db)
+	a.updatesRepo = updates.NewRepository(a.db)
+	a.turnsRepo = ratelimit.NewRepository(a.db)
+	// Everything sent goes through one outbox whose turns are shared with the other processes
+	a.tg = messenger.NewOutbox(messenger.NewTelegram(a.bot), cfg.SendLimits(), a.turnsRepo)
+
+	a.probes.Add("postgres", health.Postgres(a.db))
+	a.probes.Add("telegram", health.Cached(health.BotAPI(a.bot), 30*time.Second))
+	return a, nil
+}
+
+// listen serves probes, metrics and whatever else was registered on a.mux.
+func (a *app) listen() {
+	a.probes.Register(a.mux)
+	a.mux.Handle("GET /metrics", metrics.Handler())
+	a.srv = &http.Server{Addr: a.cfg.HTTP.Addr, Handler: a.mux, ErrorLog: logging.StdLogger(slog.LevelWarn)}
+	go func() {
+		slog.Info("listening", slog.String("addr", a.cfg.HTTP.Addr))
+		if err := a.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Fatal("http server failed", logging.Err(err))
+		}
+	}()
+}
+
+// shutdownHTTP stops the HTTP server, letting running requests finish until ctx is done.
+func (a *app) shutdownHTTP(ctx context.Context) {
+	if a.srv == nil {
+		return
+	}
+	if err := a.srv.Shutdown(ctx); err != nil {
+		slog.Error("http server shutdown failed", logging.Err(err))
+	}
+}
+
+// Close releases the database and flushes traces.
+func (a *app) Close() {
+	if a.db != nil {
+		a.db.Close()
+	}
+	if err := a.shutdownTracing(context.Background()); err != nil {
+		slog.Error("tracing shutdown failed", logging.Err(err))
+	}
+}
@@ -0,0 +1,117 @@
+// Command lineup runs the bot and administers its database.
+//
+//	lineup serve [flags]             handle Telegram updates and serve the API and dashboard
+//	lineup work [flags]              run the River workers that finish polls and deliver webhooks
+//	lineup all [flags]               serve and work in one process, for small deployments
+//	lineup migrate up|status [flags] apply River's and all pending migrations, or show them
+//	lineup migrate down [n] [flags]  revert the last n migrations, 1 by default
+//	lineup polls list [status]       show the latest polls of all chats
+//	lineup polls close <poll>        finish an active poll now
+//	lineup queue show <poll>         show the lineup of a poll
+//
+// Every command takes the configuration file, environment variables and flags described
+// in the README; flags come after the command and its arguments.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nikitkaralius/lineup/internal/config"
+	"github.com/nikitkaralius/lineup/internal/logging"
+)
+
+const usage = `usage:
+  lineup serve [flags]
+  lineup work [flags]
+  lineup all [flags]
+  lineup migrate up|status [flags]
+  lineup migrate down [n] [flags]
+  lineup polls list [status] [flags]
+  lineup polls close <poll> [flags]
+  lineup queue show <poll> [flags]
+
+Run a command with -h for its flags.
+`
+
+// commands maps command names to their implementations, which get the arguments
+// after the name.
+var commands = map[string]func(ctx context.Context, args []string) error{
+	"serve":   serveCommand,
+	"work":    workCommand,
+	"all":     allCommand,
+	"migrate": migrateCommand,
+	"polls":   pollsCommand,
+	"queue":   queueCommand,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := cmd(ctx, os.Args[2:])
+	if errors.Is(err, flag.ErrHelp) || errors.Is(err, errPrinted) {
+		return
+	}
+	if err != nil {
+		logging.Fatal(os.Args[1]+" failed", logging.Err(err))
+	}
+}
+
+// errPrinted tells a command that the configuration was printed and it should stop.
+var errPrinted = errors.New("configuration printed")
+
+// loadConfig loads the configuration from args, prints it if asked and sets up logging.
+// validate checks the settings the command needs.
+func loadConfig(name string, args []string, defaults config.Config, validate func(*config.Config) error) (*config.Config, error) {
+	cfg, err := config.Load("lineup "+name, args, defaults)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			return nil, err
+		}
+		return nil, errPrinted
+	}
+	if err := validate(cfg); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	logging.Setup(os.Stderr, cfg.LogLevel())
+	return cfg, nil
+}
+
+// validateDatabase checks the settings of commands that only use the database.
+func validateDatabase(cfg *config.Config) error {
+	if cfg.Database.DSN == "" {
+		return errors.New("database dsn is required")
+	}
+	return nil
+}
+
+// connect loads the configuration of a command that only needs the database and
+// connects to it.
+func connect(ctx context.Context, name string, args []string) (*config.Config, *pgxpool.Pool, error) {
+	cfg, err := loadConfig(name, args, config.Default(), validateDatabase)
+	if err != nil {
+		return nil, nil, err
+	}
+	pool, err := pgxpool.New(ctx, cfg.Database.DSN)
+	return cfg, pool, err
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/nikitkaralius/lineup/internal/migrate"
+)
+
+// migrateCommand applies, reverts or shows the database migrations.
+func migrateCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("migrate needs up, down or status")
+	}
+	action, args := args[0], args[1:]
+	steps := 1
+	if action == "down" && len(args) > 0 {
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			if n < 1 {
+				return fmt.Errorf("down needs a positive number of steps, got %d", n)
+			}
+			steps, args = n, args[1:]
+		}
+	}
+	if action != "up" && action != "down" && action != "status" {
+		return fmt.Errorf("unknown migrate command %q, want up, down or status", action)
+	}
+
+	_, pool, err := connect(ctx, "migrate "+action, args)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	m, err := migrate.New(pool)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "up":
+		return m.Up(ctx)
+	case "down":
+		return m.Down(ctx, steps)
+	}
+	status, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("schema version %d of %d\n", status.Version, status.Latest)
+	fmt.Printf("river version %d of %d\n", status.RiverVersion, status.RiverLatest)
+	for _, mig := range status.Pending {
+		fmt.Printf("pending %06d_%s\n", mig.Version, mig.Name)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/nikitkaralius/lineup/internal/api"
+	"github.com/nikitkaralius/lineup/internal/auth"
+	"github.com/nikitkaralius/lineup/internal/config"
+	"github.com/nikitkaralius/lineup/internal/dispatch"
+	"github.com/nikitkaralius/lineup/internal/drafts"
+	"github.com/nikitkaralius/lineup/internal/export"
+	"github.com/nikitkaralius/lineup/internal/handlers"
+	"github.com/nikitkaralius/lineup/internal/health"
+	"github.com/nikitkaralius/lineup/internal/llm"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/metrics"
+	"github.com/nikitkaralius/lineup/internal/polls"
+	"github.com/nikitkaralius/lineup/internal/queue"
+	"github.com/nikitkaralius/lineup/internal/stats"
+	"github.com/nikitkaralius/lineup/internal/tracing"
+	"github.com/nikitkaralius/lineup/internal/web"
+	"github.com/nikitkaralius/lineup/internal/webhooks"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/riverdriver/riverpgxv5"
+	"github.com/riverqueue/river/rivertype"
+)
+
+// serveCommand handles Telegram updates and serves the API and the dashboard.
+// Jobs are only inserted, `lineup work` runs them.
+func serveCommand(ctx context.Context, args []string) error {
+	cfg, err := loadConfig("serve", args, config.Default(), (*config.Config).ValidateService)
+	if err != nil {
+		return err
+	}
+	a, err := newApp(ctx, cfg, "lineup-service")
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	riverClient, err := river.NewClient(riverpgxv5.New(a.db), &river.Config{
+		Middleware: []rivertype.Middleware{&tracing.RiverMiddleware{}},
+	})
+	if err != nil {
+		return fmt.Errorf("create river client: %w", err)
+	}
+	a.probes.Add("river", health.River(riverClient, false))
+
+	stopUpdates, err := startService(ctx, a, riverClient)
+	if err != nil {
+		return err
+	}
+	a.listen()
+
+	<-ctx.Done()
+	slog.Info("shutting down")
+	ctxShutdown, cancel := context.WithTimeout(context.Background(), cfg.Updates.ShutdownTimeout)
+	defer cancel()
+
+	// Stop taking updates in, then let the accepted ones finish
+	a.shutdownHTTP(ctxShutdown)
+	stopUpdates(ctxShutdown)
+	return nil
+}
+
+// startService registers the update handlers, the API and the dashboard, and starts
+// receiving updates in the configured mode. Webhook updates only arrive once a.listen
+// is called. The returned function waits for the accepted updates after receiving
+// stopped, which is when ctx is done and the HTTP server shut down.
+func startService(ctx context.Context, a *app, riverClient *river.Client[pgx.Tx]) (stop func(context.Context), err error) {
+	cfg := a.cfg
+	me := a.bot.Self.UserName
+	draftsRepo := drafts.NewRepository(a.db)
+
+	llmClient, err := llm.NewClient(ctx, cfg.LLMConfig())
+	if err != nil {
+		return nil, fmt.Errorf("create LLM client: %w", err)
+	}
+
+	events := webhooks.NewPublisher(a.webhooksRepo, riverClient)
+	queueService := queue.NewService(a.pollsRepo, a.votersRepo, a.chatsRepo, a.tg, llmClient, events)
+	pollsManager := polls.NewManager(a.pollsRepo, polls.NewPollsService(riverClient), events, a.tg)
+	exportService := export.NewService(a.pollsRepo, a.votersRepo)
+
+	var (
+		signer         *auth.Signer
+		dashboardLinks *auth.Links
+	)
+	if cfg.Dashboard.URL != "" {
+		signer = auth.NewSigner(cfg.Dashboard.Secret)
+		dashboardLinks = auth.NewLinks(signer, cfg.Dashboard.URL)
+	}
+
+	deps := &handlers.Deps{
+		Bot:            a.tg,
+		BotUsername:    me,
+		Polls:          a.pollsRepo,
+		Drafts:         draftsRepo,
+		Chats:          a.chatsRepo,
+		Webhooks:       a.webhooksRepo,
+		PollsManager:   pollsManager,
+		LLM:            llmClient,
+		Queue:          queueService,
+		DashboardLinks: dashboardLinks,
+		Export:         exportService,
+	}
+
+	// Updates from one chat are handled in order, chats in parallel, the same in both modes.
+	// Redelivered and replayed updates are handled once: each is claimed before it is handled.
+	// Telegram already has the update confirmed by then, so one cut off by shutdown is lost.
+	// Its claim is released anyway, in case it comes again from a batch that was not confirmed.
+	dispatcher := dispatch.New(func(ctx context.Context, update dispatch.Update) {
+		ctx = logging.With(ctx, logging.UpdateID(update.UpdateID))
+		ctx, span := tracing.Start(ctx, "telegram.update", tracing.UpdateID.Int(update.UpdateID))
+		defer span.End()
+
+		first, err := a.updatesRepo.MarkProcessed(ctx, update.UpdateID)
+		if err != nil {
+			// Rather handle an update twice than lose it
+			slog.ErrorContext(ctx, "mark update processed failed", logging.Err(err))
+		} else if !first {
+			slog.InfoContext(ctx, "update skipped: already processed")
+			metrics.UpdatesProcessed.WithLabelValues(updateType(update.Update), metrics.ResultDuplicate).Inc()
+			return
+		}
+		if update.Message != nil {
+			handlers.HandleMessage(ctx, deps, update.Message, update.MessageThreadID)
+		}
+		if update.PollAnswer != nil {
+			handlers.HandlePollAnswer(ctx, a.votersRepo, a.pollsRepo, events, update.PollAnswer)
+		}
+		if update.Poll != nil {
+			handlers.HandlePoll(ctx, a.votersRepo, a.pollsRepo, update.Poll)
+		}
+
+		if ctx.Err() != nil {
+			slog.WarnContext(ctx, "update cut off by shutdown", logging.Err(ctx.Err()))
+			if err := a.updatesRepo.Release(context.WithoutCancel(ctx), update.UpdateID); err != nil {
+				slog.ErrorContext(ctx, "release update failed", logging.Err(err))
+			}
+			metrics.UpdatesProcessed.WithLabelValues(updateType(update.Update), metrics.ResultError).Inc()
+			return
+		}
+		metrics.UpdatesProcessed.WithLabelValues(updateType(update.Update), metrics.ResultOK).Inc()
+	}, cfg.Updates.Workers, cfg.Updates.QueueSize)
+
+	if cfg.API.Token != "" {
+		api.NewServer(a.pollsRepo, a.votersRepo, a.chatsRepo, pollsManager, queueService, cfg.API.Token).Register(a.mux)
+		slog.Info("JSON API enabled", slog.String("path", "/api/v1"))
+	}
+	if cfg.Dashboard.URL != "" {
+		web.NewServer(a.pollsRepo, a.votersRepo, a.chatsRepo, stats.NewRepository(a.db), export.NewRepository(a.db), signer).Register(a.mux)
+		slog.Info("dashboard enabled", slog.String("url", cfg.Dashboard.URL+"/dashboard/"))
+	}
+
+	// Closed once no more updates are received in long-polling mode
+	polling := make(chan struct{})
+
+	switch cfg.Telegram.Mode {
+	case config.ModeWebhook:
+		close(polling)
+
+		opts := dispatch.WebhookOptions{SecretToken: cfg.Telegram.WebhookSecret, ClientIPHeader: cfg.Telegram.WebhookIPHeader}
+		if opts.SecretToken == "" {
+			if opts.SecretToken, err = dispatch.NewSecretToken(); err != nil {
+				return nil, fmt.Errorf("generate webhook secret: %w", err)
+			}
+		}
+		if len(cfg.Telegram.WebhookAllowIPs) > 0 {
+			cidrs := cfg.Telegram.WebhookAllowIPs
+			if len(cidrs) == 1 && cidrs[0] == "telegram" {
+				cidrs = dispatch.TelegramNets
+			}
+			if opts.AllowedNets, err = dispatch.ParseNets(cidrs); err != nil {
+				return nil, fmt.Errorf("invalid webhook-allow-ips: %w", err)
+			}
+		}
+
+		if err := dispatch.SetWebhook(a.bot, cfg.Telegram.WebhookURL, opts.SecretToken); err != nil {
+			return nil, fmt.Errorf("set webhook: %w", err)
+		}
+		info, err := a.bot.GetWebhookInfo()
+		if err != nil {
+			slog.Warn("get webhook info failed", logging.Err(err))
+		} else {
+			slog.Info("webhook set", slog.Int("pending_updates", info.PendingUpdateCount))
+		}
+
+		a.mux.Handle("POST /telegram/webhook", dispatch.WebhookHandler(dispatcher, opts))
+	case config.ModeLongPolling:
+		if _, err := a.bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+			slog.Warn("failed to remove webhook, continuing", logging.Err(err))
+		}
+
+		const timeout = 30
+		slog.Info("started long polling", slog.Int("timeout_seconds", timeout))
+		go func() {
+			defer close(polling)
+			dispatch.LongPoll(ctx, a.bot, timeout, dispatcher)
+		}()
+	}
+
+	return func(ctx context.Context) {
+		<-polling
+		if err := dispatcher.Shutdown(ctx); err != nil {
+			slog.Error("dispatcher shutdown failed", logging.Err(err))
+		}
+	}, nil
+}
+
+// updateType returns the metrics label of the update's type.
+func updateType(update tgbotapi.Update) string {
+	switch {
+	case update.Message != nil:
+		return "message"
+	case update.PollAnswer != nil:
+		return "poll_answer"
+	case update.Poll != nil:
+		return "poll"
+	}
+	return "other"
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/nikitkaralius/lineup/internal/config"
+	"github.com/nikitkaralius/lineup/internal/health"
+	"github.com/nikitkaralius/lineup/internal/jobs"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/metrics"
+	"github.com/nikitkaralius/lineup/internal/polls"
+	"github.com/nikitkaralius/lineup/internal/tracing"
+	"github.com/nikitkaralius/lineup/internal/updates"
+	"github.com/nikitkaralius/lineup/internal/webhooks"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/riverdriver/riverpgxv5"
+	"github.com/riverqueue/river/rivertype"
+)
+
+// workCommand runs the River workers.
+func workCommand(ctx context.Context, args []string) error {
+	defaults := config.Default()
+	defaults.HTTP.Addr = ":8081" // next to serve on the same host
+	cfg, err := loadConfig("work", args, defaults, (*config.Config).Validate)
+	if err != nil {
+		return err
+	}
+	a, err := newApp(ctx, cfg, "lineup-worker")
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	riverClient, err := newWorkerClient(a)
+	if err != nil {
+		return err
+	}
+	a.listen()
+	// Not ctx, cancelling it would stop the client without waiting for jobs
+	if err := riverClient.Start(context.WithoutCancel(ctx)); err != nil {
+		return fmt.Errorf("start river client: %w", err)
+	}
+	slog.Info("worker started")
+
+	<-ctx.Done()
+	stopWorkers(riverClient, cfg.Updates.ShutdownTimeout)
+
+	ctxShutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	a.shutdownHTTP(ctxShutdown)
+	return nil
+}
+
+// newWorkerClient creates a River client running the jobs and the periodic jobs,
+// and records its jobs in the metrics.
+func newWorkerClient(a *app) (*river.Client[pgx.Tx], error) {
+	workers := river.NewWorkers()
+	river.AddWorker(workers, jobs.NewFinishPollWorker(a.pollsRepo, a.votersRepo, a.chatsRepo, webhooks.NewJobPublisher[pgx.Tx](a.webhooksRepo), a.tg))
+	river.AddWorker(workers, jobs.NewDeliverWebhookWorker(a.webhooksRepo))
+	river.AddWorker(workers, jobs.NewPruneUpdatesWorker(a.updatesRepo, a.pollsRepo, a.turnsRepo))
+	river.AddWorker(workers, jobs.NewReconcilePollsWorker(a.pollsRepo))
+
+	riverClient, err := river.NewClient(riverpgxv5.New(a.db), &river.Config{
+		Queues:     a.cfg.RiverQueues(),
+		Workers:    workers,
+		Logger:     slog.Default(),
+		Middleware: []rivertype.Middleware{&tracing.RiverMiddleware{}},
+		PeriodicJobs: []*river.PeriodicJob{
+			river.NewPeriodicJob(river.PeriodicInterval(jobs.PruneUpdatesInterval), func() (river.JobArgs, *river.InsertOpts) {
+				return updates.PruneArgs{}, nil
+			}, &river.PeriodicJobOpts{RunOnStart: true}),
+			river.NewPeriodicJob(river.PeriodicInterval(jobs.ReconcilePollsInterval), func() (river.JobArgs, *river.InsertOpts) {
+				return polls.ReconcilePollsArgs{}, nil
+			}, &river.PeriodicJobOpts{RunOnStart: true}),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create river client: %w", err)
+	}
+	a.probes.Add("river", health.River(riverClient, true))
+
+	// Subscriptions end when the client stops
+	jobEvents, _ := riverClient.Subscribe(metrics.RiverEventKinds...)
+	go metrics.RecordRiverJobs(jobEvents)
+	return riverClient, nil
+}
+
+// stopWorkers waits up to timeout for running jobs to finish, then cancels them.
+// Another SIGINT or SIGTERM cancels them right away.
+func stopWorkers(riverClient *river.Client[pgx.Tx], timeout time.Duration) {
+	slog.Info("initiating soft stop (try to wait for jobs to finish)")
+
+	softStopCtx, softStopCtxCancel := context.WithTimeout(context.Background(), timeout)
+	defer softStopCtxCancel()
+
+	sigintOrTerm := make(chan os.Signal, 1)
+	signal.Notify(sigintOrTerm, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigintOrTerm)
+	go func() {
+		select {
+		case <-sigintOrTerm:
+			slog.Warn("received SIGINT/SIGTERM again; initiating hard stop (cancel everything)")
+			softStopCtxCancel()
+		case <-softStopCtx.Done():
+		}
+	}()
+
+	err := riverClient.Stop(softStopCtx)
+	if err == nil {
+		slog.Info("soft stop succeeded")
+		return
+	}
+	if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+		slog.Error("soft stop failed", logging.Err(err))
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		slog.Warn("soft stop timeout; initiating hard stop (cancel everything)")
+	}
+
+	hardStopCtx, hardStopCtxCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer hardStopCtxCancel()
+
+	// As long as all jobs respect context cancellation, StopAndCancel will
+	// always work. However, in the case of a bug where a job blocks despite
+	// being cancelled, it may be necessary to either ignore River's stop
+	// result (what's shown here) or have a supervisor kill the process.
+	if err := riverClient.StopAndCancel(hardStopCtx); errors.Is(err, context.DeadlineExceeded) {
+		slog.Error("hard stop timeout; ignoring stop procedure and exiting unsafely")
+	} else if err != nil {
+		slog.Error("hard stop failed", logging.Err(err))
+	}
+}
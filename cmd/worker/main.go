@@ -1,127 +0,0 @@
-package main
-
-import (
-	"context"
-	"errors"
-	"flag"
-	"fmt"
-	"log"
-	"os"
-	"os/signal"
-	"syscall"
-	"time"
-
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/nikitkaralius/lineup/internal/jobs"
-	"github.com/nikitkaralius/lineup/internal/polls"
-	"github.com/nikitkaralius/lineup/internal/voters"
-	"github.com/riverqueue/river"
-	"github.com/riverqueue/river/riverdriver/riverpgxv5"
-)
-
-type config struct {
-	TelegramBotToken string
-	DatabaseDSN      string
-	LogVerbose       bool
-}
-
-func main() {
-	cfg := config{}
-	flag.StringVar(&cfg.DatabaseDSN, "dsn", "", "Postgres DB DSN (required)")
-	flag.BoolVar(&cfg.LogVerbose, "verbose", false, "Enable verbose logging (default = false)")
-	flag.Parse()
-
-	cfg.TelegramBotToken = os.Getenv("TELEGRAM_BOT_TOKEN")
-	if cfg.TelegramBotToken == "" {
-		log.Fatal("env TELEGRAM_BOT_TOKEN is required")
-	}
-
-	if cfg.DatabaseDSN == "" {
-		log.Fatal("dsn is required")
-	}
-
-	ctx := context.Background()
-
-	dbPool, err := pgxpool.New(ctx, cfg.DatabaseDSN)
-	if err != nil {
-		log.Fatal("Failed to create db pool")
-	}
-
-	pollsRepo := polls.NewRepository(dbPool)
-	votersRepo := voters.NewRepository(dbPool)
-
-	// Init Telegram bot for posting messages/results from workers
-	bot, err := tgbotapi.NewBotAPI(cfg.TelegramBotToken)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	workers := river.NewWorkers()
-	river.AddWorker(workers, jobs.NewFinishPollWorker(pollsRepo, votersRepo, bot))
-
-	riverClient, err := river.NewClient(riverpgxv5.New(dbPool), &river.Config{
-		Queues: map[string]river.QueueConfig{
-			river.QueueDefault: {MaxWorkers: 100},
-		},
-		Workers: workers,
-	})
-
-	if err != nil {
-		log.Fatal("Failed to create river client")
-	}
-
-	if err := riverClient.Start(ctx); err != nil {
-		log.Fatal("Failed to start river client")
-	}
-
-	fmt.Println("Successfully started worker")
-
-	sigintOrTerm := make(chan os.Signal, 1)
-	signal.Notify(sigintOrTerm, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		<-sigintOrTerm
-		fmt.Printf("Received SIGINT/SIGTERM; initiating soft stop (try to wait for jobs to finish)\n")
-
-		softStopCtx, softStopCtxCancel := context.WithTimeout(ctx, 10*time.Second)
-		defer softStopCtxCancel()
-
-		go func() {
-			select {
-			case <-sigintOrTerm:
-				fmt.Printf("Received SIGINT/SIGTERM again; initiating hard stop (cancel everything)\n")
-				softStopCtxCancel()
-			case <-softStopCtx.Done():
-				fmt.Printf("Soft stop timeout; initiating hard stop (cancel everything)\n")
-			}
-		}()
-
-		err := riverClient.Stop(softStopCtx)
-		if err != nil && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
-			panic(err)
-		}
-		if err == nil {
-			fmt.Printf("Soft stop succeeded\n")
-			return
-		}
-
-		hardStopCtx, hardStopCtxCancel := context.WithTimeout(ctx, 10*time.Second)
-		defer hardStopCtxCancel()
-
-		// As long as all jobs respect context cancellation, StopAndCancel will
-		// always work. However, in the case of a bug where a job blocks despite
-		// being cancelled, it may be necessary to either ignore River's stop
-		// result (what's shown here) or have a supervisor kill the process.
-		err = riverClient.StopAndCancel(hardStopCtx)
-		if err != nil && errors.Is(err, context.DeadlineExceeded) {
-			fmt.Printf("Hard stop timeout; ignoring stop procedure and exiting unsafely\n")
-		} else if err != nil {
-			panic(err)
-		}
-
-		// hard stop succeeded
-	}()
-
-	<-riverClient.Stopped()
-}
@@ -0,0 +1,531 @@
+// Package e2e runs the bot against the fake Bot API server in internal/telegramtest
+// and a real Postgres database. It is skipped unless LINEUP_TEST_DSN is set, see internal/pgtest.
+package e2e
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nikitkaralius/lineup/internal/chats"
+	"github.com/nikitkaralius/lineup/internal/handlers"
+	"github.com/nikitkaralius/lineup/internal/i18n"
+	"github.com/nikitkaralius/lineup/internal/jobs"
+	"github.com/nikitkaralius/lineup/internal/messenger"
+	"github.com/nikitkaralius/lineup/internal/pgtest"
+	"github.com/nikitkaralius/lineup/internal/polls"
+	"github.com/nikitkaralius/lineup/internal/queue"
+	"github.com/nikitkaralius/lineup/internal/telegramtest"
+	"github.com/nikitkaralius/lineup/internal/voters"
+	"github.com/nikitkaralius/lineup/internal/webhooks"
+	"github.com/riverqueue/river"
+)
+
+var (
+	chat  = tgbotapi.Chat{ID: -1001, Type: "supergroup", Title: "Lab"}
+	alice = tgbotapi.User{ID: 1, FirstName: "Alice", UserName: "alice"}
+	bob   = tgbotapi.User{ID: 2, FirstName: "Bob"}
+	carol = tgbotapi.User{ID: 3, FirstName: "Carol", UserName: "carol"}
+)
+
+// scheduled records finish jobs instead of inserting them into River.
+type scheduled struct {
+	mu   sync.Mutex
+	jobs []polls.FinishPollArgs
+}
+
+func (s *scheduled) SchedulePollFinish(ctx context.Context, args polls.FinishPollArgs, runAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, args)
+	return nil
+}
+
+type env struct {
+	api       *telegramtest.Server
+	bot       *tgbotapi.BotAPI
+	tg        messenger.Messenger
+	pollsRepo polls.Repository
+	votesRepo voters.Repository
+	chatsRepo *chats.Repository
+	events    webhooks.Publisher
+	finish    *scheduled
+	manager   *polls.Manager
+}
+
+func newEnv(t *testing.T) *env {
+	t.Helper()
+	db := pgtest.New(t)
+
+	api := telegramtest.NewServer()
+	t.Cleanup(api.Close)
+	bot, err := api.NewBot()
+	if err != nil {
+		t.Fatalf("connect to fake Bot API: %v", err)
+	}
+
+	e := &env{
+		api:       api,
+		bot:       bot,
+		tg:        messenger.NewTelegram(bot),
+		pollsRepo: polls.NewRepository(db),
+		votesRepo: voters.NewRepository(db),
+		chatsRepo: chats.NewRepository(db, chats.Defaults{}),
+		finish:    &scheduled{},
+	}
+	e.events = webhooks.NewJobPublisher[any](webhooks.NewRepository(db))
+	e.manager = polls.NewManager(e.pollsRepo, e.finish, e.events, e.tg)
+	return e
+}
+
+// deliverUpdates long-polls the fake server like the bot does and handles poll answers and poll updates.
+func (e *env) deliverUpdates(t *testing.T, ctx context.Context, want int) {
+	t.Helper()
+	offset := 0
+	for handled := 0; handled < want; {
+		updates, err := e.bot.GetUpdates(tgbotapi.UpdateConfig{Offset: offset, Timeout: 1})
+		if err != nil {
+			t.Fatalf("getUpdates: %v", err)
+		}
+		if len(updates) == 0 {
+			t.Fatalf("got %d of %d updates", handled, want)
+		}
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if u.PollAnswer != nil {
+				handlers.HandlePollAnswer(ctx, e.votesRepo, e.pollsRepo, e.events, u.PollAnswer)
+				handled++
+			}
+			if u.Poll != nil {
+				handlers.HandlePoll(ctx, e.votesRepo, e.pollsRepo, u.Poll)
+				handled++
+			}
+		}
+	}
+}
+
+func TestPollLineup(t *testing.T) {
+	e := newEnv(t)
+	ctx := context.Background()
+
+	p := &polls.TelegramPollDTO{
+		ChatID:            chat.ID,
+		Topic:             "Lab 1",
+		CreatorID:         alice.ID,
+		Duration:          time.Hour,
+		EndsAt:            time.Now().UTC().Add(time.Hour),
+		Answers:           []string{"Coming", "Not coming"},
+		ComingAnswerIndex: 0,
+	}
+	if err := e.manager.CreatePoll(ctx, p); err != nil {
+		t.Fatalf("CreatePoll: %v", err)
+	}
+	if _, ok := e.api.Poll(p.PollID); !ok {
+		t.Fatalf("poll %s was not sent to Telegram", p.PollID)
+	}
+	if len(e.finish.jobs) != 1 {
+		t.Fatalf("scheduled %d finish jobs, want 1", len(e.finish.jobs))
+	}
+
+	// Alice and Carol are coming, Bob is not; Carol changes her mind twice
+	answers := []struct {
+		user    tgbotapi.User
+		options []int
+	}{
+		{alice, []int{0}},
+		{bob, []int{1}},
+		{carol, []int{1}},
+		{carol, []int{0}},
+	}
+	for _, a := range answers {
+		if _, err := e.api.AnswerPoll(p.PollID, a.user, a.options...); err != nil {
+			t.Fatalf("AnswerPoll: %v", err)
+		}
+	}
+	e.deliverUpdates(t, ctx, len(answers))
+
+	// Finish the poll now, as the job scheduled by ClosePoll would
+	if err := e.manager.ClosePoll(ctx, p.PollID); err != nil {
+		t.Fatalf("ClosePoll: %v", err)
+	}
+	worker := jobs.NewFinishPollWorker(e.pollsRepo, e.votesRepo, e.chatsRepo, e.events, e.tg)
+	job := &river.Job[polls.FinishPollArgs]{Args: e.finish.jobs[len(e.finish.jobs)-1]}
+	if err := worker.Work(ctx, job); err != nil {
+		t.Fatalf("finish poll: %v", err)
+	}
+
+	if stopped, _ := e.api.Poll(p.PollID); !stopped.IsClosed {
+		t.Error("poll was not stopped in Telegram")
+	}
+
+	info, err := e.pollsRepo.GetPollInfoForQueue(ctx, p.PollID)
+	if err != nil {
+		t.Fatalf("GetPollInfoForQueue: %v", err)
+	}
+	if info.Status != polls.StatusProcessed {
+		t.Errorf("status = %q, want %q", info.Status, polls.StatusProcessed)
+	}
+
+	var lineup *tgbotapi.Message
+	for _, m := range e.api.Messages(chat.ID) {
+		if m.MessageID == info.ResultsMessageID {
+			lineup = &m
+		}
+	}
+	if lineup == nil {
+		t.Fatalf("results message %d was not sent", info.ResultsMessageID)
+	}
+
+	queue, err := e.votesRepo.GetQueueUserIDs(ctx, p.PollID)
+	if err != nil {
+		t.Fatalf("GetQueueUserIDs: %v", err)
+	}
+	if len(queue) != 2 {
+		t.Fatalf("queue = %v, want Alice and Carol", queue)
+	}
+
+	// The lineup is shuffled: check it lists the stored queue in order and leaves Bob out
+	mentions := map[int64]string{alice.ID: "@alice (Alice)", carol.ID: "@carol (Carol)"}
+	want := strings.Builder{}
+	for i, id := range queue {
+		fmt.Fprintf(&want, "%d. %s\n", i+1, mentions[id])
+	}
+	if !strings.Contains(lineup.Text, want.String()) || strings.Contains(lineup.Text, "Bob") {
+		t.Errorf("lineup = %q, want queue %q", lineup.Text, want.String())
+	}
+}
+
+func TestPollLineupInTopic(t *testing.T) {
+	e := newEnv(t)
+	ctx := context.Background()
+
+	p := &polls.TelegramPollDTO{
+		ChatID:          chat.ID,
+		MessageThreadID: 5,
+		Topic:           "Lab 2",
+		CreatorID:       alice.ID,
+		Duration:        time.Hour,
+		EndsAt:          time.Now().UTC().Add(time.Hour),
+	}
+	if err := e.manager.CreatePoll(ctx, p); err != nil {
+		t.Fatalf("CreatePoll: %v", err)
+	}
+	if got := e.api.Thread(chat.ID, p.MessageID); got != 5 {
+		t.Errorf("poll thread = %d, want 5", got)
+	}
+
+	if err := e.manager.ClosePoll(ctx, p.PollID); err != nil {
+		t.Fatalf("ClosePoll: %v", err)
+	}
+	worker := jobs.NewFinishPollWorker(e.pollsRepo, e.votesRepo, e.chatsRepo, e.events, e.tg)
+	job := &river.Job[polls.FinishPollArgs]{Args: e.finish.jobs[len(e.finish.jobs)-1]}
+	if err := worker.Work(ctx, job); err != nil {
+		t.Fatalf("finish poll: %v", err)
+	}
+	info, err := e.pollsRepo.GetPollInfoForQueue(ctx, p.PollID)
+	if err != nil {
+		t.Fatalf("GetPollInfoForQueue: %v", err)
+	}
+	if got := e.api.Thread(chat.ID, info.ResultsMessageID); got != 5 {
+		t.Errorf("lineup thread = %d, want 5", got)
+	}
+}
+
+func TestTopicSettings(t *testing.T) {
+	e := newEnv(t)
+	ctx := context.Background()
+
+	if err := e.chatsRepo.SetTimezone(ctx, chat.ID, 0, "Asia/Tokyo"); err != nil {
+		t.Fatalf("SetTimezone: %v", err)
+	}
+	if err := e.chatsRepo.SetLanguage(ctx, chat.ID, 5, i18n.English); err != nil {
+		t.Fatalf("SetLanguage: %v", err)
+	}
+
+	// The topic keeps its language and falls back to the chat's timezone
+	topic, err := e.chatsRepo.GetSettings(ctx, chat.ID, 5)
+	if err != nil {
+		t.Fatalf("GetSettings(topic): %v", err)
+	}
+	if topic.Language != i18n.English || topic.Timezone != "Asia/Tokyo" {
+		t.Errorf("topic settings = %s, %s, want en, Asia/Tokyo", topic.Language, topic.Timezone)
+	}
+	general, err := e.chatsRepo.GetSettings(ctx, chat.ID, 0)
+	if err != nil {
+		t.Fatalf("GetSettings(chat): %v", err)
+	}
+	if general.Language != i18n.Default || general.Timezone != "Asia/Tokyo" {
+		t.Errorf("chat settings = %s, %s, want %s, Asia/Tokyo", general.Language, general.Timezone, i18n.Default)
+	}
+	other, err := e.chatsRepo.GetSettings(ctx, chat.ID, 6)
+	if err != nil {
+		t.Fatalf("GetSettings(other topic): %v", err)
+	}
+	if other.Language != i18n.Default {
+		t.Errorf("other topic language = %s, want %s", other.Language, i18n.Default)
+	}
+}
+
+// handleMessages long-polls the fake server and handles want messages like the service does.
+func (e *env) handleMessages(t *testing.T, ctx context.Context, want int) {
+	t.Helper()
+	updates, err := e.bot.GetUpdates(tgbotapi.UpdateConfig{Timeout: 1})
+	if err != nil {
+		t.Fatalf("getUpdates: %v", err)
+	}
+	if len(updates) != want {
+		t.Fatalf("got %d updates, want %d", len(updates), want)
+	}
+	deps := &handlers.Deps{Bot: e.tg, BotUsername: telegramtest.Bot.UserName, Polls: e.pollsRepo, Chats: e.chatsRepo, PollsManager: e.manager}
+	for _, u := range updates {
+		handlers.HandleMessage(ctx, deps, u.Message, 0)
+	}
+}
+
+func TestSettingsAdminOnly(t *testing.T) {
+	e := newEnv(t)
+	ctx := context.Background()
+	e.api.SetAdmin(chat.ID, alice.ID)
+	before, err := e.chatsRepo.GetSettings(ctx, chat.ID, 0)
+	if err != nil {
+		t.Fatalf("GetSettings: %v", err)
+	}
+
+	for _, m := range []struct {
+		from tgbotapi.User
+		text string
+	}{
+		{bob, "/timezone Europe/London"},
+		{bob, "/language en"},
+		{bob, "/template compact"},
+		{alice, "/timezone Asia/Tokyo"},
+		{bob, "/timezone"},
+	} {
+		if _, err := e.api.SendText(chat, m.from, m.text); err != nil {
+			t.Fatalf("SendText: %v", err)
+		}
+	}
+	e.handleMessages(t, ctx, 5)
+
+	settings, err := e.chatsRepo.GetSettings(ctx, chat.ID, 0)
+	if err != nil {
+		t.Fatalf("GetSettings: %v", err)
+	}
+	if settings.Timezone != "Asia/Tokyo" || settings.Language != before.Language || settings.TemplateName != before.TemplateName {
+		t.Errorf("settings = %s, %s, %q, want only the admin's timezone changed", settings.Timezone, settings.Language, settings.TemplateName)
+	}
+
+	replies := e.api.Messages(chat.ID)
+	if len(replies) != 5 {
+		t.Fatalf("got %d replies, want 5", len(replies))
+	}
+	for i, key := range []i18n.Key{i18n.TimezoneAdminOnly, i18n.LanguageAdminOnly, i18n.TemplateAdminOnly} {
+		if want := i18n.T(i18n.Default, key); replies[i].Text != want {
+			t.Errorf("reply %d = %q, want %q", i, replies[i].Text, want)
+		}
+	}
+	// Anyone may look at the settings
+	if !strings.Contains(replies[4].Text, "Asia/Tokyo") {
+		t.Errorf("reply to /timezone = %q, want the timezone", replies[4].Text)
+	}
+}
+
+// newPoll creates an active poll with coming and not coming answers.
+func (e *env) newPoll(t *testing.T, ctx context.Context, topic string) *polls.TelegramPollDTO {
+	t.Helper()
+	p := &polls.TelegramPollDTO{
+		ChatID:    chat.ID,
+		Topic:     topic,
+		CreatorID: alice.ID,
+		Duration:  time.Hour,
+		EndsAt:    time.Now().UTC().Add(time.Hour),
+		Answers:   []string{"Coming", "Not coming"},
+	}
+	if err := e.manager.CreatePoll(ctx, p); err != nil {
+		t.Fatalf("CreatePoll: %v", err)
+	}
+	return p
+}
+
+func TestRetractedVote(t *testing.T) {
+	e := newEnv(t)
+	ctx := context.Background()
+	p := e.newPoll(t, ctx, "Lab 3")
+
+	e.api.AnswerPoll(p.PollID, alice, 0)
+	e.api.AnswerPoll(p.PollID, bob, 0)
+	e.api.AnswerPoll(p.PollID, bob)
+	e.api.PushPoll(p.PollID)
+	e.deliverUpdates(t, ctx, 4)
+
+	votes, err := e.votesRepo.GetVotes(ctx, p.PollID)
+	if err != nil {
+		t.Fatalf("GetVotes: %v", err)
+	}
+	if len(votes) != 1 || votes[0].UserID != alice.ID {
+		t.Errorf("votes = %+v, want Alice's only", votes)
+	}
+	got, err := e.pollsRepo.GetPoll(ctx, p.PollID)
+	if err != nil {
+		t.Fatalf("GetPoll: %v", err)
+	}
+	if want := []int{1, 0}; !slices.Equal(got.VoterCounts, want) {
+		t.Errorf("VoterCounts = %v, want %v", got.VoterCounts, want)
+	}
+}
+
+func TestDeletedPoll(t *testing.T) {
+	e := newEnv(t)
+	ctx := context.Background()
+	p := e.newPoll(t, ctx, "Lab 4")
+
+	e.api.DeleteMessage(chat.ID, p.MessageID)
+	if err := e.manager.ClosePoll(ctx, p.PollID); err != nil {
+		t.Fatalf("ClosePoll: %v", err)
+	}
+	worker := jobs.NewFinishPollWorker(e.pollsRepo, e.votesRepo, e.chatsRepo, e.events, e.tg)
+	job := &river.Job[polls.FinishPollArgs]{Args: e.finish.jobs[len(e.finish.jobs)-1]}
+	if err := worker.Work(ctx, job); err != nil {
+		t.Fatalf("finish poll: %v", err)
+	}
+
+	got, err := e.pollsRepo.GetPoll(ctx, p.PollID)
+	if err != nil {
+		t.Fatalf("GetPoll: %v", err)
+	}
+	if got.Status != polls.StatusDeleted {
+		t.Errorf("status = %q, want %q", got.Status, polls.StatusDeleted)
+	}
+	if msgs := e.api.Messages(chat.ID); len(msgs) != 0 {
+		t.Errorf("sent %d messages after the poll was deleted, want none", len(msgs))
+	}
+}
+
+func TestFinishRetry(t *testing.T) {
+	e := newEnv(t)
+	ctx := context.Background()
+	p := e.newPoll(t, ctx, "Lab 5")
+
+	e.api.AnswerPoll(p.PollID, alice, 0)
+	e.api.AnswerPoll(p.PollID, carol, 0)
+	e.deliverUpdates(t, ctx, 2)
+	if err := e.manager.ClosePoll(ctx, p.PollID); err != nil {
+		t.Fatalf("ClosePoll: %v", err)
+	}
+	worker := jobs.NewFinishPollWorker(e.pollsRepo, e.votesRepo, e.chatsRepo, e.events, e.tg)
+	job := &river.Job[polls.FinishPollArgs]{Args: e.finish.jobs[len(e.finish.jobs)-1]}
+
+	// Telegram fails to send the lineup: the queue is kept for the retry
+	e.api.FailNext("sendMessage", 500, "Internal Server Error")
+	if err := worker.Work(ctx, job); err == nil {
+		t.Fatal("finish poll succeeded, want the send error")
+	}
+	if got, _ := e.pollsRepo.GetPoll(ctx, p.PollID); got.Status != polls.StatusPublishing {
+		t.Errorf("status after the failed attempt = %q, want %q", got.Status, polls.StatusPublishing)
+	}
+	stored, err := e.votesRepo.GetQueueUserIDs(ctx, p.PollID)
+	if err != nil || len(stored) != 2 {
+		t.Fatalf("stored queue = %v, %v, want Alice and Carol", stored, err)
+	}
+
+	// The retry publishes the stored queue once, later runs do nothing
+	for range 2 {
+		if err := worker.Work(ctx, job); err != nil {
+			t.Fatalf("finish poll retry: %v", err)
+		}
+	}
+	info, err := e.pollsRepo.GetPollInfoForQueue(ctx, p.PollID)
+	if err != nil {
+		t.Fatalf("GetPollInfoForQueue: %v", err)
+	}
+	if info.Status != polls.StatusProcessed {
+		t.Errorf("status = %q, want %q", info.Status, polls.StatusProcessed)
+	}
+	if msgs := e.api.Messages(chat.ID); len(msgs) != 1 || msgs[0].MessageID != info.ResultsMessageID {
+		t.Fatalf("sent %+v, want the lineup once", msgs)
+	}
+	if queue, _ := e.votesRepo.GetQueueUserIDs(ctx, p.PollID); !slices.Equal(queue, stored) {
+		t.Errorf("queue = %v, want the one stored by the first attempt %v", queue, stored)
+	}
+}
+
+func TestFinishRejectedTemplate(t *testing.T) {
+	e := newEnv(t)
+	ctx := context.Background()
+	p := e.newPoll(t, ctx, "Lab 6")
+
+	e.api.AnswerPoll(p.PollID, alice, 0)
+	e.deliverUpdates(t, ctx, 1)
+	if err := e.manager.ClosePoll(ctx, p.PollID); err != nil {
+		t.Fatalf("ClosePoll: %v", err)
+	}
+	if err := e.chatsRepo.SetTemplate(ctx, chat.ID, 0, queue.CustomTemplate, `<div>{{.Topic}}</div>`, messenger.ModeHTML); err != nil {
+		t.Fatalf("SetTemplate: %v", err)
+	}
+	worker := jobs.NewFinishPollWorker(e.pollsRepo, e.votesRepo, e.chatsRepo, e.events, e.tg)
+	job := &river.Job[polls.FinishPollArgs]{Args: e.finish.jobs[len(e.finish.jobs)-1]}
+
+	// Telegram rejects the custom template: the lineup goes out with the default one
+	e.api.FailNext("sendMessage", 400, `Bad Request: can't parse entities: Unsupported start tag "div" at byte offset 0`)
+	if err := worker.Work(ctx, job); err != nil {
+		t.Fatalf("finish poll: %v", err)
+	}
+	if got, _ := e.pollsRepo.GetPoll(ctx, p.PollID); got.Status != polls.StatusProcessed {
+		t.Errorf("status = %q, want %q", got.Status, polls.StatusProcessed)
+	}
+	msgs := e.api.Messages(chat.ID)
+	if len(msgs) != 1 || strings.Contains(msgs[0].Text, "<div>") || !strings.Contains(msgs[0].Text, "@alice (Alice)") {
+		t.Errorf("sent %+v, want the lineup in the default template", msgs)
+	}
+}
+
+func TestQueueChanges(t *testing.T) {
+	e := newEnv(t)
+	ctx := context.Background()
+	p := e.newPoll(t, ctx, "Lab 7")
+	queueService := queue.NewService(e.pollsRepo, e.votesRepo, e.chatsRepo, e.tg, nil, e.events)
+
+	e.api.AnswerPoll(p.PollID, alice, 0)
+	e.deliverUpdates(t, ctx, 1)
+	if err := e.manager.ClosePoll(ctx, p.PollID); err != nil {
+		t.Fatalf("ClosePoll: %v", err)
+	}
+	worker := jobs.NewFinishPollWorker(e.pollsRepo, e.votesRepo, e.chatsRepo, e.events, e.tg)
+	job := &river.Job[polls.FinishPollArgs]{Args: e.finish.jobs[len(e.finish.jobs)-1]}
+
+	// While the lineup is still publishing the queue is refused before anything is changed
+	e.api.FailNext("sendMessage", 500, "Internal Server Error")
+	if err := worker.Work(ctx, job); err == nil {
+		t.Fatal("finish poll succeeded, want the send error")
+	}
+	if err := queueService.JoinQueue(ctx, p.PollID, bob.ID); !errors.Is(err, queue.ErrNotPublished) {
+		t.Errorf("JoinQueue while publishing error = %v, want ErrNotPublished", err)
+	}
+	if got, _ := e.votesRepo.GetQueueUserIDs(ctx, p.PollID); !slices.Equal(got, []int64{alice.ID}) {
+		t.Errorf("queue = %v, want Alice's only", got)
+	}
+
+	// Once published, concurrent joins all get in
+	if err := worker.Work(ctx, job); err != nil {
+		t.Fatalf("finish poll retry: %v", err)
+	}
+	var wg sync.WaitGroup
+	for _, u := range []tgbotapi.User{bob, carol} {
+		wg.Go(func() {
+			if err := queueService.JoinQueue(ctx, p.PollID, u.ID); err != nil {
+				t.Errorf("JoinQueue(%s): %v", u.FirstName, err)
+			}
+		})
+	}
+	wg.Wait()
+	got, _ := e.votesRepo.GetQueueUserIDs(ctx, p.PollID)
+	if len(got) != 3 || got[0] != alice.ID {
+		t.Errorf("queue = %v, want Alice followed by Bob and Carol", got)
+	}
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"time"
+
+	"github.com/nikitkaralius/lineup/internal/polls"
+	"github.com/nikitkaralius/lineup/internal/voters"
+)
+
+type pollResponse struct {
+	PollID            string     `json:"poll_id"`
+	ChatID            int64      `json:"chat_id"`
+	MessageThreadID   int        `json:"message_thread_id,omitempty"`
+	MessageID         int        `json:"message_id"`
+	Topic             string     `json:"topic"`
+	Status            string     `json:"status"`
+	CreatorID         int64      `json:"creator_id"`
+	CreatorUsername   string     `json:"creator_username,omitempty"`
+	CreatorName       string     `json:"creator_name,omitempty"`
+	StartedAt         time.Time  `json:"started_at"`
+	EndsAt            time.Time  `json:"ends_at"`
+	Answers           []string   `json:"answers"`
+	ComingAnswerIndex int        `json:"coming_answer_index"`
+	ResultsMessageID  int        `json:"results_message_id,omitempty"`
+	SessionStartAt    *time.Time `json:"session_start_at,omitempty"`
+	SlotSeconds       int        `json:"slot_seconds,omitempty"`
+	VoterCounts       []int      `json:"voter_counts,omitempty"`
+}
+
+type voteResponse struct {
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	OptionIDs []int     `json:"option_ids"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type queueEntryResponse struct {
+	Position  int        `json:"position"`
+	UserID    int64      `json:"user_id"`
+	Username  string     `json:"username,omitempty"`
+	Name      string     `json:"name,omitempty"`
+	SlotStart *time.Time `json:"slot_start,omitempty"`
+}
+
+type pollDetailsResponse struct {
+	Poll  pollResponse         `json:"poll"`
+	Votes []voteResponse       `json:"votes"`
+	Queue []queueEntryResponse `json:"queue"`
+}
+
+type createPollRequest struct {
+	ChatID            int64      `json:"chat_id"`
+	MessageThreadID   int        `json:"message_thread_id,omitempty"`
+	Topic             string     `json:"topic"`
+	Duration          string     `json:"duration,omitempty"`
+	EndsAt            *time.Time `json:"ends_at,omitempty"`
+	Answers           []string   `json:"answers,omitempty"`
+	ComingAnswerIndex int        `json:"coming_answer_index"`
+	SessionStartAt    *time.Time `json:"session_start_at,omitempty"`
+	SlotDuration      string     `json:"slot_duration,omitempty"`
+	CreatorID         int64      `json:"creator_id,omitempty"`
+	CreatorName       string     `json:"creator_name,omitempty"`
+}
+
+type extendPollRequest struct {
+	Duration string     `json:"duration,omitempty"`
+	EndsAt   *time.Time `json:"ends_at,omitempty"`
+}
+
+type joinQueueRequest struct {
+	UserID int64 `json:"user_id"`
+}
+
+type reorderQueueRequest struct {
+	UserIDs []int64 `json:"user_ids"`
+}
+
+func newPollResponse(p *polls.TelegramPollDTO) pollResponse {
+	res := pollResponse{
+		PollID:            p.PollID,
+		ChatID:            p.ChatID,
+		MessageThreadID:   p.MessageThreadID,
+		MessageID:         p.MessageID,
+		Topic:             p.Topic,
+		Status:            p.Status,
+		CreatorID:         p.CreatorID,
+		CreatorUsername:   p.CreatorUsername,
+		CreatorName:       p.CreatorName,
+		StartedAt:         p.StartedAt,
+		EndsAt:            p.EndsAt,
+		Answers:           p.Answers,
+		ComingAnswerIndex: p.ComingAnswerIndex,
+		ResultsMessageID:  p.ResultsMessageID,
+		SlotSeconds:       int(p.SlotDuration / time.Second),
+		VoterCounts:       p.VoterCounts,
+	}
+	if !p.SessionStartAt.IsZero() {
+		res.SessionStartAt = &p.SessionStartAt
+	}
+	return res
+}
+
+func newVoteResponses(vs []voters.VoteDTO) []voteResponse {
+	res := make([]voteResponse, len(vs))
+	for i, v := range vs {
+		res[i] = voteResponse{UserID: v.UserID, Username: v.Username, Name: v.Name, OptionIDs: v.OptionIDs, UpdatedAt: v.UpdatedAt}
+	}
+	return res
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikitkaralius/lineup/internal/polls"
+	"github.com/nikitkaralius/lineup/internal/utils"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+func (s *Server) listPolls(w http.ResponseWriter, r *http.Request) {
+	chatID, err := strconv.ParseInt(r.PathValue("chatID"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid chat id")
+		return
+	}
+	limit, err := queryInt(r, "limit", defaultPageSize)
+	if err != nil || limit < 1 || limit > maxPageSize {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxPageSize))
+		return
+	}
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil || offset < 0 {
+		writeError(w, http.StatusBadRequest, "offset must be a non-negative integer")
+		return
+	}
+
+	ps, err := s.pollsRepo.ListPollsByChat(r.Context(), chatID, limit, offset)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	res := make([]pollResponse, len(ps))
+	for i := range ps {
+		res[i] = newPollResponse(&ps[i])
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) getPoll(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	p, err := s.pollsRepo.GetPoll(ctx, r.PathValue("pollID"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	vs, err := s.votersRepo.GetVotes(ctx, p.PollID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	q, err := s.queueEntries(r, p)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, pollDetailsResponse{Poll: newPollResponse(p), Votes: newVoteResponses(vs), Queue: q})
+}
+
+func (s *Server) createPoll(w http.ResponseWriter, r *http.Request) {
+	var req createPollRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	req.Topic = strings.TrimSpace(req.Topic)
+	if req.ChatID == 0 || req.Topic == "" {
+		writeError(w, http.StatusBadRequest, "chat_id and topic are required")
+		return
+	}
+	endsAt, err := parseEndsAt(req.Duration, req.EndsAt)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(req.Answers) == 1 || len(req.Answers) > 10 {
+		writeError(w, http.StatusBadRequest, "a poll needs between 2 and 10 answers")
+		return
+	}
+	if len(req.Answers) > 0 && (req.ComingAnswerIndex < 0 || req.ComingAnswerIndex >= len(req.Answers)) {
+		writeError(w, http.StatusBadRequest, "coming_answer_index is out of range")
+		return
+	}
+
+	var (
+		sessionStartAt time.Time
+		slot           time.Duration
+	)
+	if req.SessionStartAt != nil || req.SlotDuration != "" {
+		if req.SessionStartAt == nil || req.SlotDuration == "" {
+			writeError(w, http.StatusBadRequest, "session_start_at and slot_duration must be given together")
+			return
+		}
+		slot, err = time.ParseDuration(req.SlotDuration)
+		if err != nil || slot < time.Minute {
+			writeError(w, http.StatusBadRequest, "slot_duration must be a duration of at least 1m")
+			return
+		}
+		sessionStartAt = req.SessionStartAt.UTC()
+	}
+
+	settings := s.chatsRepo.GetSettingsOrDefault(r.Context(), req.ChatID, req.MessageThreadID)
+	answers := req.Answers
+	if len(answers) == 0 {
+		answers, req.ComingAnswerIndex = polls.ChatPollAnswers(settings)
+	}
+	creatorName := req.CreatorName
+	if creatorName == "" {
+		creatorName = "API"
+	}
+
+	p := &polls.TelegramPollDTO{
+		ChatID:            req.ChatID,
+		MessageThreadID:   req.MessageThreadID,
+		Topic:             polls.FormatTopic(settings.Language, utils.LoadLocation(settings.Timezone), req.Topic, endsAt, sessionStartAt, slot),
+		CreatorID:         req.CreatorID,
+		CreatorName:       creatorName,
+		Duration:          time.Until(endsAt).Round(time.Second),
+		EndsAt:            endsAt,
+		Answers:           answers,
+		ComingAnswerIndex: req.ComingAnswerIndex,
+		SessionStartAt:    sessionStartAt,
+		SlotDuration:      slot,
+	}
+	if err := s.pollsManager.CreatePoll(r.Context(), p); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, newPollResponse(p))
+}
+
+func (s *Server) closePoll(w http.ResponseWriter, r *http.Request) {
+	s.respondWithPoll(w, r, s.pollsManager.ClosePoll(r.Context(), r.PathValue("pollID")))
+}
+
+func (s *Server) extendPoll(w http.ResponseWriter, r *http.Request) {
+	var req extendPollRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	endsAt, err := parseEndsAt(req.Duration, req.EndsAt)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.respondWithPoll(w, r, s.pollsManager.ExtendPoll(r.Context(), r.PathValue("pollID"), endsAt))
+}
+
+func (s *Server) cancelPoll(w http.ResponseWriter, r *http.Request) {
+	s.respondWithPoll(w, r, s.pollsManager.CancelPoll(r.Context(), r.PathValue("pollID")))
+}
+
+// respondWithPoll reports err or, if the operation succeeded, the updated poll.
+func (s *Server) respondWithPoll(w http.ResponseWriter, r *http.Request, err error) {
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	p, err := s.pollsRepo.GetPoll(r.Context(), r.PathValue("pollID"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newPollResponse(p))
+}
+
+// parseEndsAt resolves either a Go duration from now or an absolute end time in the future.
+func parseEndsAt(duration string, endsAt *time.Time) (time.Time, error) {
+	switch {
+	case duration != "" && endsAt != nil:
+		return time.Time{}, fmt.Errorf("give either duration or ends_at, not both")
+	case duration != "":
+		d, err := time.ParseDuration(duration)
+		if err != nil || d <= 0 {
+			return time.Time{}, fmt.Errorf("duration must be a positive Go duration, e.g. 30m")
+		}
+		return time.Now().UTC().Add(d), nil
+	case endsAt != nil:
+		if !endsAt.After(time.Now()) {
+			return time.Time{}, fmt.Errorf("ends_at must be in the future")
+		}
+		return endsAt.UTC(), nil
+	default:
+		return time.Time{}, fmt.Errorf("duration or ends_at is required")
+	}
+}
+
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	return strconv.Atoi(v)
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/nikitkaralius/lineup/internal/polls"
+	"github.com/nikitkaralius/lineup/internal/queue"
+)
+
+func (s *Server) getQueue(w http.ResponseWriter, r *http.Request) {
+	p, err := s.pollsRepo.GetPollInfoForQueue(r.Context(), r.PathValue("pollID"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	q, err := s.queueEntries(r, p)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, q)
+}
+
+func (s *Server) joinQueue(w http.ResponseWriter, r *http.Request) {
+	var req joinQueueRequest
+	if err := decodeJSON(r, &req); err != nil || req.UserID == 0 {
+		writeError(w, http.StatusBadRequest, "user_id is required")
+		return
+	}
+	s.respondWithQueue(w, r, s.queueService.JoinQueue(r.Context(), r.PathValue("pollID"), req.UserID))
+}
+
+func (s *Server) leaveQueue(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(r.PathValue("userID"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+	s.respondWithQueue(w, r, s.queueService.LeaveQueue(r.Context(), r.PathValue("pollID"), userID))
+}
+
+func (s *Server) reorderQueue(w http.ResponseWriter, r *http.Request) {
+	var req reorderQueueRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	s.respondWithQueue(w, r, s.queueService.ReorderQueue(r.Context(), r.PathValue("pollID"), req.UserIDs))
+}
+
+// respondWithQueue reports err or, if the operation succeeded, the updated queue.
+func (s *Server) respondWithQueue(w http.ResponseWriter, r *http.Request, err error) {
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	s.getQueue(w, r)
+}
+
+// queueEntries returns the poll's queue with voter details and estimated time slots.
+// Polls without a published lineup have an empty queue.
+func (s *Server) queueEntries(r *http.Request, p *polls.TelegramPollDTO) ([]queueEntryResponse, error) {
+	ctx := r.Context()
+	userIDs, err := s.queueService.GetQueue(ctx, p.PollID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return []queueEntryResponse{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	votersMap, err := s.votersRepo.GetVotersInfo(ctx, p.PollID, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var slots []time.Time
+	if !p.SessionStartAt.IsZero() && p.SlotDuration > 0 {
+		slots = queue.ComputeSlots(p.SessionStartAt, p.SlotDuration, len(userIDs))
+	}
+
+	res := make([]queueEntryResponse, len(userIDs))
+	for i, userID := range userIDs {
+		v := votersMap[userID]
+		res[i] = queueEntryResponse{Position: i + 1, UserID: userID, Username: v.Username, Name: v.Name}
+		if slots != nil {
+			res[i].SlotStart = &slots[i]
+		}
+	}
+	return res, nil
+}
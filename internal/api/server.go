@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/nikitkaralius/lineup/internal/chats"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/polls"
+	"github.com/nikitkaralius/lineup/internal/queue"
+	"github.com/nikitkaralius/lineup/internal/voters"
+)
+
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// maxBodySize limits request bodies accepted by the API.
+const maxBodySize = 1 << 20
+
+// Server is the authenticated JSON API for polls, votes and queues.
+type Server struct {
+	pollsRepo    polls.Repository
+	votersRepo   voters.Repository
+	chatsRepo    *chats.Repository
+	pollsManager *polls.Manager
+	queueService *queue.Service
+	token        string
+}
+
+// NewServer creates the API server. Requests must carry "Authorization: Bearer <token>".
+func NewServer(
+	pollsRepo polls.Repository,
+	votersRepo voters.Repository,
+	chatsRepo *chats.Repository,
+	pollsManager *polls.Manager,
+	queueService *queue.Service,
+	token string,
+) *Server {
+	return &Server{
+		pollsRepo:    pollsRepo,
+		votersRepo:   votersRepo,
+		chatsRepo:    chatsRepo,
+		pollsManager: pollsManager,
+		queueService: queueService,
+		token:        token,
+	}
+}
+
+// Register adds the API routes to mux under /api/v1.
+func (s *Server) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		if _, err := w.Write(openAPISpec); err != nil {
+			slog.WarnContext(r.Context(), "api: write response failed", logging.Err(err))
+		}
+	})
+
+	mux.Handle("GET /api/v1/chats/{chatID}/polls", s.auth(s.listPolls))
+	mux.Handle("POST /api/v1/polls", s.auth(s.createPoll))
+	mux.Handle("GET /api/v1/polls/{pollID}", s.auth(s.getPoll))
+	mux.Handle("POST /api/v1/polls/{pollID}/close", s.auth(s.closePoll))
+	mux.Handle("POST /api/v1/polls/{pollID}/extend", s.auth(s.extendPoll))
+	mux.Handle("POST /api/v1/polls/{pollID}/cancel", s.auth(s.cancelPoll))
+	mux.Handle("GET /api/v1/polls/{pollID}/queue", s.auth(s.getQueue))
+	mux.Handle("POST /api/v1/polls/{pollID}/queue", s.auth(s.joinQueue))
+	mux.Handle("PUT /api/v1/polls/{pollID}/queue", s.auth(s.reorderQueue))
+	mux.Handle("DELETE /api/v1/polls/{pollID}/queue/{userID}", s.auth(s.leaveQueue))
+}
+
+// auth rejects requests without the configured bearer token.
+func (s *Server) auth(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		next(w, r.WithContext(requestContext(r)))
+	})
+}
+
+// requestContext adds the request and the chat, poll and user in its path to the log attributes.
+func requestContext(r *http.Request) context.Context {
+	ctx := logging.With(r.Context(), slog.String("method", r.Method), slog.String("path", r.URL.Path))
+	if id := r.PathValue("pollID"); id != "" {
+		ctx = logging.With(ctx, logging.PollID(id))
+	}
+	if id, err := strconv.ParseInt(r.PathValue("chatID"), 10, 64); err == nil {
+		ctx = logging.With(ctx, logging.ChatID(id))
+	}
+	if id, err := strconv.ParseInt(r.PathValue("userID"), 10, 64); err == nil {
+		ctx = logging.With(ctx, logging.UserID(id))
+	}
+	return ctx
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("api: write response failed", logging.Err(err))
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
+
+// writeServiceError maps repository and service errors to HTTP statuses.
+func writeServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		writeError(w, http.StatusNotFound, "not found")
+	case errors.Is(err, polls.ErrPollNotActive),
+		errors.Is(err, queue.ErrAlreadyInQueue),
+		errors.Is(err, queue.ErrNotInQueue),
+		errors.Is(err, queue.ErrQueueMismatch),
+		errors.Is(err, queue.ErrNotPublished):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		slog.ErrorContext(r.Context(), "api: request failed", logging.Err(err))
+		writeError(w, http.StatusInternalServerError, "internal error")
+	}
+}
+
+func decodeJSON(r *http.Request, v any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// LoginTTL is how long a login link sent by the bot stays valid.
+	LoginTTL = 15 * time.Minute
+	// CalendarTTL is how long a calendar feed link stays valid. Calendar apps poll
+	// the feed without user interaction, so it lives much longer than a login link.
+	CalendarTTL = 365 * 24 * time.Hour
+)
+
+// Links builds signed dashboard login links.
+type Links struct {
+	signer  *Signer
+	baseURL string
+}
+
+// NewLinks creates a link builder for the dashboard served at baseURL, e.g. "https://lineup.example.com".
+func NewLinks(signer *Signer, baseURL string) *Links {
+	return &Links{signer: signer, baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// LoginURL returns a one-chat login link for userID valid for LoginTTL.
+func (l *Links) LoginURL(userID, chatID int64, chatTitle string) (string, error) {
+	token, err := l.signer.Sign(Claims{Purpose: PurposeLogin, UserID: userID, Chats: map[int64]string{chatID: chatTitle}}, LoginTTL)
+	if err != nil {
+		return "", err
+	}
+	return l.baseURL + "/dashboard/login?token=" + url.QueryEscape(token), nil
+}
+
+// CalendarURL returns the link to userID's iCal feed of upcoming sessions, valid for CalendarTTL.
+func (l *Links) CalendarURL(userID int64) (string, error) {
+	token, err := l.signer.Sign(Claims{Purpose: PurposeCalendar, UserID: userID}, CalendarTTL)
+	if err != nil {
+		return "", err
+	}
+	return l.baseURL + "/calendar/" + url.PathEscape(token) + ".ics", nil
+}
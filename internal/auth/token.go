@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Token purposes. A token signed for one purpose is rejected for any other.
+const (
+	PurposeLogin    = "login"
+	PurposeSession  = "session"
+	PurposeCalendar = "calendar"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token expired")
+)
+
+// Claims is the payload of a signed token.
+type Claims struct {
+	Purpose   string           `json:"p"`
+	UserID    int64            `json:"u"`
+	Chats     map[int64]string `json:"c,omitempty"` // chat ID to chat title
+	ExpiresAt int64            `json:"e"`           // unix seconds
+}
+
+// HasChat reports whether the claims grant access to chatID.
+func (c *Claims) HasChat(chatID int64) bool {
+	_, ok := c.Chats[chatID]
+	return ok
+}
+
+// Signer issues and verifies HMAC-SHA256 signed tokens, so that no server-side session storage is needed.
+type Signer struct {
+	key []byte
+}
+
+// NewSigner creates a signer with the given secret.
+func NewSigner(secret string) *Signer {
+	return &Signer{key: []byte(secret)}
+}
+
+// Sign returns a URL-safe token for c that expires after ttl.
+func (s *Signer) Sign(c Claims, ttl time.Duration) (string, error) {
+	c.ExpiresAt = time.Now().Add(ttl).Unix()
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	p := base64.RawURLEncoding.EncodeToString(payload)
+	return p + "." + base64.RawURLEncoding.EncodeToString(s.mac(p)), nil
+}
+
+// Verify checks the token signature, purpose and expiry and returns its claims.
+func (s *Signer) Verify(token, purpose string) (*Claims, error) {
+	p, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, s.mac(p)) {
+		return nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(p)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var c Claims
+	if err := json.Unmarshal(payload, &c); err != nil || c.Purpose != purpose {
+		return nil, ErrInvalidToken
+	}
+	if time.Now().Unix() >= c.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+	return &c, nil
+}
+
+func (s *Signer) mac(payload string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
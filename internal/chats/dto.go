@@ -0,0 +1,32 @@
+package chats
+
+import (
+	"time"
+
+	"github.com/nikitkaralius/lineup/internal/i18n"
+)
+
+type ChatSettingsDTO struct {
+	ChatID            int64
+	ThreadID          int // forum topic the settings are for, 0 for the chat
+	Language          i18n.Lang
+	Timezone          string // IANA timezone name used to display times
+	TemplateName      string // results template preset name or "custom"
+	TemplateBody      string // text/template body of a custom template
+	TemplateParseMode string // Telegram parse mode of a custom template
+
+	// Configured for all chats, not changeable per chat
+	Answers           []string      // poll answers when a request names none; empty for the localized defaults
+	ComingAnswerIndex int           // index of the "coming" answer in Answers
+	Duration          time.Duration // poll duration when a request names none; 0 to ask for one
+}
+
+// Defaults are the settings of chats that never changed them.
+type Defaults struct {
+	Language          i18n.Lang
+	Timezone          string
+	TemplateName      string
+	Answers           []string
+	ComingAnswerIndex int
+	Duration          time.Duration
+}
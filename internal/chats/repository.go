@@ -0,0 +1,123 @@
+package chats
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nikitkaralius/lineup/internal/i18n"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/utils"
+)
+
+type Repository struct {
+	DB       *pgxpool.Pool
+	Defaults Defaults
+}
+
+// NewRepository returns a repository filling in defaults for what chats did not set.
+// Zero fields of defaults are the built-in ones.
+func NewRepository(db *pgxpool.Pool, defaults Defaults) *Repository {
+	if defaults.Language == "" {
+		defaults.Language = i18n.Default
+	}
+	if defaults.Timezone == "" {
+		defaults.Timezone = utils.DefaultTimezone
+	}
+	return &Repository{DB: db, Defaults: defaults}
+}
+
+// GetSettings returns the settings of the forum topic threadID of the chat, or of the
+// chat itself if threadID is 0. What the topic did not set is the chat's, and what
+// neither set the defaults.
+func (s *Repository) GetSettings(ctx context.Context, chatID int64, threadID int) (*ChatSettingsDTO, error) {
+	settings := s.defaultSettings(chatID, threadID)
+	var language string
+	// Unset columns are NULL and keep the defaults. The template body and parse mode
+	// belong to whichever row chose the template.
+	err := s.DB.QueryRow(ctx, `SELECT COALESCE(t.language,c.language,$3), COALESCE(t.timezone,c.timezone,$4),
+		COALESCE(t.template_name,c.template_name,$5),
+		CASE WHEN t.template_name IS NOT NULL THEN COALESCE(t.template_body,'') ELSE COALESCE(c.template_body,'') END,
+		CASE WHEN t.template_name IS NOT NULL THEN t.template_parse_mode ELSE COALESCE(c.template_parse_mode,'') END
+	FROM (SELECT 1) AS one
+	LEFT JOIN chat_settings c ON c.chat_id=$1
+	LEFT JOIN topic_settings t ON t.chat_id=$1 AND t.thread_id=$2 AND $2<>0`,
+		chatID, threadID, string(settings.Language), settings.Timezone, settings.TemplateName).
+		Scan(&language, &settings.Timezone, &settings.TemplateName, &settings.TemplateBody, &settings.TemplateParseMode)
+	if err != nil {
+		return nil, err
+	}
+	if lang, ok := i18n.Parse(language); ok {
+		settings.Language = lang
+	}
+	return &settings, nil
+}
+
+// GetLanguage returns the language of the chat or its forum topic, falling back to the default on any error.
+func (s *Repository) GetLanguage(ctx context.Context, chatID int64, threadID int) i18n.Lang {
+	return s.GetSettingsOrDefault(ctx, chatID, threadID).Language
+}
+
+// SetLanguage sets the language of the forum topic threadID, or of the chat if threadID is 0.
+func (s *Repository) SetLanguage(ctx context.Context, chatID int64, threadID int, lang i18n.Lang) error {
+	if threadID != 0 {
+		_, err := s.DB.Exec(ctx, `INSERT INTO topic_settings (chat_id, thread_id, language, updated_at) VALUES ($1,$2,$3,NOW())
+	ON CONFLICT (chat_id, thread_id) DO UPDATE SET language=EXCLUDED.language, updated_at=NOW()`, chatID, threadID, string(lang))
+		return err
+	}
+	_, err := s.DB.Exec(ctx, `INSERT INTO chat_settings (chat_id, language, updated_at) VALUES ($1,$2,NOW())
+	ON CONFLICT (chat_id) DO UPDATE SET language=EXCLUDED.language, updated_at=NOW()`, chatID, string(lang))
+	return err
+}
+
+// GetSettingsOrDefault returns the settings of the chat or its forum topic, falling back to the defaults on any error.
+func (s *Repository) GetSettingsOrDefault(ctx context.Context, chatID int64, threadID int) *ChatSettingsDTO {
+	settings, err := s.GetSettings(ctx, chatID, threadID)
+	if err != nil {
+		slog.WarnContext(ctx, "get chat settings failed, using the defaults", logging.ChatID(chatID), logging.Err(err))
+		settings := s.defaultSettings(chatID, threadID)
+		return &settings
+	}
+	return settings
+}
+
+func (s *Repository) defaultSettings(chatID int64, threadID int) ChatSettingsDTO {
+	d := s.Defaults
+	return ChatSettingsDTO{
+		ChatID:            chatID,
+		ThreadID:          threadID,
+		Language:          d.Language,
+		Timezone:          d.Timezone,
+		TemplateName:      d.TemplateName,
+		Answers:           d.Answers,
+		ComingAnswerIndex: d.ComingAnswerIndex,
+		Duration:          d.Duration,
+	}
+}
+
+// SetTimezone sets the timezone of the forum topic threadID, or of the chat if threadID is 0.
+func (s *Repository) SetTimezone(ctx context.Context, chatID int64, threadID int, timezone string) error {
+	if threadID != 0 {
+		_, err := s.DB.Exec(ctx, `INSERT INTO topic_settings (chat_id, thread_id, timezone, updated_at) VALUES ($1,$2,$3,NOW())
+	ON CONFLICT (chat_id, thread_id) DO UPDATE SET timezone=EXCLUDED.timezone, updated_at=NOW()`, chatID, threadID, timezone)
+		return err
+	}
+	_, err := s.DB.Exec(ctx, `INSERT INTO chat_settings (chat_id, timezone, updated_at) VALUES ($1,$2,NOW())
+	ON CONFLICT (chat_id) DO UPDATE SET timezone=EXCLUDED.timezone, updated_at=NOW()`, chatID, timezone)
+	return err
+}
+
+// SetTemplate selects the results template of the forum topic threadID, or of the chat if threadID is 0.
+// body and parseMode are only used by custom templates.
+func (s *Repository) SetTemplate(ctx context.Context, chatID int64, threadID int, name, body, parseMode string) error {
+	if threadID != 0 {
+		_, err := s.DB.Exec(ctx, `INSERT INTO topic_settings (chat_id, thread_id, template_name, template_body, template_parse_mode, updated_at) VALUES ($1,$2,$3,NULLIF($4,''),$5,NOW())
+	ON CONFLICT (chat_id, thread_id) DO UPDATE SET template_name=EXCLUDED.template_name, template_body=EXCLUDED.template_body, template_parse_mode=EXCLUDED.template_parse_mode, updated_at=NOW()`,
+			chatID, threadID, name, body, parseMode)
+		return err
+	}
+	_, err := s.DB.Exec(ctx, `INSERT INTO chat_settings (chat_id, template_name, template_body, template_parse_mode, updated_at) VALUES ($1,$2,NULLIF($3,''),$4,NOW())
+	ON CONFLICT (chat_id) DO UPDATE SET template_name=EXCLUDED.template_name, template_body=EXCLUDED.template_body, template_parse_mode=EXCLUDED.template_parse_mode, updated_at=NOW()`,
+		chatID, name, body, parseMode)
+	return err
+}
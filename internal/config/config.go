@@ -0,0 +1,229 @@
+// Package config loads the settings of the service and the worker.
+//
+// Settings are layered, later layers overriding earlier ones:
+//
+//  1. built-in defaults, see Default
+//  2. a YAML or TOML file given with -config or LINEUP_CONFIG
+//  3. environment variables, unless empty
+//  4. command line flags
+//
+// Each setting's file key, environment variable and flag are declared in the
+// struct tags of Config: yaml and toml name the file key, env the variable and
+// flag the flag. Settings tagged secret are redacted by Print.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/nikitkaralius/lineup/internal/chats"
+	"github.com/nikitkaralius/lineup/internal/i18n"
+	"github.com/nikitkaralius/lineup/internal/llm"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/messenger"
+	"github.com/nikitkaralius/lineup/internal/queue"
+	"github.com/nikitkaralius/lineup/internal/utils"
+	"github.com/riverqueue/river"
+)
+
+// Update modes.
+const (
+	ModeLongPolling = "long-polling"
+	ModeWebhook     = "webhook"
+)
+
+type Config struct {
+	Database  Database  `yaml:"database" toml:"database"`
+	Telegram  Telegram  `yaml:"telegram" toml:"telegram"`
+	Log       Log       `yaml:"log" toml:"log"`
+	HTTP      HTTP      `yaml:"http" toml:"http"`
+	Updates   Updates   `yaml:"updates" toml:"updates"`
+	LLM       LLM       `yaml:"llm" toml:"llm"`
+	River     River     `yaml:"river" toml:"river"`
+	API       API       `yaml:"api" toml:"api"`
+	Dashboard Dashboard `yaml:"dashboard" toml:"dashboard"`
+	Chats     Chats     `yaml:"chats" toml:"chats"`
+
+	// PrintConfig asks to print the loaded configuration and exit instead of running.
+	PrintConfig bool `yaml:"-" toml:"-" flag:"print-config" usage:"Print the configuration with secrets redacted and exit"`
+}
+
+type Database struct {
+	DSN         string `yaml:"dsn" toml:"dsn" env:"POSTGRES_DSN" flag:"dsn" usage:"Postgres DB DSN (required)" secret:"dsn"`
+	AutoMigrate bool   `yaml:"auto_migrate" toml:"auto_migrate" env:"POSTGRES_AUTO_MIGRATE" flag:"auto-migrate" usage:"Apply pending database migrations on start"`
+}
+
+type Telegram struct {
+	BotToken        string   `yaml:"bot_token" toml:"bot_token" env:"TELEGRAM_BOT_TOKEN" usage:"Telegram bot token (required)" secret:"true"`
+	Mode            string   `yaml:"mode" toml:"mode" env:"TELEGRAM_MODE" flag:"mode" usage:"Bot update mode: long-polling or webhook"`
+	WebhookURL      string   `yaml:"webhook_url" toml:"webhook_url" env:"TELEGRAM_WEBHOOK_URL" flag:"webhook-url" usage:"Telegram webhook public URL (required for webhook mode)"`
+	WebhookSecret   string   `yaml:"webhook_secret" toml:"webhook_secret" env:"TELEGRAM_WEBHOOK_SECRET" usage:"Webhook secret_token, random on every start if unset" secret:"true"`
+	WebhookAllowIPs []string `yaml:"webhook_allow_ips" toml:"webhook_allow_ips" env:"TELEGRAM_WEBHOOK_ALLOW_IPS" flag:"webhook-allow-ips" usage:"Comma-separated CIDRs webhook requests are accepted from, \"telegram\" for Telegram's networks (default any)"`
+	WebhookIPHeader string   `yaml:"webhook_ip_header" toml:"webhook_ip_header" env:"TELEGRAM_WEBHOOK_IP_HEADER" flag:"webhook-ip-header" usage:"Header with the client address set by a reverse proxy, e.g. X-Real-IP (default the connection address)"`
+
+	// Send limits of the outbox all Bot API requests go through.
+	SendRate          int           `yaml:"send_rate" toml:"send_rate" env:"TELEGRAM_SEND_RATE" flag:"send-rate" usage:"Bot API requests per second across all chats"`
+	ChatSendInterval  time.Duration `yaml:"chat_send_interval" toml:"chat_send_interval" env:"TELEGRAM_CHAT_SEND_INTERVAL" usage:"Time between messages to one private chat"`
+	GroupSendInterval time.Duration `yaml:"group_send_interval" toml:"group_send_interval" env:"TELEGRAM_GROUP_SEND_INTERVAL" usage:"Time between messages to one group"`
+}
+
+type Log struct {
+	Level string `yaml:"level" toml:"level" env:"LOG_LEVEL" flag:"log-level" usage:"Log level: debug, info, warn or error; debug also logs Bot API requests"`
+}
+
+type HTTP struct {
+	Addr string `yaml:"addr" toml:"addr" env:"HTTP_ADDR" flag:"http-addr" usage:"HTTP listen address for the webhook, API, dashboard, probes and metrics"`
+}
+
+type Updates struct {
+	Workers         int           `yaml:"workers" toml:"workers" env:"UPDATE_WORKERS" flag:"workers" usage:"Number of updates handled in parallel, at most one per chat"`
+	QueueSize       int           `yaml:"queue_size" toml:"queue_size" env:"UPDATE_QUEUE_SIZE" flag:"update-queue" usage:"Updates accepted but not yet handled before intake blocks"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"Time to finish handling accepted updates and running jobs on shutdown"`
+}
+
+type LLM struct {
+	BaseURL  string        `yaml:"base_url" toml:"base_url" env:"LLM_BASE_URL" usage:"OpenAI-compatible API base URL"`
+	APIKey   string        `yaml:"api_key" toml:"api_key" env:"YANDEX_API_KEY" usage:"LLM API key (required by the service)" secret:"true"`
+	FolderID string        `yaml:"folder_id" toml:"folder_id" env:"YANDEX_FOLDER_ID" usage:"Yandex Cloud folder ID; empty for other providers"`
+	Model    string        `yaml:"model" toml:"model" env:"LLM_MODEL" usage:"Model name"`
+	Timeout  time.Duration `yaml:"timeout" toml:"timeout" env:"LLM_TIMEOUT" usage:"Timeout of LLM requests"`
+}
+
+type River struct {
+	// Queues maps River queue names to their maximum number of concurrently running jobs.
+	// Setting it replaces the defaults, and must keep the default queue.
+	Queues map[string]int `yaml:"queues" toml:"queues" env:"RIVER_QUEUES" usage:"Comma-separated queue=max_workers pairs"`
+}
+
+type API struct {
+	Token string `yaml:"token" toml:"token" env:"API_TOKEN" usage:"Bearer token of the JSON API, which is only served when set" secret:"true"`
+}
+
+type Dashboard struct {
+	URL    string `yaml:"url" toml:"url" env:"DASHBOARD_URL" usage:"Public base URL of the service, enables the web dashboard"`
+	Secret string `yaml:"secret" toml:"secret" env:"DASHBOARD_SECRET" usage:"Secret signing dashboard links and sessions (required with the dashboard URL)" secret:"true"`
+}
+
+// Chats holds the defaults of chats that did not choose otherwise.
+type Chats struct {
+	Language          string        `yaml:"language" toml:"language" env:"CHAT_LANGUAGE" usage:"Language of chats that did not choose one"`
+	Timezone          string        `yaml:"timezone" toml:"timezone" env:"CHAT_TIMEZONE" usage:"IANA timezone of chats that did not choose one"`
+	Template          string        `yaml:"template" toml:"template" env:"CHAT_TEMPLATE" usage:"Results template preset of chats that did not choose one"`
+	Answers           []string      `yaml:"answers" toml:"answers" env:"CHAT_ANSWERS" usage:"Comma-separated poll answers when a request names none (default \"coming\" and \"not coming\" in the chat language)"`
+	ComingAnswerIndex int           `yaml:"coming_answer_index" toml:"coming_answer_index" env:"CHAT_COMING_ANSWER_INDEX" usage:"0-based index of the \"coming\" answer in the default answers"`
+	Duration          time.Duration `yaml:"duration" toml:"duration" env:"CHAT_POLL_DURATION" usage:"Poll duration when a request names none; 0 asks for one"`
+}
+
+// Default returns the built-in configuration.
+func Default() Config {
+	return Config{
+		Database: Database{AutoMigrate: true},
+		Telegram: Telegram{Mode: ModeLongPolling, SendRate: 25, ChatSendInterval: time.Second, GroupSendInterval: 3 * time.Second},
+		Log:      Log{Level: "info"},
+		HTTP:     HTTP{Addr: ":8080"},
+		Updates:  Updates{Workers: 8, QueueSize: 100, ShutdownTimeout: 30 * time.Second},
+		LLM:      LLM{BaseURL: llm.DefaultBaseURL, Model: llm.DefaultModel, Timeout: llm.DefaultTimeout},
+		River:    River{Queues: map[string]int{river.QueueDefault: 100}},
+		Chats:    Chats{Language: string(i18n.Default), Timezone: utils.DefaultTimezone, Template: queue.DefaultTemplate},
+	}
+}
+
+// Validate checks the settings needed by both the service and the worker.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Database.DSN != "", "database dsn is required")
+	check(c.Telegram.BotToken != "", "telegram bot token is required")
+	check(c.Telegram.Mode == ModeLongPolling || c.Telegram.Mode == ModeWebhook, "unknown telegram mode %q, want %s or %s", c.Telegram.Mode, ModeLongPolling, ModeWebhook)
+	check(c.Telegram.Mode != ModeWebhook || c.Telegram.WebhookURL != "", "telegram webhook url is required in webhook mode")
+	check(c.Telegram.SendRate > 0, "telegram send rate must be positive")
+	check(c.Telegram.ChatSendInterval >= 0 && c.Telegram.GroupSendInterval >= 0, "telegram send intervals must not be negative")
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, err)
+	}
+	check(c.Updates.Workers > 0, "update workers must be positive")
+	check(c.Updates.QueueSize > 0, "update queue size must be positive")
+	check(c.Updates.ShutdownTimeout > 0, "shutdown timeout must be positive")
+	check(c.LLM.Timeout > 0, "llm timeout must be positive")
+	if c.LLM.BaseURL != "" {
+		if _, err := url.ParseRequestURI(c.LLM.BaseURL); err != nil {
+			errs = append(errs, fmt.Errorf("invalid llm base url: %w", err))
+		}
+	}
+	_, ok := c.River.Queues[river.QueueDefault]
+	check(ok, "river queue %s is required, all jobs are inserted into it", river.QueueDefault)
+	for name, workers := range c.River.Queues {
+		check(workers > 0, "river queue %s needs a positive number of workers", name)
+	}
+	check(c.Dashboard.URL == "" || c.Dashboard.Secret != "", "dashboard secret is required when the dashboard url is set")
+
+	_, ok = i18n.Parse(c.Chats.Language)
+	check(ok, "unsupported chat language %q", c.Chats.Language)
+	_, err := time.LoadLocation(c.Chats.Timezone)
+	check(err == nil && c.Chats.Timezone != "Local", "unknown chat timezone %q", c.Chats.Timezone)
+	_, ok = queue.Presets[c.Chats.Template]
+	check(ok, "unknown chat template %q", c.Chats.Template)
+	check(len(c.Chats.Answers) != 1, "chat answers need at least two answers")
+	check(len(c.Chats.Answers) == 0 || (c.Chats.ComingAnswerIndex >= 0 && c.Chats.ComingAnswerIndex < len(c.Chats.Answers)), "chat coming answer index %d is not one of the answers", c.Chats.ComingAnswerIndex)
+	check(c.Chats.Duration >= 0, "chat poll duration must not be negative")
+
+	return errors.Join(errs...)
+}
+
+// ValidateService additionally checks the settings only the service needs.
+func (c *Config) ValidateService() error {
+	var errs []error
+	if err := c.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.LLM.APIKey == "" {
+		errs = append(errs, errors.New("llm api key is required"))
+	}
+	return errors.Join(errs...)
+}
+
+// LogLevel returns the parsed log level; call Validate first.
+func (c *Config) LogLevel() slog.Level {
+	level, _ := logging.ParseLevel(c.Log.Level)
+	return level
+}
+
+// ChatDefaults returns the chat defaults for chats.NewRepository.
+func (c *Config) ChatDefaults() chats.Defaults {
+	lang, _ := i18n.Parse(c.Chats.Language)
+	return chats.Defaults{
+		Language:          lang,
+		Timezone:          c.Chats.Timezone,
+		TemplateName:      c.Chats.Template,
+		Answers:           c.Chats.Answers,
+		ComingAnswerIndex: c.Chats.ComingAnswerIndex,
+		Duration:          c.Chats.Duration,
+	}
+}
+
+// SendLimits returns the limits for messenger.NewOutbox.
+func (c *Config) SendLimits() messenger.Limits {
+	return messenger.Limits{PerSecond: c.Telegram.SendRate, ChatInterval: c.Telegram.ChatSendInterval, GroupInterval: c.Telegram.GroupSendInterval}
+}
+
+// LLMConfig returns the settings for llm.NewClient.
+func (c *Config) LLMConfig() llm.Config {
+	return llm.Config{BaseURL: c.LLM.BaseURL, APIKey: c.LLM.APIKey, FolderID: c.LLM.FolderID, Model: c.LLM.Model, Timeout: c.LLM.Timeout}
+}
+
+// RiverQueues returns the queue settings for river.Config.
+func (c *Config) RiverQueues() map[string]river.QueueConfig {
+	queues := make(map[string]river.QueueConfig, len(c.River.Queues))
+	for name, workers := range c.River.Queues {
+		queues[name] = river.QueueConfig{MaxWorkers: workers}
+	}
+	return queues
+}
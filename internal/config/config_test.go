@@ -0,0 +1,165 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "lineup.yaml", `
+database:
+  dsn: postgres://file
+log:
+  level: warn
+updates:
+  workers: 2
+  queue_size: 20
+river:
+  queues:
+    default: 50
+    webhooks: 5
+chats:
+  duration: 1h
+  answers: [Yes, No]
+`)
+	t.Setenv(FileEnv, path)
+	t.Setenv("LOG_LEVEL", "error")
+	t.Setenv("UPDATE_WORKERS", "3")
+	t.Setenv("CHAT_TIMEZONE", "Europe/Berlin")
+
+	cfg, err := Load("test", []string{"-workers", "4"}, Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Database.DSN != "postgres://file" {
+		t.Errorf("dsn = %q, want the file's", cfg.Database.DSN)
+	}
+	if cfg.Log.Level != "error" {
+		t.Errorf("log level = %q, want the environment's", cfg.Log.Level)
+	}
+	if cfg.Updates.Workers != 4 {
+		t.Errorf("workers = %d, want the flag's", cfg.Updates.Workers)
+	}
+	if cfg.Updates.QueueSize != 20 || cfg.Chats.Duration != time.Hour || len(cfg.Chats.Answers) != 2 {
+		t.Errorf("file settings not applied: %+v %+v", cfg.Updates, cfg.Chats)
+	}
+	if cfg.Chats.Timezone != "Europe/Berlin" {
+		t.Errorf("timezone = %q, want the environment's", cfg.Chats.Timezone)
+	}
+	if cfg.Updates.ShutdownTimeout != 30*time.Second {
+		t.Errorf("shutdown timeout = %s, want the default", cfg.Updates.ShutdownTimeout)
+	}
+	if len(cfg.River.Queues) != 2 || cfg.River.Queues["default"] != 50 || cfg.River.Queues["webhooks"] != 5 {
+		t.Errorf("river queues = %v, want the file's", cfg.River.Queues)
+	}
+	if Default().River.Queues["webhooks"] != 0 {
+		t.Error("loading changed the defaults")
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	path := writeFile(t, "lineup.toml", `
+[database]
+dsn = "postgres://toml"
+
+[updates]
+shutdown_timeout = "5s"
+`)
+	cfg, err := Load("test", []string{"-config", path}, Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.DSN != "postgres://toml" || cfg.Updates.ShutdownTimeout != 5*time.Second {
+		t.Errorf("got %+v %+v", cfg.Database, cfg.Updates)
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	for name, content := range map[string]string{
+		"lineup.yaml": "database:\n  dns: postgres://typo\n",
+		"lineup.toml": "[database]\ndns = \"postgres://typo\"\n",
+	} {
+		if _, err := Load("test", []string{"-config", writeFile(t, name, content)}, Default()); err == nil {
+			t.Errorf("%s: unknown key accepted", name)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.Database.DSN = "postgres://localhost/lineup"
+	cfg.Telegram.BotToken = "token"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("valid config: %v", err)
+	}
+	if err := cfg.ValidateService(); err == nil {
+		t.Error("service config without an LLM key accepted")
+	}
+
+	cfg.Telegram.Mode = ModeWebhook
+	cfg.Chats.Timezone = "Mars/Olympus"
+	cfg.Chats.Answers = []string{"Yes", "No"}
+	cfg.Chats.ComingAnswerIndex = 2
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("invalid config accepted")
+	}
+	for _, want := range []string{"webhook url", "timezone", "coming answer index"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.Database.DSN = "postgres://lineup:hunter2@db:5432/lineup?sslmode=disable"
+	cfg.Telegram.BotToken = "123:secret-token"
+	cfg.Dashboard.Secret = "dashboard-secret"
+
+	buf := bytes.Buffer{}
+	if err := cfg.Print(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, secret := range []string{"hunter2", "secret-token", "dashboard-secret"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("printed config contains %q:\n%s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "lineup:REDACTED@db:5432") {
+		t.Errorf("printed config lacks the redacted DSN:\n%s", out)
+	}
+	if cfg.Telegram.BotToken != "123:secret-token" {
+		t.Error("Print changed the config")
+	}
+
+	if got := redactDSN("host=db user=lineup password=hunter2 dbname=lineup"); strings.Contains(got, "hunter2") {
+		t.Errorf("redactDSN kept the password: %s", got)
+	}
+}
+
+func TestExampleConfig(t *testing.T) {
+	t.Setenv("TELEGRAM_BOT_TOKEN", "token")
+	cfg, err := Load("test", []string{"-config", "../../config.example.yaml"}, Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("example config is invalid: %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/goccy/go-yaml"
+)
+
+// FileEnv names the configuration file when -config is not given.
+const FileEnv = "LINEUP_CONFIG"
+
+// Load builds the configuration from defaults, the configuration file, the environment
+// and the command line arguments args, in increasing precedence. It does not validate
+// the result. name is the program name shown in the usage message.
+func Load(name string, args []string, defaults Config) (*Config, error) {
+	cfg := defaults
+	// Maps would be shared with defaults otherwise
+	cfg.River.Queues = make(map[string]int, len(defaults.River.Queues))
+	for k, v := range defaults.River.Queues {
+		cfg.River.Queues[k] = v
+	}
+
+	// Flags are parsed first to find the file, but applied last
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	path := fs.String("config", os.Getenv(FileEnv), "YAML or TOML configuration file (default $"+FileEnv+")")
+	type setFlag struct {
+		name  string
+		field reflect.Value
+		value string
+	}
+	var flags []setFlag
+	err := walk(reflect.ValueOf(&cfg).Elem(), func(f reflect.StructField, v reflect.Value) error {
+		name := f.Tag.Get("flag")
+		if name == "" {
+			return nil
+		}
+		usage := f.Tag.Get("usage")
+		if !v.IsZero() {
+			usage += fmt.Sprintf(" (default %s)", format(v))
+		}
+		set := func(s string) error {
+			flags = append(flags, setFlag{name, v, s})
+			return nil
+		}
+		if v.Kind() == reflect.Bool {
+			fs.BoolFunc(name, usage, set)
+		} else {
+			fs.Func(name, usage, set)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	if *path != "" {
+		if err := loadFile(*path, &cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	err = walk(reflect.ValueOf(&cfg).Elem(), func(f reflect.StructField, v reflect.Value) error {
+		name := f.Tag.Get("env")
+		if name == "" {
+			return nil
+		}
+		// Empty variables count as unset, e.g. placeholders declared in a Dockerfile
+		s := os.Getenv(name)
+		if s == "" {
+			return nil
+		}
+		if err := parse(v, s); err != nil {
+			return fmt.Errorf("env %s: %w", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range flags {
+		if err := parse(f.field, f.value); err != nil {
+			return nil, fmt.Errorf("flag -%s: %w", f.name, err)
+		}
+	}
+	return &cfg, nil
+}
+
+// loadFile decodes the file at path into cfg, choosing the format by its extension.
+// Unknown keys are errors, so that typos do not go unnoticed.
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml":
+		err = yaml.NewDecoder(bytes.NewReader(data), yaml.Strict()).Decode(cfg)
+	case ".toml":
+		var md toml.MetaData
+		md, err = toml.Decode(string(data), cfg)
+		if err == nil && len(md.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown keys %v", md.Undecoded())
+		}
+	default:
+		return fmt.Errorf("config %s: unknown format %q, want .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	return nil
+}
+
+// walk calls fn for every setting in the struct v, descending into nested structs.
+func walk(v reflect.Value, fn func(reflect.StructField, reflect.Value) error) error {
+	t := v.Type()
+	for i := range t.NumField() {
+		f, fv := t.Field(i), v.Field(i)
+		if f.Type.Kind() == reflect.Struct {
+			if err := walk(fv, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(f, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// parse sets v from its environment variable or flag representation. Lists are
+// comma-separated, maps are comma-separated key=value pairs.
+func parse(v reflect.Value, s string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String && v.Type().Elem().Kind() == reflect.Int:
+		m := map[string]int{}
+		for _, pair := range strings.Split(s, ",") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok {
+				return fmt.Errorf("want key=value, got %q", pair)
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return err
+			}
+			m[strings.TrimSpace(key)] = n
+		}
+		v.Set(reflect.ValueOf(m))
+	default:
+		return errors.New("unsupported setting type " + v.Type().String())
+	}
+	return nil
+}
+
+// format is the inverse of parse.
+func format(v reflect.Value) string {
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Slice:
+		return strings.Join(v.Interface().([]string), ",")
+	case v.Kind() == reflect.Map:
+		m := v.Interface().(map[string]int)
+		pairs := make([]string, 0, len(m))
+		for k, n := range m {
+			pairs = append(pairs, k+"="+strconv.Itoa(n))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"regexp"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Redacted replaces secrets in printed configurations.
+const Redacted = "REDACTED"
+
+// Print writes the configuration as YAML with secrets redacted.
+func (c *Config) Print(w io.Writer) error {
+	redacted := *c
+	err := walk(reflect.ValueOf(&redacted).Elem(), func(f reflect.StructField, v reflect.Value) error {
+		switch f.Tag.Get("secret") {
+		case "true":
+			if v.String() != "" {
+				v.SetString(Redacted)
+			}
+		case "dsn":
+			v.SetString(redactDSN(v.String()))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	out, err := yaml.MarshalWithOptions(redacted, yaml.UseLiteralStyleIfMultiline(true))
+	if err != nil {
+		return fmt.Errorf("print config: %w", err)
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+var dsnPassword = regexp.MustCompile(`(password=)('[^']*'|\S+)`)
+
+// redactDSN hides the password of a URL or key=value Postgres DSN.
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), Redacted)
+		}
+		dsn = u.String()
+	}
+	return dsnPassword.ReplaceAllString(dsn, "${1}"+Redacted)
+}
@@ -0,0 +1,185 @@
+// Package dispatch runs Telegram update handlers on a pool of workers.
+//
+// Updates with the same key, e.g. from the same chat, are handled one at a time in the
+// order they were dispatched; updates with different keys are handled in parallel.
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"runtime/debug"
+	"strconv"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nikitkaralius/lineup/internal/logging"
+)
+
+// ErrClosed is returned by Dispatch after Shutdown was called.
+var ErrClosed = errors.New("dispatcher is shut down")
+
+// Handler handles a single update.
+type Handler func(ctx context.Context, update Update)
+
+// Dispatcher queues updates per key and hands them to a fixed number of workers.
+type Dispatcher struct {
+	handler Handler
+
+	// ctx is passed to handlers; it is only cancelled when Shutdown gives up waiting,
+	// so that in-flight handlers are not interrupted by the shutdown signal itself
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	slots chan struct{} // limits accepted but unfinished updates
+	wg    sync.WaitGroup
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending map[string][]Update // per key; the first update is being handled or about to be
+	ready   []string            // keys with updates and no worker
+	closed  bool
+}
+
+// New starts a dispatcher with the given number of workers. Dispatch blocks once
+// queueSize updates are accepted but not yet handled.
+func New(handler Handler, workers, queueSize int) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		handler: handler,
+		ctx:     ctx,
+		cancel:  cancel,
+		slots:   make(chan struct{}, max(queueSize, 1)),
+		pending: make(map[string][]Update),
+	}
+	d.cond = sync.NewCond(&d.mu)
+	for range max(workers, 1) {
+		d.wg.Add(1)
+		go d.work()
+	}
+	return d
+}
+
+// Key returns the ordering key of an update: its chat, or for poll answers, which carry
+// no chat, the poll. Updates of other kinds share the empty key.
+func Key(u tgbotapi.Update) string {
+	switch {
+	case u.Message != nil:
+		return "chat:" + strconv.FormatInt(u.Message.Chat.ID, 10)
+	case u.EditedMessage != nil:
+		return "chat:" + strconv.FormatInt(u.EditedMessage.Chat.ID, 10)
+	case u.CallbackQuery != nil && u.CallbackQuery.Message != nil:
+		return "chat:" + strconv.FormatInt(u.CallbackQuery.Message.Chat.ID, 10)
+	case u.PollAnswer != nil:
+		return "poll:" + u.PollAnswer.PollID
+	case u.Poll != nil:
+		return "poll:" + u.Poll.ID
+	}
+	return ""
+}
+
+// Dispatch queues the update for handling. It waits while the queue is full and
+// returns ctx.Err() if ctx is done first, or ErrClosed if the dispatcher is shut down.
+func (d *Dispatcher) Dispatch(ctx context.Context, u Update) error {
+	d.mu.Lock()
+	closed := d.closed
+	d.mu.Unlock()
+	if closed {
+		return ErrClosed
+	}
+
+	select {
+	case d.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		<-d.slots
+		return ErrClosed
+	}
+	key := Key(u.Update)
+	queued, busy := d.pending[key]
+	d.pending[key] = append(queued, u)
+	if !busy {
+		d.ready = append(d.ready, key)
+		d.cond.Signal()
+	}
+	return nil
+}
+
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+	for {
+		d.mu.Lock()
+		for len(d.ready) == 0 && !d.closed {
+			d.cond.Wait()
+		}
+		if len(d.ready) == 0 {
+			// closed and drained
+			d.mu.Unlock()
+			return
+		}
+		key := d.ready[0]
+		d.ready = d.ready[1:]
+		u := d.pending[key][0]
+		d.mu.Unlock()
+
+		d.handle(u)
+		<-d.slots
+
+		d.mu.Lock()
+		if rest := d.pending[key][1:]; len(rest) > 0 {
+			d.pending[key] = rest
+			d.ready = append(d.ready, key)
+			d.cond.Signal()
+		} else {
+			delete(d.pending, key)
+		}
+		d.mu.Unlock()
+	}
+}
+
+// handle runs the handler, keeping the worker alive if it panics.
+func (d *Dispatcher) handle(u Update) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("handle update panicked", logging.UpdateID(u.UpdateID), slog.Any("panic", r), slog.String("stack", string(debug.Stack())))
+		}
+	}()
+	d.handler(d.ctx, u)
+}
+
+// Shutdown stops accepting updates and waits for the accepted ones to be handled.
+// If ctx is done first, the handlers' context is cancelled and ctx.Err() is returned
+// without waiting further.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	d.closed = true
+	d.cond.Broadcast()
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.mu.Lock()
+		left := 0
+		for _, queued := range d.pending {
+			left += len(queued)
+		}
+		d.mu.Unlock()
+		slog.Warn("dispatcher shutdown timed out", slog.Int("unhandled", left))
+		d.cancel()
+		return ctx.Err()
+	}
+}
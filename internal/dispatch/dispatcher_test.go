@@ -0,0 +1,129 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func message(id int, chatID int64) Update {
+	return Update{Update: tgbotapi.Update{UpdateID: id, Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: chatID}}}}
+}
+
+func TestPerChatOrder(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		seen = map[int64][]int{}
+	)
+	d := New(func(ctx context.Context, u Update) {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		seen[u.Message.Chat.ID] = append(seen[u.Message.Chat.ID], u.UpdateID)
+	}, 4, 100)
+
+	want := map[int64][]int{}
+	for i := range 60 {
+		chatID := int64(i % 3)
+		want[chatID] = append(want[chatID], i)
+		if err := d.Dispatch(context.Background(), message(i, chatID)); err != nil {
+			t.Fatalf("Dispatch: %v", err)
+		}
+	}
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	for chatID, ids := range want {
+		if !slices.Equal(seen[chatID], ids) {
+			t.Errorf("chat %d handled %v, want %v", chatID, seen[chatID], ids)
+		}
+	}
+}
+
+func TestChatsRunInParallel(t *testing.T) {
+	release := make(chan struct{})
+	fast := make(chan struct{})
+	d := New(func(ctx context.Context, u Update) {
+		if u.Message.Chat.ID == 1 {
+			<-release
+			return
+		}
+		close(fast)
+	}, 2, 10)
+	defer d.Shutdown(context.Background())
+
+	d.Dispatch(context.Background(), message(1, 1))
+	d.Dispatch(context.Background(), message(2, 2))
+	select {
+	case <-fast:
+	case <-time.After(time.Second):
+		t.Error("a slow chat blocked another one")
+	}
+	close(release)
+}
+
+func TestShutdownDrains(t *testing.T) {
+	var handled atomic.Int32
+	d := New(func(ctx context.Context, u Update) {
+		time.Sleep(10 * time.Millisecond)
+		if ctx.Err() == nil {
+			handled.Add(1)
+		}
+	}, 1, 10)
+	for i := range 5 {
+		d.Dispatch(context.Background(), message(i, 1))
+	}
+
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if n := handled.Load(); n != 5 {
+		t.Errorf("handled %d updates, want all 5", n)
+	}
+	if err := d.Dispatch(context.Background(), message(6, 1)); !errors.Is(err, ErrClosed) {
+		t.Errorf("Dispatch after Shutdown error = %v, want ErrClosed", err)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	cancelled := make(chan struct{})
+	d := New(func(ctx context.Context, u Update) {
+		<-ctx.Done()
+		close(cancelled)
+	}, 1, 10)
+	d.Dispatch(context.Background(), message(1, 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := d.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown error = %v, want context.DeadlineExceeded", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("handler context was not cancelled after the timeout")
+	}
+}
+
+func TestPanicKeepsWorker(t *testing.T) {
+	var handled atomic.Int32
+	d := New(func(ctx context.Context, u Update) {
+		if u.UpdateID == 1 {
+			panic("boom")
+		}
+		handled.Add(1)
+	}, 1, 10)
+	d.Dispatch(context.Background(), message(1, 1))
+	d.Dispatch(context.Background(), message(2, 1))
+	d.Shutdown(context.Background())
+	if handled.Load() != 1 {
+		t.Error("update after a panicking one was not handled")
+	}
+}
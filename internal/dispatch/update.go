@@ -0,0 +1,97 @@
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nikitkaralius/lineup/internal/logging"
+)
+
+// Update is a Telegram update with the fields tgbotapi v5.5.1 does not decode.
+type Update struct {
+	tgbotapi.Update
+	// MessageThreadID is the forum topic Message was posted in, 0 outside forum topics.
+	MessageThreadID int
+}
+
+func (u *Update) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &u.Update); err != nil {
+		return err
+	}
+	var topic struct {
+		Message *struct {
+			MessageThreadID int  `json:"message_thread_id"`
+			IsTopicMessage  bool `json:"is_topic_message"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(data, &topic); err != nil {
+		return err
+	}
+	// Replies in groups without topics carry a thread ID as well, but can't be posted to
+	if m := topic.Message; m != nil && m.IsTopicMessage {
+		u.MessageThreadID = m.MessageThreadID
+	}
+	return nil
+}
+
+// pollRetryDelay is how long LongPoll waits after getUpdates failed.
+const pollRetryDelay = 3 * time.Second
+
+// LongPoll receives updates with getUpdates, waiting up to timeout seconds for each
+// batch, and dispatches them to d until ctx is done. It takes the place of tgbotapi's
+// GetUpdatesChan, which decodes updates without the fields Update adds.
+func LongPoll(ctx context.Context, bot *tgbotapi.BotAPI, timeout int, d *Dispatcher) {
+	config := tgbotapi.NewUpdate(0)
+	config.Timeout = timeout
+	for {
+		updates, err := getUpdates(ctx, bot, config)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			slog.Warn("get updates failed, retrying", slog.Duration("delay", pollRetryDelay), logging.Err(err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(pollRetryDelay):
+			}
+			continue
+		}
+		for _, u := range updates {
+			// Asking for a later offset confirms the update
+			config.Offset = max(config.Offset, u.UpdateID+1)
+			if err := d.Dispatch(ctx, u); err != nil {
+				slog.Error("dispatch update failed", logging.UpdateID(u.UpdateID), logging.Err(err))
+			}
+		}
+	}
+}
+
+// getUpdates requests the next updates. tgbotapi takes no context, so the request is
+// abandoned when ctx is done; updates it receives are not confirmed and come again.
+func getUpdates(ctx context.Context, bot *tgbotapi.BotAPI, config tgbotapi.UpdateConfig) ([]Update, error) {
+	type result struct {
+		updates []Update
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := bot.Request(config)
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		var updates []Update
+		err = json.Unmarshal(resp.Result, &updates)
+		done <- result{updates, err}
+	}()
+	select {
+	case r := <-done:
+		return r.updates, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,84 @@
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nikitkaralius/lineup/internal/telegramtest"
+)
+
+func TestUpdateThreadID(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want int
+	}{
+		{"topic message", `{"update_id":1,"message":{"message_id":5,"chat":{"id":-100},"message_thread_id":7,"is_topic_message":true}}`, 7},
+		{"reply outside topics", `{"update_id":1,"message":{"message_id":5,"chat":{"id":-100},"message_thread_id":3}}`, 0},
+		{"poll answer", `{"update_id":1,"poll_answer":{"poll_id":"p","option_ids":[0]}}`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var u Update
+			if err := json.Unmarshal([]byte(tt.json), &u); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if u.UpdateID != 1 {
+				t.Errorf("UpdateID = %d, want 1", u.UpdateID)
+			}
+			if u.MessageThreadID != tt.want {
+				t.Errorf("MessageThreadID = %d, want %d", u.MessageThreadID, tt.want)
+			}
+		})
+	}
+}
+
+func TestLongPoll(t *testing.T) {
+	api := telegramtest.NewServer()
+	defer api.Close()
+	bot, err := api.NewBot()
+	if err != nil {
+		t.Fatalf("NewBot: %v", err)
+	}
+
+	received := make(chan Update, 2)
+	d := New(func(ctx context.Context, u Update) { received <- u }, 1, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		LongPoll(ctx, bot, 1, d)
+	}()
+
+	chat := tgbotapi.Chat{ID: -100, Type: "supergroup"}
+	alice := tgbotapi.User{ID: 1, FirstName: "Alice"}
+	if _, err := api.SendTopicText(chat, 7, alice, "in the topic"); err != nil {
+		t.Fatalf("SendTopicText: %v", err)
+	}
+	if _, err := api.SendText(chat, alice, "in General"); err != nil {
+		t.Fatalf("SendText: %v", err)
+	}
+	for _, want := range []int{7, 0} {
+		select {
+		case u := <-received:
+			if u.MessageThreadID != want {
+				t.Errorf("update %q thread = %d, want %d", u.Message.Text, u.MessageThreadID, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("update was not dispatched")
+		}
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("LongPoll did not return after cancel")
+	}
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
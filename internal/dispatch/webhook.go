@@ -0,0 +1,140 @@
+package dispatch
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nikitkaralius/lineup/internal/logging"
+)
+
+// SecretTokenHeader carries the secret_token the webhook was registered with in every delivery.
+const SecretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// DefaultMaxBodyBytes limits webhook request bodies; updates are a few kilobytes.
+const DefaultMaxBodyBytes = 1 << 20
+
+// TelegramNets are the networks Telegram delivers webhooks from,
+// see https://core.telegram.org/bots/webhooks#the-short-version.
+var TelegramNets = []string{"149.154.160.0/20", "91.108.4.0/22"}
+
+// WebhookOptions secures the webhook endpoint.
+type WebhookOptions struct {
+	SecretToken string // required value of SecretTokenHeader; empty disables the check
+
+	// AllowedNets restricts source addresses, e.g. to TelegramNets; empty allows any.
+	AllowedNets []*net.IPNet
+	// ClientIPHeader names the header with the client address set by a reverse proxy,
+	// e.g. X-Real-IP. Empty uses the connection's remote address.
+	ClientIPHeader string
+
+	MaxBodyBytes int64 // 0 means DefaultMaxBodyBytes
+}
+
+// NewSecretToken returns a random secret_token. Telegram allows 1-256 characters of A-Z, a-z, 0-9, _ and -.
+func NewSecretToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ParseNets parses CIDRs such as TelegramNets.
+func ParseNets(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// SetWebhook registers url as the bot's webhook with secretToken.
+// tgbotapi's WebhookConfig has no secret_token, so the request is made directly.
+func SetWebhook(bot *tgbotapi.BotAPI, url, secretToken string) error {
+	params := tgbotapi.Params{"url": url}
+	params.AddNonEmpty("secret_token", secretToken)
+	resp, err := bot.MakeRequest("setWebhook", params)
+	if err != nil {
+		return err
+	}
+	if !resp.Ok {
+		return fmt.Errorf("setWebhook: %s", resp.Description)
+	}
+	return nil
+}
+
+// WebhookHandler accepts updates POSTed by Telegram and dispatches them to d.
+// Requests with a wrong secret token or from outside the allowed networks are rejected
+// with 403 and never decoded.
+func WebhookHandler(d *Dispatcher, opts WebhookOptions) http.Handler {
+	maxBody := opts.MaxBodyBytes
+	if maxBody == 0 {
+		maxBody = DefaultMaxBodyBytes
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !opts.allowed(r) {
+			slog.Warn("webhook request rejected: address not allowed", slog.String("remote_addr", r.RemoteAddr))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if opts.SecretToken != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get(SecretTokenHeader)), []byte(opts.SecretToken)) != 1 {
+			slog.Warn("webhook request rejected: wrong secret token", slog.String("remote_addr", r.RemoteAddr))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		var update Update
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody)).Decode(&update); err != nil {
+			slog.Warn("decode webhook update failed", logging.Err(err))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// Telegram redelivers the update later if it is not accepted now
+		if err := d.Dispatch(r.Context(), update); err != nil {
+			slog.Error("dispatch update failed", logging.UpdateID(update.UpdateID), logging.Err(err))
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func (o WebhookOptions) allowed(r *http.Request) bool {
+	if len(o.AllowedNets) == 0 {
+		return true
+	}
+
+	addr := r.RemoteAddr
+	if o.ClientIPHeader != "" {
+		// Proxies append to X-Forwarded-For; the last address is the one that connected to our proxy
+		values := strings.Split(r.Header.Get(o.ClientIPHeader), ",")
+		addr = strings.TrimSpace(values[len(values)-1])
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range o.AllowedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
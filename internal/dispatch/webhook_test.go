@@ -0,0 +1,105 @@
+package dispatch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nikitkaralius/lineup/internal/telegramtest"
+)
+
+func TestWebhookSecretToken(t *testing.T) {
+	api := telegramtest.NewServer()
+	defer api.Close()
+	bot, err := api.NewBot()
+	if err != nil {
+		t.Fatalf("NewBot: %v", err)
+	}
+
+	received := make(chan Update, 1)
+	d := New(func(ctx context.Context, u Update) { received <- u }, 1, 10)
+	defer d.Shutdown(context.Background())
+
+	secret, err := NewSecretToken()
+	if err != nil {
+		t.Fatalf("NewSecretToken: %v", err)
+	}
+	hook := httptest.NewServer(WebhookHandler(d, WebhookOptions{SecretToken: secret}))
+	defer hook.Close()
+	if err := SetWebhook(bot, hook.URL, secret); err != nil {
+		t.Fatalf("SetWebhook: %v", err)
+	}
+
+	// Telegram's deliveries carry the secret
+	status, err := api.SendText(tgbotapi.Chat{ID: -1}, tgbotapi.User{ID: 1}, "/help")
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("delivery = %d, %v, want 200", status, err)
+	}
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("update was not dispatched")
+	}
+
+	// Forged ones don't
+	forged := `{"update_id":1,"poll_answer":{"poll_id":"p","user":{"id":2},"option_ids":[0]}}`
+	for _, header := range []string{"", "wrong"} {
+		req, _ := http.NewRequest(http.MethodPost, hook.URL, strings.NewReader(forged))
+		if header != "" {
+			req.Header.Set(SecretTokenHeader, header)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("POST: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("secret %q: status = %d, want 403", header, resp.StatusCode)
+		}
+	}
+	select {
+	case u := <-received:
+		t.Errorf("forged update %d was dispatched", u.UpdateID)
+	default:
+	}
+}
+
+func TestWebhookLimits(t *testing.T) {
+	d := New(func(ctx context.Context, u Update) {}, 1, 10)
+	defer d.Shutdown(context.Background())
+
+	telegram, err := ParseNets(TelegramNets)
+	if err != nil {
+		t.Fatalf("ParseNets: %v", err)
+	}
+	h := WebhookHandler(d, WebhookOptions{AllowedNets: telegram, ClientIPHeader: "X-Forwarded-For", MaxBodyBytes: 64})
+
+	tests := []struct {
+		name      string
+		forwarded string
+		body      string
+		want      int
+	}{
+		{"telegram", "149.154.167.220", `{"update_id":1}`, http.StatusOK},
+		{"telegram behind proxies", "10.0.0.1, 91.108.4.10", `{"update_id":2}`, http.StatusOK},
+		{"elsewhere", "203.0.113.7", `{"update_id":3}`, http.StatusForbidden},
+		{"spoofed first hop", "149.154.167.220, 203.0.113.7", `{"update_id":4}`, http.StatusForbidden},
+		{"no address", "", `{"update_id":5}`, http.StatusForbidden},
+		{"too large", "149.154.167.220", `{"update_id":6,"message":{"text":"` + strings.Repeat("a", 100) + `"}}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/telegram/webhook", strings.NewReader(tt.body))
+			req.Header.Set("X-Forwarded-For", tt.forwarded)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package drafts
+
+import (
+	"time"
+
+	"github.com/nikitkaralius/lineup/internal/llm"
+)
+
+// TTL is how long a partially specified poll waits for the user's clarification.
+const TTL = 10 * time.Minute
+
+type PollDraftDTO struct {
+	ChatID            int64
+	UserID            int64
+	Intent            llm.PollIntent
+	Missing           llm.MissingField
+	QuestionMessageID int
+	ExpiresAt         time.Time
+}
@@ -0,0 +1,60 @@
+package drafts
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nikitkaralius/lineup/internal/llm"
+)
+
+type Repository struct {
+	DB *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{DB: db}
+}
+
+// SaveDraft stores the draft, replacing any previous one of the same user in the chat.
+// Expired drafts of all users are purged along the way.
+func (s *Repository) SaveDraft(ctx context.Context, d *PollDraftDTO) error {
+	intent, err := json.Marshal(d.Intent)
+	if err != nil {
+		return err
+	}
+	if _, err := s.DB.Exec(ctx, `DELETE FROM poll_drafts WHERE expires_at <= NOW()`); err != nil {
+		return err
+	}
+	_, err = s.DB.Exec(ctx, `INSERT INTO poll_drafts (chat_id, user_id, intent, missing, question_message_id, expires_at)
+	VALUES ($1,$2,$3,$4,$5,$6)
+	ON CONFLICT (chat_id, user_id) DO UPDATE SET intent=EXCLUDED.intent, missing=EXCLUDED.missing, question_message_id=EXCLUDED.question_message_id, expires_at=EXCLUDED.expires_at`,
+		d.ChatID, d.UserID, intent, string(d.Missing), d.QuestionMessageID, d.ExpiresAt,
+	)
+	return err
+}
+
+// FindDraft returns the user's unexpired draft in the chat or pgx.ErrNoRows.
+func (s *Repository) FindDraft(ctx context.Context, chatID, userID int64) (*PollDraftDTO, error) {
+	var (
+		d       PollDraftDTO
+		intent  []byte
+		missing string
+	)
+	err := s.DB.QueryRow(ctx, `SELECT chat_id, user_id, intent, missing, question_message_id, expires_at FROM poll_drafts WHERE chat_id=$1 AND user_id=$2 AND expires_at > NOW()`, chatID, userID).
+		Scan(&d.ChatID, &d.UserID, &intent, &missing, &d.QuestionMessageID, &d.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(intent, &d.Intent); err != nil {
+		return nil, err
+	}
+	d.Missing = llm.MissingField(missing)
+	return &d, nil
+}
+
+// DeleteDraft removes the user's draft in the chat, if any.
+func (s *Repository) DeleteDraft(ctx context.Context, chatID, userID int64) error {
+	_, err := s.DB.Exec(ctx, `DELETE FROM poll_drafts WHERE chat_id=$1 AND user_id=$2`, chatID, userID)
+	return err
+}
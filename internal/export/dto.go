@@ -0,0 +1,43 @@
+package export
+
+import (
+	"time"
+
+	"github.com/nikitkaralius/lineup/internal/polls"
+	"github.com/nikitkaralius/lineup/internal/voters"
+)
+
+// PollRecord is everything exported about a single poll.
+type PollRecord struct {
+	Poll  polls.TelegramPollDTO
+	Votes []voters.VoteDTO
+	Queue []int64 // final queue order, empty if the poll has no lineup
+}
+
+// SessionDTO is a scheduled session a user takes part in, for the calendar feed.
+type SessionDTO struct {
+	PollID         string
+	ChatID         int64
+	Topic          string
+	Status         string
+	SessionStartAt time.Time
+	SlotDuration   time.Duration
+	QueueLength    int
+	Position       int // 1-based queue position, 0 while the poll is still running
+}
+
+// SlotStart returns the estimated start of the user's slot, or the session start without a position.
+func (s SessionDTO) SlotStart() time.Time {
+	if s.Position == 0 {
+		return s.SessionStartAt
+	}
+	return s.SessionStartAt.Add(time.Duration(s.Position-1) * s.SlotDuration)
+}
+
+// SlotEnd returns the estimated end of the user's slot, or the end of the whole session without a position.
+func (s SessionDTO) SlotEnd() time.Time {
+	if s.Position == 0 {
+		return s.SessionStartAt.Add(time.Duration(max(s.QueueLength, 1)) * s.SlotDuration)
+	}
+	return s.SlotStart().Add(s.SlotDuration)
+}
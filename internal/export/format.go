@@ -0,0 +1,153 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nikitkaralius/lineup/internal/voters"
+)
+
+// Export formats.
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// csvHeader lists the CSV columns. There is one row per voter or queued person of each poll,
+// and a row with empty person columns for polls nobody answered.
+var csvHeader = []string{
+	"poll_id", "topic", "status", "started_at", "ends_at",
+	"user_id", "username", "name", "answers", "coming", "voted_at",
+	"queue_position", "slot_start",
+}
+
+// WriteCSV writes records as CSV with times in loc.
+func WriteCSV(w io.Writer, records []PollRecord, loc *time.Location) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, r := range records {
+		people := newPeople(r)
+		if len(people) == 0 {
+			people = []person{{}}
+		}
+		for _, p := range people {
+			row := []string{
+				r.Poll.PollID, r.Poll.Topic, r.Poll.Status, formatTime(r.Poll.StartedAt, loc), formatTime(r.Poll.EndsAt, loc),
+				"", p.Username, p.Name, strings.Join(p.Answers, "; "), "", formatTime(p.VotedAt, loc),
+				"", formatTime(p.SlotStart, loc),
+			}
+			if p.UserID != 0 {
+				row[5] = strconv.FormatInt(p.UserID, 10)
+				row[9] = strconv.FormatBool(p.Coming)
+			}
+			if p.Position > 0 {
+				row[11] = strconv.Itoa(p.Position)
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type pollJSON struct {
+	PollID            string     `json:"poll_id"`
+	ChatID            int64      `json:"chat_id"`
+	Topic             string     `json:"topic"`
+	Status            string     `json:"status"`
+	StartedAt         time.Time  `json:"started_at"`
+	EndsAt            time.Time  `json:"ends_at"`
+	Answers           []string   `json:"answers"`
+	ComingAnswerIndex int        `json:"coming_answer_index"`
+	SessionStartAt    *time.Time `json:"session_start_at,omitempty"`
+	SlotSeconds       int        `json:"slot_seconds,omitempty"`
+	People            []person   `json:"people"`
+}
+
+// WriteJSON writes records as an indented JSON array of polls with everyone who voted or was queued.
+func WriteJSON(w io.Writer, records []PollRecord) error {
+	res := make([]pollJSON, len(records))
+	for i, r := range records {
+		res[i] = pollJSON{
+			PollID:            r.Poll.PollID,
+			ChatID:            r.Poll.ChatID,
+			Topic:             r.Poll.Topic,
+			Status:            r.Poll.Status,
+			StartedAt:         r.Poll.StartedAt,
+			EndsAt:            r.Poll.EndsAt,
+			Answers:           r.Poll.Answers,
+			ComingAnswerIndex: r.Poll.ComingAnswerIndex,
+			SlotSeconds:       int(r.Poll.SlotDuration / time.Second),
+			People:            newPeople(r),
+		}
+		if !r.Poll.SessionStartAt.IsZero() {
+			res[i].SessionStartAt = &r.Poll.SessionStartAt
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
+
+// person is a voter or queued person of a poll.
+type person struct {
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Answers   []string  `json:"answers"`
+	Coming    bool      `json:"coming"`
+	VotedAt   time.Time `json:"voted_at,omitzero"`
+	Position  int       `json:"queue_position,omitempty"` // 1-based, 0 if not in the queue
+	SlotStart time.Time `json:"slot_start,omitzero"`
+}
+
+// newPeople lists voters in vote order followed by queued people who never voted.
+func newPeople(r PollRecord) []person {
+	people := make([]person, 0, len(r.Votes))
+	for _, v := range r.Votes {
+		people = append(people, newVoter(r, v))
+	}
+	for _, userID := range r.Queue {
+		if !slices.ContainsFunc(r.Votes, func(v voters.VoteDTO) bool { return v.UserID == userID }) {
+			people = append(people, withPosition(r, person{UserID: userID, Answers: []string{}}))
+		}
+	}
+	return people
+}
+
+func newVoter(r PollRecord, v voters.VoteDTO) person {
+	p := person{UserID: v.UserID, Username: v.Username, Name: v.Name, Answers: []string{}, VotedAt: v.UpdatedAt}
+	for _, id := range v.OptionIDs {
+		if id >= 0 && id < len(r.Poll.Answers) {
+			p.Answers = append(p.Answers, r.Poll.Answers[id])
+		}
+		if id == r.Poll.ComingAnswerIndex {
+			p.Coming = true
+		}
+	}
+	return withPosition(r, p)
+}
+
+func withPosition(r PollRecord, p person) person {
+	p.Position = slices.Index(r.Queue, p.UserID) + 1
+	if p.Position > 0 && !r.Poll.SessionStartAt.IsZero() && r.Poll.SlotDuration > 0 {
+		p.SlotStart = r.Poll.SessionStartAt.Add(time.Duration(p.Position-1) * r.Poll.SlotDuration)
+	}
+	return p
+}
+
+func formatTime(t time.Time, loc *time.Location) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(loc).Format(time.RFC3339)
+}
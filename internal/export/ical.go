@@ -0,0 +1,72 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const icalTime = "20060102T150405Z"
+
+// WriteICal writes sessions as an iCalendar (RFC 5545) feed. Queued sessions become
+// events for the user's estimated slot; sessions of running polls cover the whole session.
+func WriteICal(w io.Writer, sessions []SessionDTO, name string, now time.Time) error {
+	bw := bufio.NewWriter(w)
+	line := func(s string) {
+		writeFolded(bw, s)
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//lineup//lineup bot//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + escapeText(name))
+	for _, s := range sessions {
+		summary, _, _ := strings.Cut(s.Topic, "\n")
+		description := s.Topic
+		if s.Position > 0 {
+			summary = fmt.Sprintf("#%d %s", s.Position, summary)
+			description = fmt.Sprintf("%s\n\n#%d / %d", s.Topic, s.Position, s.QueueLength)
+		}
+
+		line("BEGIN:VEVENT")
+		line("UID:" + s.PollID + "@lineup")
+		line("DTSTAMP:" + now.UTC().Format(icalTime))
+		line("DTSTART:" + s.SlotStart().UTC().Format(icalTime))
+		line("DTEND:" + s.SlotEnd().UTC().Format(icalTime))
+		line("SUMMARY:" + escapeText(summary))
+		line("DESCRIPTION:" + escapeText(description))
+		if s.Position == 0 {
+			line("STATUS:TENTATIVE")
+		} else {
+			line("STATUS:CONFIRMED")
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return bw.Flush()
+}
+
+// escapeText escapes an iCalendar TEXT value.
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// writeFolded writes a content line, folding it at 75 octets without splitting UTF-8 sequences.
+func writeFolded(w *bufio.Writer, s string) {
+	const limit = 75
+	n := 0
+	for _, r := range s {
+		size := len(string(r))
+		if n+size > limit {
+			w.WriteString("\r\n ")
+			n = 1
+		}
+		w.WriteRune(r)
+		n += size
+	}
+	w.WriteString("\r\n")
+}
@@ -0,0 +1,51 @@
+package export
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repository struct {
+	DB *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{DB: db}
+}
+
+// GetUserSessions returns scheduled sessions starting after since that the user is queued for,
+// or voted to come to in a still running poll, across all chats, earliest first.
+func (s *Repository) GetUserSessions(ctx context.Context, userID int64, since time.Time) ([]SessionDTO, error) {
+	rows, err := s.DB.Query(ctx, `SELECT p.poll_id, p.chat_id, p.topic, p.status, p.session_start_at, p.slot_seconds,
+		COALESCE(cardinality(r.queue_user_ids), 0),
+		COALESCE(array_position(r.queue_user_ids, $1), 0)
+	FROM polls p
+	LEFT JOIN poll_results r ON r.poll_id = p.poll_id
+	WHERE p.session_start_at IS NOT NULL AND p.slot_seconds > 0 AND p.session_start_at >= $2
+		AND (
+			(p.status = 'processed' AND $1 = ANY(r.queue_user_ids))
+			OR (p.status = 'active' AND EXISTS (
+				SELECT 1 FROM poll_votes v WHERE v.poll_id = p.poll_id AND v.user_id = $1 AND COALESCE(p.coming_answer_index, 0) = ANY(v.option_ids)
+			))
+		)
+	ORDER BY p.session_start_at`, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := []SessionDTO{}
+	for rows.Next() {
+		var (
+			d           SessionDTO
+			slotSeconds int
+		)
+		if err := rows.Scan(&d.PollID, &d.ChatID, &d.Topic, &d.Status, &d.SessionStartAt, &slotSeconds, &d.QueueLength, &d.Position); err != nil {
+			return nil, err
+		}
+		d.SlotDuration = time.Duration(slotSeconds) * time.Second
+		res = append(res, d)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,45 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/nikitkaralius/lineup/internal/polls"
+	"github.com/nikitkaralius/lineup/internal/voters"
+)
+
+// Service collects poll data for export.
+type Service struct {
+	pollsRepo  polls.Repository
+	votersRepo voters.Repository
+}
+
+// NewService creates a new export service.
+func NewService(pollsRepo polls.Repository, votersRepo voters.Repository) *Service {
+	return &Service{pollsRepo: pollsRepo, votersRepo: votersRepo}
+}
+
+// Collect returns the chat's polls started in [from, to) with their votes and final queues.
+func (s *Service) Collect(ctx context.Context, chatID int64, from, to time.Time) ([]PollRecord, error) {
+	ps, err := s.pollsRepo.ListPollsByChatBetween(ctx, chatID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list polls: %w", err)
+	}
+
+	records := make([]PollRecord, len(ps))
+	for i, p := range ps {
+		votes, err := s.votersRepo.GetVotes(ctx, p.PollID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get votes of poll %s: %w", p.PollID, err)
+		}
+		queue, err := s.votersRepo.GetQueueUserIDs(ctx, p.PollID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("failed to get queue of poll %s: %w", p.PollID, err)
+		}
+		records[i] = PollRecord{Poll: p, Votes: votes, Queue: queue}
+	}
+	return records, nil
+}
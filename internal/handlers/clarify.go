@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nikitkaralius/lineup/internal/chats"
+	"github.com/nikitkaralius/lineup/internal/drafts"
+	"github.com/nikitkaralius/lineup/internal/i18n"
+	"github.com/nikitkaralius/lineup/internal/llm"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/messenger"
+	"github.com/nikitkaralius/lineup/internal/polls"
+	"github.com/nikitkaralius/lineup/internal/utils"
+)
+
+// askClarification asks the author of msg for the field missing in the poll intent
+// and remembers the partial intent until the answer arrives. Messages without an author,
+// like channel posts, cannot be answered, so they only get told what is missing.
+func askClarification(ctx context.Context, bot messenger.Messenger, draftsRepo *drafts.Repository, lang i18n.Lang, msg *tgbotapi.Message, incomplete *llm.IncompleteIntentError) {
+	if msg.From == nil {
+		reply(ctx, bot, msg, pollIntentErrorText(lang, incomplete))
+		return
+	}
+
+	question := messenger.Message{
+		ChatID:           msg.Chat.ID,
+		Text:             clarificationQuestion(lang, incomplete),
+		ReplyToMessageID: msg.MessageID,
+		ForceReply:       true,
+	}
+	sent, err := bot.SendMessage(ctx, question)
+	if err != nil {
+		slog.ErrorContext(ctx, "send clarification question failed", logging.Err(err))
+		return
+	}
+
+	d := &drafts.PollDraftDTO{
+		ChatID:            msg.Chat.ID,
+		UserID:            msg.From.ID,
+		Intent:            incomplete.Intent,
+		Missing:           incomplete.Missing,
+		QuestionMessageID: sent.MessageID,
+		ExpiresAt:         time.Now().UTC().Add(drafts.TTL),
+	}
+	if err := draftsRepo.SaveDraft(ctx, d); err != nil {
+		slog.ErrorContext(ctx, "save poll draft failed", logging.Err(err))
+	}
+}
+
+// handleClarification merges the user's answer into the draft and creates the poll
+// once nothing is missing anymore.
+func handleClarification(
+	ctx context.Context,
+	bot messenger.Messenger,
+	pollsManager *polls.Manager,
+	draftsRepo *drafts.Repository,
+	llmClient *llm.Client,
+	settings *chats.ChatSettingsDTO,
+	draft *drafts.PollDraftDTO,
+	msg *tgbotapi.Message,
+) {
+	lang := settings.Language
+	intent, ok := mergeClarification(draft.Intent, draft.Missing, msg.Text)
+	if !ok {
+		var err error
+		intent, err = llmClient.ParseClarification(ctx, draft.Intent, draft.Missing, msg.Text, utils.LoadLocation(settings.Timezone))
+		if err != nil {
+			slog.WarnContext(ctx, "LLM clarification parsing failed", logging.Err(err))
+			intent = &draft.Intent
+		}
+	}
+
+	err := llm.ValidatePollIntent(intent)
+	var incomplete *llm.IncompleteIntentError
+	if errors.As(err, &incomplete) {
+		// Still missing something: ask again, the new question replaces the draft
+		askClarification(ctx, bot, draftsRepo, lang, msg, incomplete)
+		return
+	}
+
+	if err := draftsRepo.DeleteDraft(ctx, draft.ChatID, draft.UserID); err != nil {
+		slog.ErrorContext(ctx, "delete poll draft failed", logging.Err(err))
+	}
+
+	if err != nil {
+		reply(ctx, bot, msg, pollIntentErrorText(lang, err))
+		return
+	}
+
+	createPoll(ctx, bot, pollsManager, settings, msg, intent)
+}
+
+// clarificationQuestion builds the question asking for the missing field.
+func clarificationQuestion(lang i18n.Lang, incomplete *llm.IncompleteIntentError) string {
+	switch incomplete.Missing {
+	case llm.MissingComingAnswer:
+		b := strings.Builder{}
+		for i, answer := range incomplete.Intent.Answers {
+			b.WriteString(fmt.Sprintf("%d. %s\n", i+1, answer))
+		}
+		return i18n.T(lang, i18n.AskComingAnswer, b.String())
+	default:
+		return i18n.T(lang, i18n.AskDuration, incomplete.Intent.Topic)
+	}
+}
+
+// mergeClarification handles answers that need no LLM: a Go duration for the
+// missing duration, or an answer number or text for the missing coming answer.
+func mergeClarification(intent llm.PollIntent, missing llm.MissingField, text string) (*llm.PollIntent, bool) {
+	text = strings.TrimSpace(text)
+	switch missing {
+	case llm.MissingDuration:
+		if _, err := time.ParseDuration(text); err == nil {
+			intent.Duration = text
+			return &intent, true
+		}
+	case llm.MissingComingAnswer:
+		if n, err := strconv.Atoi(text); err == nil && n >= 1 && n <= len(intent.Answers) {
+			intent.ComingAnswerIndex = n - 1
+			return &intent, true
+		}
+		for i, answer := range intent.Answers {
+			if strings.EqualFold(strings.TrimSpace(answer), text) {
+				intent.ComingAnswerIndex = i
+				return &intent, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nikitkaralius/lineup/internal/i18n"
+	"github.com/nikitkaralius/lineup/internal/llm"
+	"github.com/nikitkaralius/lineup/internal/messenger"
+)
+
+func TestMergeClarification(t *testing.T) {
+	intent := llm.PollIntent{Topic: "Lab", Answers: []string{"Coming", " Not coming "}, ComingAnswerIndex: -1}
+	tests := []struct {
+		name      string
+		missing   llm.MissingField
+		text      string
+		ok        bool
+		duration  string
+		comingIdx int
+	}{
+		{"duration", llm.MissingDuration, " 90m ", true, "90m", -1},
+		{"compound duration", llm.MissingDuration, "1h30m", true, "1h30m", -1},
+		{"duration in words", llm.MissingDuration, "until 13:48", false, "", 0},
+		{"duration without unit", llm.MissingDuration, "30", false, "", 0},
+		{"answer number", llm.MissingComingAnswer, "2", true, "", 1},
+		{"answer text", llm.MissingComingAnswer, "not COMING", true, "", 1},
+		{"answer number out of range", llm.MissingComingAnswer, "3", false, "", 0},
+		{"answer number zero", llm.MissingComingAnswer, "0", false, "", 0},
+		{"unknown answer", llm.MissingComingAnswer, "maybe", false, "", 0},
+		{"unknown field", llm.MissingField("topic"), "Lab", false, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := mergeClarification(intent, tt.missing, tt.text)
+			if ok != tt.ok {
+				t.Fatalf("mergeClarification(%q) ok = %v, want %v", tt.text, ok, tt.ok)
+			}
+			if !ok {
+				if got != nil {
+					t.Errorf("mergeClarification(%q) = %+v, want nil", tt.text, got)
+				}
+				return
+			}
+			if got.Duration != tt.duration || got.ComingAnswerIndex != tt.comingIdx || got.Topic != intent.Topic {
+				t.Errorf("mergeClarification(%q) = %+v, want duration %q and coming answer %d", tt.text, got, tt.duration, tt.comingIdx)
+			}
+		})
+	}
+	if intent.Duration != "" || intent.ComingAnswerIndex != -1 {
+		t.Errorf("intent changed to %+v, want it left as is", intent)
+	}
+}
+
+func TestAskClarificationWithoutAuthor(t *testing.T) {
+	bot := messenger.NewFake("bot")
+	// A channel post has no author to ask, nor a draft to remember
+	msg := &tgbotapi.Message{MessageID: 7, Chat: &tgbotapi.Chat{ID: -100, Type: "supergroup"}, SenderChat: &tgbotapi.Chat{ID: -200, Type: "channel"}}
+	incomplete := &llm.IncompleteIntentError{Intent: llm.PollIntent{Topic: "Lab"}, Missing: llm.MissingDuration}
+
+	askClarification(context.Background(), bot, nil, i18n.English, msg, incomplete)
+
+	if len(bot.Messages) != 1 || bot.Messages[0].ForceReply || bot.Messages[0].ReplyToMessageID != msg.MessageID {
+		t.Errorf("messages = %+v, want a plain reply saying what is missing", bot.Messages)
+	}
+}
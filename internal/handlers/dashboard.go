@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nikitkaralius/lineup/internal/auth"
+	"github.com/nikitkaralius/lineup/internal/i18n"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/messenger"
+)
+
+// handleDashboardCommand sends the author of "/dashboard" a login link to the chat's
+// dashboard in a private message, so that only chat members get access.
+func handleDashboardCommand(ctx context.Context, bot messenger.Messenger, links *auth.Links, lang i18n.Lang, msg *tgbotapi.Message) {
+	if links == nil {
+		reply(ctx, bot, msg, i18n.T(lang, i18n.DashboardDisabled))
+		return
+	}
+	if msg.From == nil {
+		return
+	}
+
+	url, err := links.LoginURL(msg.From.ID, msg.Chat.ID, msg.Chat.Title)
+	if err != nil {
+		slog.ErrorContext(ctx, "build dashboard link failed", logging.Err(err))
+		return
+	}
+
+	// Bots can only message users who started a private chat with them
+	dm := messenger.Message{
+		ChatID:                msg.From.ID,
+		Text:                  i18n.T(lang, i18n.DashboardLink, msg.Chat.Title, url, int(auth.LoginTTL/time.Minute)),
+		DisableWebPagePreview: true,
+	}
+	if _, err := bot.SendMessage(ctx, dm); err != nil {
+		slog.WarnContext(ctx, "send dashboard link failed", logging.Err(err))
+		reply(ctx, bot, msg, i18n.T(lang, i18n.DashboardStartBot, bot.Username()))
+		return
+	}
+	reply(ctx, bot, msg, i18n.T(lang, i18n.DashboardLinkSent))
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nikitkaralius/lineup/internal/auth"
+	"github.com/nikitkaralius/lineup/internal/chats"
+	"github.com/nikitkaralius/lineup/internal/export"
+	"github.com/nikitkaralius/lineup/internal/i18n"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/messenger"
+	"github.com/nikitkaralius/lineup/internal/utils"
+)
+
+const (
+	exportDateLayout  = "2006-01-02"
+	defaultExportDays = 30
+)
+
+// handleExportCommand sends the chat's polls of a date range as a CSV or JSON file:
+// "/export [csv|json] [from] [to]" with inclusive dates in the chat timezone.
+func handleExportCommand(ctx context.Context, bot messenger.Messenger, exportService *export.Service, settings *chats.ChatSettingsDTO, msg *tgbotapi.Message) {
+	lang := settings.Language
+	loc := utils.LoadLocation(settings.Timezone)
+
+	format, from, to, err := parseExportArgs(msg.CommandArguments(), time.Now().In(loc), loc)
+	if err != nil {
+		reply(ctx, bot, msg, i18n.T(lang, i18n.ExportUsage))
+		return
+	}
+	fromText, toText := from.Format(exportDateLayout), to.AddDate(0, 0, -1).Format(exportDateLayout)
+
+	records, err := exportService.Collect(ctx, msg.Chat.ID, from, to)
+	if err != nil {
+		slog.ErrorContext(ctx, "export polls failed", logging.Err(err))
+		reply(ctx, bot, msg, i18n.T(lang, i18n.ExportError, err))
+		return
+	}
+	if len(records) == 0 {
+		reply(ctx, bot, msg, i18n.T(lang, i18n.ExportEmpty, fromText, toText))
+		return
+	}
+
+	buf := bytes.Buffer{}
+	if format == export.FormatJSON {
+		err = export.WriteJSON(&buf, records)
+	} else {
+		err = export.WriteCSV(&buf, records, loc)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "write export failed", slog.String("format", format), logging.Err(err))
+		reply(ctx, bot, msg, i18n.T(lang, i18n.ExportError, err))
+		return
+	}
+
+	doc := messenger.Document{
+		ChatID:           msg.Chat.ID,
+		FileName:         fmt.Sprintf("lineup_%s_%s.%s", fromText, toText, format),
+		Data:             buf.Bytes(),
+		Caption:          i18n.T(lang, i18n.ExportCaption, len(records), fromText, toText),
+		ReplyToMessageID: msg.MessageID,
+	}
+	if _, err := bot.SendDocument(ctx, doc); err != nil {
+		slog.ErrorContext(ctx, "send export failed", logging.Err(err))
+	}
+}
+
+// parseExportArgs parses "[csv|json] [from] [to]" in any order of format and dates.
+// The returned range is [from, to) with to at the start of the day after the last date.
+func parseExportArgs(args string, now time.Time, loc *time.Location) (format string, from, to time.Time, err error) {
+	format = export.FormatCSV
+	var dates []time.Time
+	for _, f := range strings.Fields(args) {
+		switch strings.ToLower(f) {
+		case export.FormatCSV, export.FormatJSON:
+			format = strings.ToLower(f)
+			continue
+		}
+		d, err := time.ParseInLocation(exportDateLayout, f, loc)
+		if err != nil {
+			return "", time.Time{}, time.Time{}, err
+		}
+		dates = append(dates, d)
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	switch len(dates) {
+	case 0:
+		from, to = today.AddDate(0, 0, -defaultExportDays+1), today
+	case 1:
+		from, to = dates[0], today
+	case 2:
+		from, to = dates[0], dates[1]
+	default:
+		return "", time.Time{}, time.Time{}, fmt.Errorf("too many dates")
+	}
+	if to.Before(from) {
+		return "", time.Time{}, time.Time{}, fmt.Errorf("range ends before it starts")
+	}
+	return format, from, to.AddDate(0, 0, 1), nil
+}
+
+// handleCalendarCommand sends the author of "/calendar" a link to their personal iCal feed
+// of upcoming sessions in a private message.
+func handleCalendarCommand(ctx context.Context, bot messenger.Messenger, links *auth.Links, lang i18n.Lang, msg *tgbotapi.Message) {
+	if links == nil {
+		reply(ctx, bot, msg, i18n.T(lang, i18n.CalendarDisabled))
+		return
+	}
+	if msg.From == nil {
+		return
+	}
+
+	url, err := links.CalendarURL(msg.From.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "build calendar link failed", logging.Err(err))
+		return
+	}
+
+	dm := messenger.Message{
+		ChatID:                msg.From.ID,
+		Text:                  i18n.T(lang, i18n.CalendarLink, url, int(auth.CalendarTTL/(24*time.Hour))),
+		DisableWebPagePreview: true,
+	}
+	if _, err := bot.SendMessage(ctx, dm); err != nil {
+		slog.WarnContext(ctx, "send calendar link failed", logging.Err(err))
+		reply(ctx, bot, msg, i18n.T(lang, i18n.DashboardStartBot, bot.Username()))
+		return
+	}
+	reply(ctx, bot, msg, i18n.T(lang, i18n.CalendarLinkSent))
+}
@@ -2,48 +2,122 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/nikitkaralius/lineup/internal/auth"
+	"github.com/nikitkaralius/lineup/internal/chats"
+	"github.com/nikitkaralius/lineup/internal/drafts"
+	"github.com/nikitkaralius/lineup/internal/export"
+	"github.com/nikitkaralius/lineup/internal/i18n"
 	"github.com/nikitkaralius/lineup/internal/llm"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/messenger"
 	"github.com/nikitkaralius/lineup/internal/polls"
 	"github.com/nikitkaralius/lineup/internal/queue"
+	"github.com/nikitkaralius/lineup/internal/tracing"
 	"github.com/nikitkaralius/lineup/internal/utils"
+	"github.com/nikitkaralius/lineup/internal/webhooks"
 )
 
-// formatPollTopic formats the poll topic with end time in the specified format.
-func formatPollTopic(topic string, endTimeMoscow string) string {
-	return fmt.Sprintf("📋 Тема: %s\n⏰ Завершится: %s", topic, endTimeMoscow)
+// reply sends text to the chat of msg as a reply to it.
+func reply(ctx context.Context, bot messenger.Messenger, msg *tgbotapi.Message, text string) {
+	if _, err := bot.SendMessage(ctx, messenger.Message{ChatID: msg.Chat.ID, Text: text, ReplyToMessageID: msg.MessageID}); err != nil {
+		slog.ErrorContext(ctx, "send reply failed", logging.Err(err))
+	}
 }
 
-func HandleMessage(
-	ctx context.Context,
-	bot *tgbotapi.BotAPI,
-	pollsRepo *polls.Repository,
-	msg *tgbotapi.Message,
-	botUsername string,
-	pollsService polls.Service,
-	llmClient *llm.Client,
-	queueService *queue.Service,
-) {
+// Deps are what HandleMessage needs. The service creates them once.
+// Dashboard links are nil unless the dashboard is enabled.
+type Deps struct {
+	Bot            messenger.Messenger
+	BotUsername    string
+	Polls          polls.Repository
+	Drafts         *drafts.Repository
+	Chats          *chats.Repository
+	Webhooks       *webhooks.Repository
+	PollsManager   *polls.Manager
+	LLM            *llm.Client
+	Queue          *queue.Service
+	DashboardLinks *auth.Links
+	Export         *export.Service
+}
+
+// HandleMessage handles a group message posted in the forum topic threadID, 0 outside topics.
+func HandleMessage(ctx context.Context, deps *Deps, msg *tgbotapi.Message, threadID int) {
 	if msg.Chat == nil || (msg.Chat.Type != "group" && msg.Chat.Type != "supergroup") {
 		return
 	}
+	ctx = logging.With(ctx, logging.ChatID(msg.Chat.ID))
+	// Replies, polls and lineups go to the forum topic msg was posted in
+	bot := messenger.InThread(deps.Bot, msg.Chat.ID, threadID)
+	ctx, span := tracing.Start(ctx, "HandleMessage", tracing.ChatID.Int64(msg.Chat.ID))
+	defer span.End()
+	if msg.From != nil {
+		ctx = logging.With(ctx, logging.UserID(msg.From.ID))
+		span.SetAttributes(tracing.UserID.Int64(msg.From.ID))
+	}
 	text := msg.Text
 	if text == "" {
 		return
 	}
 
+	settings := deps.Chats.GetSettingsOrDefault(ctx, msg.Chat.ID, threadID)
+	lang := settings.Language
+
 	// Check if this is a reply to a results message (queue join/leave)
 	if msg.ReplyToMessage != nil {
 		// Find poll by results_message_id
-		poll, err := pollsRepo.FindPollByResultsMessageID(ctx, msg.ReplyToMessage.MessageID)
+		poll, err := deps.Polls.FindPollByResultsMessageID(ctx, msg.Chat.ID, msg.ReplyToMessage.MessageID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			slog.ErrorContext(ctx, "find poll by results message failed", logging.Err(err))
+		}
 		if err == nil && poll != nil {
 			// This is a reply to a results message - handle queue operation
-			handleQueueOperation(ctx, bot, queueService, poll.PollID, msg)
+			handleQueueOperation(ctx, bot, deps.Queue, lang, poll.PollID, msg)
+			return
+		}
+
+		// Check if this is an answer to a clarifying question about a poll draft
+		if msg.From != nil {
+			draft, err := deps.Drafts.FindDraft(ctx, msg.Chat.ID, msg.From.ID)
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				slog.ErrorContext(ctx, "find poll draft failed", logging.Err(err))
+			}
+			if err == nil && draft.QuestionMessageID == msg.ReplyToMessage.MessageID {
+				handleClarification(ctx, bot, deps.PollsManager, deps.Drafts, deps.LLM, settings, draft, msg)
+				return
+			}
+		}
+	}
+
+	if msg.IsCommand() {
+		switch msg.Command() {
+		case "language":
+			handleLanguageCommand(ctx, bot, deps.Chats, settings, msg)
+			return
+		case "template":
+			handleTemplateCommand(ctx, bot, deps.Chats, settings, msg)
+			return
+		case "timezone":
+			handleTimezoneCommand(ctx, bot, deps.Chats, settings, msg)
+			return
+		case "dashboard":
+			handleDashboardCommand(ctx, bot, deps.DashboardLinks, lang, msg)
+			return
+		case "export":
+			handleExportCommand(ctx, bot, deps.Export, settings, msg)
+			return
+		case "calendar":
+			handleCalendarCommand(ctx, bot, deps.DashboardLinks, lang, msg)
+			return
+		case "webhook":
+			handleWebhookCommand(ctx, bot, deps.Webhooks, lang, msg)
 			return
 		}
 	}
@@ -57,7 +131,7 @@ func HandleMessage(
 		for _, e := range msg.Entities {
 			if e.Type == "mention" {
 				mention := msg.Text[e.Offset : e.Offset+e.Length]
-				if mention == "@"+botUsername {
+				if mention == "@"+deps.BotUsername {
 					triggered = true
 					// Strip mention from text
 					text = msg.Text[e.Offset+e.Length:]
@@ -71,38 +145,74 @@ func HandleMessage(
 	}
 
 	// Try LLM parsing first
-	intent, err := llmClient.ParsePollIntent(ctx, text)
+	intent, err := deps.LLM.ParsePollIntent(ctx, text, utils.LoadLocation(settings.Timezone))
+	var incomplete *llm.IncompleteIntentError
+	if errors.As(err, &incomplete) && incomplete.Missing == llm.MissingDuration && settings.Duration > 0 {
+		// No need to ask when the chat has a default duration
+		intent = &incomplete.Intent
+		intent.Duration = settings.Duration.String()
+		err = llm.ValidatePollIntent(intent)
+	}
+	if errors.As(err, &incomplete) {
+		askClarification(ctx, bot, deps.Drafts, lang, msg, incomplete)
+		return
+	}
 	if err != nil {
 		// Fallback to simple parsing
-		log.Printf("LLM parsing failed, using fallback: %v", err)
+		slog.WarnContext(ctx, "LLM parsing failed, using fallback", logging.Err(err))
 		topic, dur, err2 := parseTopicAndDuration(text)
 		if err2 != nil {
 			// Send LLM error message to user
-			reply := tgbotapi.NewMessage(msg.Chat.ID, err.Error())
-			reply.ReplyToMessageID = msg.MessageID
-			bot.Send(reply)
+			reply(ctx, bot, msg, pollIntentErrorText(lang, err))
 			return
 		}
 		// Use fallback values
 		intent = &llm.PollIntent{
 			Topic:             topic,
 			Duration:          dur.String(),
-			Answers:           polls.DefaultPollAnswers,
 			ComingAnswerIndex: polls.DefaultComingAnswerIndex,
 		}
 	}
 
+	createPoll(ctx, bot, deps.PollsManager, settings, msg, intent)
+}
+
+// pollIntentErrorText explains to the user why the poll request was not understood.
+func pollIntentErrorText(lang i18n.Lang, err error) string {
+	var explanation *llm.ExplanationError
+	switch {
+	case errors.Is(err, llm.ErrMissingTopic):
+		return i18n.T(lang, i18n.ErrMissingTopic)
+	case errors.As(err, &explanation):
+		return i18n.T(lang, i18n.ErrPollExplained, explanation.Message)
+	default:
+		return i18n.T(lang, i18n.ErrPollUnparsed, err)
+	}
+}
+
+// createPoll sends the poll described by intent to the chat of msg, stores it and
+// schedules its finish. Errors are reported as replies to msg.
+func createPoll(
+	ctx context.Context,
+	bot messenger.Messenger,
+	pollsManager *polls.Manager,
+	settings *chats.ChatSettingsDTO,
+	msg *tgbotapi.Message,
+	intent *llm.PollIntent,
+) {
+	lang := settings.Language
+	loc := utils.LoadLocation(settings.Timezone)
+
 	// Parse end time or duration
 	var endsAtUTC time.Time
 	var dur time.Duration
+	var err error
 
 	if intent.EndTime != "" {
 		// Parse end time
-		endsAtUTC, err = utils.ParseEndTimeInMoscow(intent.EndTime)
+		endsAtUTC, err = utils.ParseTimeIn(intent.EndTime, loc)
 		if err != nil {
-			reply := tgbotapi.NewMessage(msg.Chat.ID, fmt.Sprintf("Ошибка обработки времени окончания: %v", err))
-			reply.ReplyToMessageID = msg.MessageID
-			bot.Send(reply)
+			reply(ctx, bot, msg, i18n.T(lang, i18n.ErrEndTime, err))
 			return
 		}
 		dur = endsAtUTC.Sub(time.Now().UTC())
@@ -110,47 +220,51 @@ func HandleMessage(
 		// Parse duration
 		dur, endsAtUTC, err = utils.ParseDurationInMoscow(intent.Duration)
 		if err != nil {
-			reply := tgbotapi.NewMessage(msg.Chat.ID, fmt.Sprintf("Ошибка обработки длительности: %v", err))
-			reply.ReplyToMessageID = msg.MessageID
-			bot.Send(reply)
+			reply(ctx, bot, msg, i18n.T(lang, i18n.ErrDuration, err))
 			return
 		}
+	} else if settings.Duration > 0 {
+		dur, endsAtUTC = settings.Duration, time.Now().UTC().Add(settings.Duration)
 	} else {
-		reply := tgbotapi.NewMessage(msg.Chat.ID, "Не указана длительность или время окончания опроса")
-		reply.ReplyToMessageID = msg.MessageID
-		bot.Send(reply)
+		reply(ctx, bot, msg, i18n.T(lang, i18n.ErrNoDuration))
 		return
 	}
 
-	// Format end time in Moscow timezone for display
-	endTimeMoscow := utils.FormatMoscowTimeForPoll(endsAtUTC)
+	// Parse optional session start and time per person for time slots
+	var sessionStartAt time.Time
+	var slot time.Duration
+	if intent.SessionStart != "" || intent.SlotDuration != "" {
+		if intent.SessionStart == "" || intent.SlotDuration == "" {
+			reply(ctx, bot, msg, i18n.T(lang, i18n.ErrHalfSchedule))
+			return
+		}
+		sessionStartAt, err = utils.ParseTimeIn(intent.SessionStart, loc)
+		if err != nil {
+			reply(ctx, bot, msg, i18n.T(lang, i18n.ErrSessionStart, err))
+			return
+		}
+		slot, err = time.ParseDuration(intent.SlotDuration)
+		if err == nil && slot < time.Minute {
+			err = fmt.Errorf("time per person must be at least a minute, got %s", slot)
+		}
+		if err != nil {
+			reply(ctx, bot, msg, i18n.T(lang, i18n.ErrSlotDuration, err))
+			return
+		}
+	}
 
 	// Format topic with end time
-	topicWithEndTime := formatPollTopic(intent.Topic, endTimeMoscow)
+	topicWithEndTime := polls.FormatTopic(lang, loc, intent.Topic, endsAtUTC, sessionStartAt, slot)
 
 	// Create poll with custom answers if specified
-	answers := intent.Answers
+	answers, comingIndex := intent.Answers, intent.ComingAnswerIndex
 	if len(answers) == 0 {
-		answers = polls.DefaultPollAnswers
-	}
-
-	pollCfg := tgbotapi.NewPoll(msg.Chat.ID, topicWithEndTime, answers...)
-	pollCfg.IsAnonymous = false
-	pollCfg.AllowsMultipleAnswers = false
-	sent, err := bot.Send(pollCfg)
-	if err != nil {
-		log.Printf("send poll error: %v", err)
-		return
-	}
-	if sent.Poll == nil {
-		log.Printf("poll send returned no poll")
-		return
+		answers, comingIndex = polls.ChatPollAnswers(settings)
 	}
 
 	p := &polls.TelegramPollDTO{
-		PollID:          sent.Poll.ID,
 		ChatID:          msg.Chat.ID,
-		MessageID:       sent.MessageID,
+		MessageThreadID: settings.ThreadID,
 		Topic:           topicWithEndTime, // Store topic with end time
 		CreatorID:       msg.From.ID,
 		CreatorUsername: msg.From.UserName,
@@ -160,39 +274,40 @@ func HandleMessage(
 			}
 			return ""
 		}(),
-		StartedAt:         time.Now().UTC(),
 		Duration:          dur,
 		EndsAt:            endsAtUTC,
 		Answers:           answers,
-		ComingAnswerIndex: intent.ComingAnswerIndex,
-	}
-
-	if err := pollsRepo.InsertPoll(ctx, p); err != nil {
-		log.Printf("insert poll error: %v", err)
-		return
+		ComingAnswerIndex: comingIndex,
+		SessionStartAt:    sessionStartAt,
+		SlotDuration:      slot,
+		SourceMessageID:   msg.MessageID,
 	}
 
-	// Enqueue async job to finalize poll at EndsAt
-	if pollsService != nil {
-		args := polls.FinishPollArgs{PollID: p.PollID, ChatID: p.ChatID, MessageID: p.MessageID, Topic: p.Topic}
-		if err := pollsService.SchedulePollFinish(ctx, args, p.EndsAt); err != nil {
-			log.Printf("enqueue finish poll error: %v", err)
+	if err := pollsManager.CreatePoll(ctx, p); err != nil {
+		if errors.Is(err, polls.ErrDuplicatePoll) {
+			slog.InfoContext(ctx, "create poll skipped: message already created one", slog.Int("message_id", msg.MessageID))
+			return
 		}
+		slog.ErrorContext(ctx, "create poll failed", logging.Err(err))
 	}
 }
 
-func handleQueueOperation(ctx context.Context, bot *tgbotapi.BotAPI, queueService *queue.Service, pollID string, msg *tgbotapi.Message) {
+func handleQueueOperation(ctx context.Context, bot messenger.Messenger, queueService *queue.Service, lang i18n.Lang, pollID string, msg *tgbotapi.Message) {
 	text := msg.Text
 	if text == "" {
 		return
 	}
+	ctx = logging.With(ctx, logging.PollID(pollID))
 
 	// Parse intent using LLM via queue service
 	intent, err := queueService.ParseQueueIntent(ctx, text)
 	if err != nil {
-		reply := tgbotapi.NewMessage(msg.Chat.ID, fmt.Sprintf("Не могу понять ваш запрос: %v\n\nИспользуйте: 'хочу в очередь' или 'выхожу из очереди'", err))
-		reply.ReplyToMessageID = msg.MessageID
-		bot.Send(reply)
+		if errors.Is(err, llm.ErrUnknownQueueAction) {
+			reply(ctx, bot, msg, i18n.T(lang, i18n.QueueUnknownAction))
+			return
+		}
+		slog.WarnContext(ctx, "parse queue intent failed", logging.Err(err))
+		reply(ctx, bot, msg, i18n.T(lang, i18n.QueueUnparsed, err))
 		return
 	}
 
@@ -203,16 +318,18 @@ func handleQueueOperation(ctx context.Context, bot *tgbotapi.BotAPI, queueServic
 	case "leave":
 		errMsg = queueService.LeaveQueue(ctx, pollID, msg.From.ID)
 	default:
-		reply := tgbotapi.NewMessage(msg.Chat.ID, "Не могу определить действие. Используйте: 'хочу в очередь' или 'выхожу из очереди'")
-		reply.ReplyToMessageID = msg.MessageID
-		bot.Send(reply)
+		reply(ctx, bot, msg, i18n.T(lang, i18n.QueueUnknownAction))
 		return
 	}
 
-	if errMsg != nil {
-		reply := tgbotapi.NewMessage(msg.Chat.ID, fmt.Sprintf("Ошибка: %v", errMsg))
-		reply.ReplyToMessageID = msg.MessageID
-		bot.Send(reply)
+	switch {
+	case errors.Is(errMsg, queue.ErrAlreadyInQueue):
+		reply(ctx, bot, msg, i18n.T(lang, i18n.QueueError, i18n.T(lang, i18n.QueueAlreadyIn)))
+	case errors.Is(errMsg, queue.ErrNotInQueue):
+		reply(ctx, bot, msg, i18n.T(lang, i18n.QueueError, i18n.T(lang, i18n.QueueNotIn)))
+	case errMsg != nil:
+		slog.ErrorContext(ctx, "queue operation failed", slog.String("action", intent.Action), logging.Err(errMsg))
+		reply(ctx, bot, msg, i18n.T(lang, i18n.QueueError, errMsg))
 	}
 }
 
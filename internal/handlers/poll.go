@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/metrics"
+	"github.com/nikitkaralius/lineup/internal/polls"
+	"github.com/nikitkaralius/lineup/internal/tracing"
+	"github.com/nikitkaralius/lineup/internal/voters"
+)
+
+// HandlePoll reconciles the stored votes with a new poll state from Telegram. Telegram's
+// voters per answer are stored on the poll; if they differ from the stored votes, a poll
+// answer was missed and the difference is logged and counted.
+func HandlePoll(ctx context.Context, store voters.Repository, pollsRepo polls.Repository, p *tgbotapi.Poll) {
+	ctx = logging.With(ctx, logging.PollID(p.ID))
+	ctx, span := tracing.Start(ctx, "HandlePoll", tracing.PollID.String(p.ID))
+	defer span.End()
+
+	counts := make([]int, len(p.Options))
+	for i, o := range p.Options {
+		counts[i] = o.VoterCount
+	}
+	poll, err := pollsRepo.GetPoll(ctx, p.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Not one of ours, e.g. a poll forwarded to the bot
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "get poll failed", logging.Err(err))
+		return
+	}
+	if err := pollsRepo.UpdateVoterCounts(ctx, p.ID, counts); err != nil {
+		slog.ErrorContext(ctx, "save voter counts failed", logging.Err(err))
+		return
+	}
+
+	votes, err := store.GetVotes(ctx, p.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "get votes failed", logging.Err(err))
+		return
+	}
+	stored := make([]int, len(counts))
+	for _, v := range votes {
+		for _, id := range v.OptionIDs {
+			if id >= 0 && id < len(stored) {
+				stored[id]++
+			}
+		}
+	}
+	if !slices.Equal(stored, counts) {
+		slog.WarnContext(ctx, "stored votes differ from Telegram's counts",
+			logging.ChatID(poll.ChatID), slog.Any("telegram", counts), slog.Any("stored", stored))
+		metrics.VoteCountMismatches.Inc()
+	}
+}
@@ -2,12 +2,44 @@ package handlers
 
 import (
 	"context"
+	"log/slog"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/polls"
+	"github.com/nikitkaralius/lineup/internal/tracing"
 	"github.com/nikitkaralius/lineup/internal/voters"
+	"github.com/nikitkaralius/lineup/internal/webhooks"
 )
 
-func HandlePollAnswer(ctx context.Context, store *voters.Repository, pa *tgbotapi.PollAnswer) {
-	// Persist vote
-	_ = store.UpsertVote(ctx, pa.PollID, pa.User, pa.OptionIDs)
+func HandlePollAnswer(ctx context.Context, store voters.Repository, pollsRepo polls.Repository, events webhooks.Publisher, pa *tgbotapi.PollAnswer) {
+	ctx = logging.With(ctx, logging.PollID(pa.PollID), logging.UserID(pa.User.ID))
+	ctx, span := tracing.Start(ctx, "HandlePollAnswer", tracing.PollID.String(pa.PollID), tracing.UserID.Int64(pa.User.ID))
+	defer span.End()
+
+	// Persist vote; a retracted vote has no options and is removed
+	if len(pa.OptionIDs) == 0 {
+		if err := store.DeleteVote(ctx, pa.PollID, pa.User.ID); err != nil {
+			slog.ErrorContext(ctx, "delete retracted vote failed", logging.Err(err))
+			return
+		}
+		slog.InfoContext(ctx, "vote retracted")
+	} else if err := store.UpsertVote(ctx, pa.PollID, pa.User, pa.OptionIDs); err != nil {
+		slog.ErrorContext(ctx, "save vote failed", logging.Err(err))
+		return
+	}
+
+	// Poll answers carry no chat, the poll knows which chat's webhooks to notify
+	poll, err := pollsRepo.GetPoll(ctx, pa.PollID)
+	if err != nil {
+		slog.ErrorContext(ctx, "publish event failed", slog.String("event", webhooks.EventVoteChanged), logging.Err(err))
+		return
+	}
+	data := webhooks.VoteData{UserID: pa.User.ID, Username: pa.User.UserName, Name: voters.DisplayName(pa.User), OptionIDs: pa.OptionIDs}
+	if data.OptionIDs == nil {
+		data.OptionIDs = []int{}
+	}
+	if err := events.Publish(ctx, poll.ChatID, webhooks.EventVoteChanged, pa.PollID, data); err != nil {
+		slog.ErrorContext(ctx, "publish event failed", slog.String("event", webhooks.EventVoteChanged), logging.ChatID(poll.ChatID), logging.Err(err))
+	}
 }
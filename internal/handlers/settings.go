@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nikitkaralius/lineup/internal/chats"
+	"github.com/nikitkaralius/lineup/internal/i18n"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/messenger"
+	"github.com/nikitkaralius/lineup/internal/queue"
+)
+
+// handleLanguageCommand shows the chat language for "/language" and changes it for "/language <code>".
+// In a forum topic the command changes the topic's language. Only chat administrators may change it.
+func handleLanguageCommand(ctx context.Context, bot messenger.Messenger, chatsRepo *chats.Repository, settings *chats.ChatSettingsDTO, msg *tgbotapi.Message) {
+	lang := settings.Language
+	arg := strings.TrimSpace(msg.CommandArguments())
+	if arg == "" {
+		reply(ctx, bot, msg, i18n.T(lang, i18n.LanguageCurrent, i18n.T(lang, i18n.LanguageName), supportedLanguages()))
+		return
+	}
+
+	if !isAdminAuthor(ctx, bot, msg) {
+		reply(ctx, bot, msg, i18n.T(lang, i18n.LanguageAdminOnly))
+		return
+	}
+
+	newLang, ok := i18n.Parse(arg)
+	if !ok {
+		reply(ctx, bot, msg, i18n.T(lang, i18n.LanguageUnknown, arg, supportedLanguages()))
+		return
+	}
+
+	if err := chatsRepo.SetLanguage(ctx, msg.Chat.ID, settings.ThreadID, newLang); err != nil {
+		slog.ErrorContext(ctx, "save chat settings failed", logging.Err(err))
+		reply(ctx, bot, msg, i18n.T(lang, i18n.SettingsError, err))
+		return
+	}
+	reply(ctx, bot, msg, i18n.T(newLang, i18n.LanguageChanged, i18n.T(newLang, i18n.LanguageName)))
+}
+
+// isAdminAuthor reports whether the author of msg administers its chat.
+func isAdminAuthor(ctx context.Context, bot messenger.Messenger, msg *tgbotapi.Message) bool {
+	return msg.From != nil && isChatAdmin(ctx, bot, msg.Chat.ID, msg.From.ID)
+}
+
+// supportedLanguages lists the catalog languages as "en (English), ru (Русский)".
+func supportedLanguages() string {
+	langs := i18n.Supported()
+	names := make([]string, len(langs))
+	for i, l := range langs {
+		names[i] = string(l) + " (" + i18n.T(l, i18n.LanguageName) + ")"
+	}
+	return strings.Join(names, ", ")
+}
+
+// handleTemplateCommand shows the results template for "/template", selects a preset
+// for "/template <name>" and stores a custom one for "/template custom" or
+// "/template custom_html" followed by the template body on the next lines.
+// In a forum topic the command changes the topic's template. Only chat administrators may change it.
+func handleTemplateCommand(ctx context.Context, bot messenger.Messenger, chatsRepo *chats.Repository, settings *chats.ChatSettingsDTO, msg *tgbotapi.Message) {
+	lang := settings.Language
+	args := strings.TrimSpace(msg.CommandArguments())
+	name, body, _ := strings.Cut(args, "\n")
+	name = strings.TrimSpace(name)
+	presets := strings.Join(queue.PresetNames(), ", ")
+
+	if name == "" {
+		current := queue.ChatTemplate(settings)
+		reply(ctx, bot, msg, i18n.T(lang, i18n.TemplateCurrent, current.Name, presets))
+		return
+	}
+	if !isAdminAuthor(ctx, bot, msg) {
+		reply(ctx, bot, msg, i18n.T(lang, i18n.TemplateAdminOnly))
+		return
+	}
+
+	var tpl queue.Template
+	switch name {
+	case queue.CustomTemplate, queue.CustomTemplate + "_html":
+		if strings.TrimSpace(body) == "" {
+			reply(ctx, bot, msg, i18n.T(lang, i18n.TemplateNoBody))
+			return
+		}
+		tpl = queue.Template{Name: queue.CustomTemplate, Body: body}
+		if name != queue.CustomTemplate {
+			tpl.ParseMode = messenger.ModeHTML
+		}
+		if err := queue.ValidateTemplate(tpl); err != nil {
+			reply(ctx, bot, msg, i18n.T(lang, i18n.TemplateInvalid, err))
+			return
+		}
+	default:
+		preset, ok := queue.Presets[name]
+		if !ok {
+			reply(ctx, bot, msg, i18n.T(lang, i18n.TemplateUnknown, name, presets))
+			return
+		}
+		tpl = queue.Template{Name: preset.Name}
+	}
+
+	if err := chatsRepo.SetTemplate(ctx, msg.Chat.ID, settings.ThreadID, tpl.Name, tpl.Body, tpl.ParseMode); err != nil {
+		slog.ErrorContext(ctx, "save chat settings failed", logging.Err(err))
+		reply(ctx, bot, msg, i18n.T(lang, i18n.SettingsError, err))
+		return
+	}
+	reply(ctx, bot, msg, i18n.T(lang, i18n.TemplateChanged, tpl.Name))
+}
+
+// handleTimezoneCommand shows the chat timezone for "/timezone" and changes it for "/timezone <IANA name>".
+// In a forum topic the command changes the topic's timezone. Only chat administrators may change it.
+func handleTimezoneCommand(ctx context.Context, bot messenger.Messenger, chatsRepo *chats.Repository, settings *chats.ChatSettingsDTO, msg *tgbotapi.Message) {
+	lang := settings.Language
+	arg := strings.TrimSpace(msg.CommandArguments())
+	if arg == "" {
+		reply(ctx, bot, msg, i18n.T(lang, i18n.TimezoneCurrent, settings.Timezone))
+		return
+	}
+	if !isAdminAuthor(ctx, bot, msg) {
+		reply(ctx, bot, msg, i18n.T(lang, i18n.TimezoneAdminOnly))
+		return
+	}
+
+	loc, err := time.LoadLocation(arg)
+	if err != nil || arg == "Local" {
+		reply(ctx, bot, msg, i18n.T(lang, i18n.TimezoneUnknown, arg))
+		return
+	}
+
+	if err := chatsRepo.SetTimezone(ctx, msg.Chat.ID, settings.ThreadID, loc.String()); err != nil {
+		slog.ErrorContext(ctx, "save chat settings failed", logging.Err(err))
+		reply(ctx, bot, msg, i18n.T(lang, i18n.SettingsError, err))
+		return
+	}
+	reply(ctx, bot, msg, i18n.T(lang, i18n.TimezoneChanged, loc.String()))
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nikitkaralius/lineup/internal/i18n"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/messenger"
+	"github.com/nikitkaralius/lineup/internal/webhooks"
+)
+
+// handleWebhookCommand lists the chat's webhooks for "/webhook", adds one for
+// "/webhook add <url> [events...]" and removes one for "/webhook remove <id>".
+// Only chat administrators may use it; secrets are sent privately.
+func handleWebhookCommand(ctx context.Context, bot messenger.Messenger, webhooksRepo *webhooks.Repository, lang i18n.Lang, msg *tgbotapi.Message) {
+	if msg.From == nil || !isChatAdmin(ctx, bot, msg.Chat.ID, msg.From.ID) {
+		reply(ctx, bot, msg, i18n.T(lang, i18n.WebhookAdminOnly))
+		return
+	}
+
+	args := strings.Fields(msg.CommandArguments())
+	switch {
+	case len(args) == 0:
+		listWebhooks(ctx, bot, webhooksRepo, lang, msg)
+	case args[0] == "add" && len(args) >= 2:
+		addWebhook(ctx, bot, webhooksRepo, lang, msg, args[1], args[2:])
+	case args[0] == "remove" && len(args) == 2:
+		removeWebhook(ctx, bot, webhooksRepo, lang, msg, args[1])
+	default:
+		reply(ctx, bot, msg, i18n.T(lang, i18n.WebhookUsage, strings.Join(webhooks.EventTypes, ", ")))
+	}
+}
+
+func listWebhooks(ctx context.Context, bot messenger.Messenger, webhooksRepo *webhooks.Repository, lang i18n.Lang, msg *tgbotapi.Message) {
+	hooks, err := webhooksRepo.ListWebhooks(ctx, msg.Chat.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "list webhooks failed", logging.Err(err))
+		reply(ctx, bot, msg, i18n.T(lang, i18n.SettingsError, err))
+		return
+	}
+	if len(hooks) == 0 {
+		reply(ctx, bot, msg, i18n.T(lang, i18n.WebhookNone)+"\n\n"+i18n.T(lang, i18n.WebhookUsage, strings.Join(webhooks.EventTypes, ", ")))
+		return
+	}
+	b := strings.Builder{}
+	for _, h := range hooks {
+		events := i18n.T(lang, i18n.WebhookAllEvents)
+		if len(h.Events) > 0 {
+			events = strings.Join(h.Events, ", ")
+		}
+		b.WriteString(fmt.Sprintf("#%d %s (%s)\n", h.ID, h.URL, events))
+	}
+	reply(ctx, bot, msg, i18n.T(lang, i18n.WebhookList, b.String()))
+}
+
+func addWebhook(ctx context.Context, bot messenger.Messenger, webhooksRepo *webhooks.Repository, lang i18n.Lang, msg *tgbotapi.Message, rawURL string, events []string) {
+	u, err := webhooks.ParseURL(rawURL)
+	if err != nil {
+		reply(ctx, bot, msg, i18n.T(lang, i18n.WebhookInvalidURL, rawURL))
+		return
+	}
+	for _, e := range events {
+		if !slices.Contains(webhooks.EventTypes, e) {
+			reply(ctx, bot, msg, i18n.T(lang, i18n.WebhookUnknownEvent, e, strings.Join(webhooks.EventTypes, ", ")))
+			return
+		}
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		slog.ErrorContext(ctx, "generate webhook secret failed", logging.Err(err))
+		return
+	}
+	hook := &webhooks.WebhookDTO{ChatID: msg.Chat.ID, URL: u.String(), Secret: secret, Events: events}
+	if err := webhooksRepo.AddWebhook(ctx, hook); err != nil {
+		slog.ErrorContext(ctx, "add webhook failed", logging.Err(err))
+		reply(ctx, bot, msg, i18n.T(lang, i18n.SettingsError, err))
+		return
+	}
+
+	// The secret must not be posted in the group, so the webhook is only kept if it can be sent privately
+	dm := messenger.Message{
+		ChatID:                msg.From.ID,
+		Text:                  i18n.T(lang, i18n.WebhookSecret, hook.ID, hook.URL, hook.Secret),
+		DisableWebPagePreview: true,
+	}
+	if _, err := bot.SendMessage(ctx, dm); err != nil {
+		slog.WarnContext(ctx, "send webhook secret failed", logging.Err(err))
+		if _, err := webhooksRepo.DeleteWebhook(ctx, msg.Chat.ID, hook.ID); err != nil {
+			slog.ErrorContext(ctx, "delete webhook failed", slog.Int64("webhook_id", hook.ID), logging.Err(err))
+		}
+		reply(ctx, bot, msg, i18n.T(lang, i18n.DashboardStartBot, bot.Username()))
+		return
+	}
+	reply(ctx, bot, msg, i18n.T(lang, i18n.WebhookAdded, hook.ID))
+}
+
+func removeWebhook(ctx context.Context, bot messenger.Messenger, webhooksRepo *webhooks.Repository, lang i18n.Lang, msg *tgbotapi.Message, rawID string) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(rawID, "#"), 10, 64)
+	if err != nil {
+		reply(ctx, bot, msg, i18n.T(lang, i18n.WebhookNotFound, rawID))
+		return
+	}
+	ok, err := webhooksRepo.DeleteWebhook(ctx, msg.Chat.ID, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "delete webhook failed", logging.Err(err))
+		reply(ctx, bot, msg, i18n.T(lang, i18n.SettingsError, err))
+		return
+	}
+	if !ok {
+		reply(ctx, bot, msg, i18n.T(lang, i18n.WebhookNotFound, rawID))
+		return
+	}
+	reply(ctx, bot, msg, i18n.T(lang, i18n.WebhookRemoved, id))
+}
+
+// isChatAdmin reports whether the user administers the chat.
+func isChatAdmin(ctx context.Context, bot messenger.Messenger, chatID, userID int64) bool {
+	admin, err := bot.IsChatAdmin(ctx, chatID, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "get chat member failed", logging.ChatID(chatID), logging.UserID(userID), logging.Err(err))
+		return false
+	}
+	return admin
+}
@@ -0,0 +1,49 @@
+package health
+
+import (
+	"context"
+	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+)
+
+// Postgres checks that the database accepts connections.
+func Postgres(db *pgxpool.Pool) Check {
+	return db.Ping
+}
+
+// River checks that River's tables are reachable through its client; a working client
+// must also not have stopped.
+func River[TTx any](client *river.Client[TTx], working bool) Check {
+	return func(ctx context.Context) error {
+		if working {
+			select {
+			case <-client.Stopped():
+				return fmt.Errorf("river client stopped")
+			default:
+			}
+		}
+		_, err := client.QueueList(ctx, river.NewQueueListParams().First(1))
+		return err
+	}
+}
+
+// BotAPI checks that the Telegram Bot API answers getMe.
+// tgbotapi takes no context, so the check gives up waiting when ctx is done.
+func BotAPI(bot *tgbotapi.BotAPI) Check {
+	return func(ctx context.Context) error {
+		done := make(chan error, 1)
+		go func() {
+			_, err := bot.GetMe()
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
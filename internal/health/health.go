@@ -0,0 +1,110 @@
+// Package health serves liveness and readiness probes.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CheckTimeout bounds every readiness check.
+const CheckTimeout = 5 * time.Second
+
+// Check reports whether a dependency is reachable.
+type Check func(ctx context.Context) error
+
+// Handler serves GET /healthz, which succeeds while the process runs, and GET /readyz,
+// which succeeds only if all checks pass.
+type Handler struct {
+	names  []string
+	checks map[string]Check
+}
+
+func NewHandler() *Handler {
+	return &Handler{checks: make(map[string]Check)}
+}
+
+// Add registers a readiness check.
+func (h *Handler) Add(name string, check Check) {
+	h.names = append(h.names, name)
+	h.checks[name] = check
+}
+
+// Register registers the probe routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", h.healthz)
+	mux.HandleFunc("GET /readyz", h.readyz)
+}
+
+func (h *Handler) healthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte("ok")); err != nil {
+		slog.WarnContext(r.Context(), "write healthz response failed", slog.Any("error", err))
+	}
+}
+
+type readyResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+func (h *Handler) readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), CheckTimeout)
+	defer cancel()
+
+	resp := readyResponse{Status: "ok", Checks: make(map[string]string, len(h.names))}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, name := range h.names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := "ok"
+			if err := h.checks[name](ctx); err != nil {
+				result = err.Error()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			resp.Checks[name] = result
+			if result != "ok" {
+				resp.Status = "unavailable"
+			}
+		}()
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	if resp.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.WarnContext(r.Context(), "write readyz response failed", slog.Any("error", err))
+	}
+}
+
+// Cached runs check at most once per ttl and reports the last result in between,
+// for checks that call rate-limited APIs.
+func Cached(check Check, ttl time.Duration) Check {
+	var (
+		mu      sync.Mutex
+		checked time.Time
+		last    error
+	)
+	return func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if !checked.IsZero() && time.Since(checked) < ttl {
+			return last
+		}
+		last = check(ctx)
+		checked = time.Now()
+		return last
+	}
+}
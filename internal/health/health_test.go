@@ -0,0 +1,55 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReadyz(t *testing.T) {
+	h := NewHandler()
+	h.Add("postgres", func(ctx context.Context) error { return nil })
+	failing := errors.New("connection refused")
+	h.Add("telegram", func(ctx context.Context) error { return failing })
+	mux := http.NewServeMux()
+	h.Register(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+	var resp readyResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Checks["postgres"] != "ok" || resp.Checks["telegram"] != failing.Error() {
+		t.Errorf("checks = %v", resp.Checks)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("healthz status = %d, want 200", rec.Code)
+	}
+}
+
+func TestCached(t *testing.T) {
+	calls := 0
+	check := Cached(func(ctx context.Context) error {
+		calls++
+		return nil
+	}, time.Hour)
+	for range 3 {
+		if err := check(context.Background()); err != nil {
+			t.Fatalf("check: %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("check ran %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,101 @@
+package i18n
+
+var english = map[Key]string{
+	LanguageName: "English",
+
+	PollTopic:        "📋 Topic: %s\n⏰ Ends: %s",
+	AnswerComing:     "Going",
+	AnswerNotComing:  "Not going",
+	ErrEndTime:       "Could not process the end time: %v",
+	ErrDuration:      "Could not process the duration: %v",
+	ErrNoDuration:    "No poll duration or end time given",
+	ErrMissingTopic:  "❌ The poll topic is missing.\n\nWhat to add: the poll topic\nExamples:\n/poll Math | 30m\n/poll Practice | until 13:48",
+	ErrPollExplained: "%s\n\nCorrect format examples:\n/poll Topic | 30m\n/poll Topic | until 13:48\n/poll Topic | tomorrow 13:48",
+	ErrPollUnparsed:  "❌ Could not understand the request: %v\n\nCorrect format examples:\n/poll Topic | 30m\n/poll Topic | until 13:48\n/poll Topic | tomorrow 13:48",
+	AskDuration:      "⏰ Until when should the poll «%s» run?\n\nReply to this message, for example: 30m, until 13:48, tomorrow 13:48",
+	AskComingAnswer:  "🙋 Which answer means going?\n\n%s\nReply to this message with the answer's number or text",
+	PollSchedule:     "\n🕐 Starts: %s, %s per person",
+	SlotMinutes:      "%d min",
+	ErrSessionStart:  "Could not process the session start: %v",
+	ErrSlotDuration:  "Could not process the time per person: %v",
+	ErrHalfSchedule:  "To estimate everyone's time, give both the session start and the time per person, for example: /poll Practice | until 13:00 | starts 14:00 | 7 min each",
+
+	QueueEmpty:         "No one is in the queue.",
+	VoterAnonymous:     "Anonymous",
+	VoterUnknown:       "Unknown",
+	QueueUnparsed:      "I can't understand your request: %v\n\nUse: 'join the queue' or 'leave the queue'",
+	QueueUnknownAction: "I can't tell what you want. Use: 'join the queue' or 'leave the queue'",
+	QueueAlreadyIn:     "you are already in the queue",
+	QueueNotIn:         "you are not in the queue",
+	QueueError:         "Error: %v",
+
+	LanguageCurrent:   "🌐 Chat language: %s\n\nAvailable languages: %s\nChange it: /language ru",
+	LanguageChanged:   "🌐 Chat language set to %s",
+	LanguageUnknown:   "Unknown language «%s». Available languages: %s",
+	LanguageAdminOnly: "Only chat administrators can change the language",
+	SettingsError:     "Could not save chat settings: %v",
+	TemplateCurrent:   "🧩 Results template: %s\n\nBuilt-in templates: %s\nPick one: /template compact\nYour own: /template custom (or custom_html for HTML) followed by a Go text/template on the next line, see the README for the data model",
+	TemplateChanged:   "🧩 Results template set to %s",
+	TemplateUnknown:   "Unknown template «%s». Built-in templates: %s",
+	TemplateInvalid:   "The template does not work: %v",
+	TemplateNoBody:    "Put the template text on a new line after the command",
+	TemplateAdminOnly: "Only chat administrators can change the results template",
+	TimezoneCurrent:   "🕐 Chat timezone: %s\nChange it: /timezone Europe/London",
+	TimezoneChanged:   "🕐 Chat timezone set to %s",
+	TimezoneUnknown:   "Unknown timezone «%s». Use an IANA name, for example Europe/London",
+	TimezoneAdminOnly: "Only chat administrators can change the timezone",
+
+	DashboardDisabled:    "The web dashboard is not set up for this bot",
+	DashboardLink:        "📊 Login link for the dashboard of «%s»:\n%s\n\nThe link is valid for %d min. Do not forward it to anyone.",
+	DashboardLinkSent:    "📊 I sent you a dashboard link in a private message",
+	DashboardStartBot:    "I can't message you privately. Open @%s, press Start and repeat /dashboard",
+	DashboardTitle:       "Lineup — queue history",
+	DashboardChats:       "Your chats",
+	DashboardLogout:      "Log out",
+	DashboardExpired:     "The link has expired or is invalid. Send /dashboard in the chat to get a new one.",
+	DashboardForbidden:   "You have no access to this chat. Send /dashboard there to get a link.",
+	DashboardHistory:     "Poll history",
+	DashboardNoPolls:     "There have been no polls in this chat yet.",
+	DashboardNewer:       "← Newer",
+	DashboardOlder:       "Older →",
+	DashboardPeople:      "Attendance",
+	DashboardNoPeople:    "Statistics appear after the first finished poll.",
+	DashboardPerson:      "Person",
+	DashboardPolls:       "Polls",
+	DashboardAttended:    "Queued",
+	DashboardRate:        "Attendance rate",
+	DashboardAvgPosition: "Average position",
+	DashboardTopic:       "Topic",
+	DashboardStatus:      "Status",
+	DashboardEndsAt:      "Ends",
+	DashboardQueue:       "Queue",
+	DashboardVotes:       "Votes",
+	DashboardAnswer:      "Answer",
+	DashboardSlot:        "Time",
+	DashboardBack:        "← Back to chat",
+	StatusActive:         "running",
+	StatusPublishing:     "publishing",
+	StatusProcessed:      "finished",
+	StatusCancelled:      "cancelled",
+	StatusDeleted:        "deleted",
+
+	ExportUsage:      "Usage: /export [csv|json] [from YYYY-MM-DD] [to YYYY-MM-DD]\nFor example: /export csv 2024-09-01 2024-12-31\nWithout dates, polls of the last 30 days are exported.",
+	ExportEmpty:      "There were no polls from %s to %s",
+	ExportCaption:    "📦 %d polls from %s to %s",
+	ExportError:      "Could not export polls: %v",
+	CalendarDisabled: "The calendar is not set up for this bot",
+	CalendarLink:     "📅 Your calendar of upcoming sessions:\n%s\n\nAdd it to your calendar app as a subscription by URL. The link is personal and valid for %d days.",
+	CalendarLinkSent: "📅 I sent you a calendar link in a private message",
+
+	WebhookAdminOnly:    "Only chat administrators can configure webhooks",
+	WebhookUsage:        "🔗 Webhooks send the chat's events to other systems.\n\nAdd: /webhook add https://example.com/hook [events]\nRemove: /webhook remove <number>\nEvents: %s (all by default)",
+	WebhookList:         "🔗 Chat webhooks:\n%s\nAdd: /webhook add <url> [events]\nRemove: /webhook remove <number>",
+	WebhookNone:         "This chat has no webhooks",
+	WebhookAllEvents:    "all events",
+	WebhookInvalidURL:   "Invalid address «%s». Use a full https URL of a public host",
+	WebhookUnknownEvent: "Unknown event «%s». Events: %s",
+	WebhookAdded:        "🔗 Webhook #%d added. I sent you the secret to verify signatures in a private message",
+	WebhookSecret:       "🔐 Secret of webhook #%d (%s):\n%s\n\nRequests are signed in the X-Lineup-Signature header: sha256=HMAC-SHA256(secret, X-Lineup-Timestamp + \".\" + request body)",
+	WebhookRemoved:      "🔗 Webhook #%d removed",
+	WebhookNotFound:     "Webhook #%s not found",
+}
@@ -0,0 +1,61 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Lang is a chat language code (ISO 639-1).
+type Lang string
+
+const (
+	Russian Lang = "ru"
+	English Lang = "en"
+)
+
+// Default is the language of chats that never chose one.
+const Default = Russian
+
+// Key identifies a user-facing message in the catalog.
+type Key string
+
+// catalog maps every supported language to its messages.
+// A new language only needs its own map registered here.
+var catalog = map[Lang]map[Key]string{
+	Russian: russian,
+	English: english,
+}
+
+// T returns the message for key in lang formatted with args.
+// Messages missing in lang fall back to Default, then to the key itself.
+func T(lang Lang, key Key, args ...any) string {
+	msg, ok := catalog[lang][key]
+	if !ok {
+		msg, ok = catalog[Default][key]
+	}
+	if !ok {
+		msg = string(key)
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// Parse resolves a user-supplied language code.
+func Parse(s string) (Lang, bool) {
+	lang := Lang(strings.ToLower(strings.TrimSpace(s)))
+	_, ok := catalog[lang]
+	return lang, ok
+}
+
+// Supported returns all languages in the catalog sorted by code.
+func Supported() []Lang {
+	langs := make([]Lang, 0, len(catalog))
+	for lang := range catalog {
+		langs = append(langs, lang)
+	}
+	sort.Slice(langs, func(i, j int) bool { return langs[i] < langs[j] })
+	return langs
+}
@@ -0,0 +1,107 @@
+package i18n
+
+const (
+	LanguageName Key = "language_name" // the language's own name, e.g. "English"
+
+	// Poll creation
+	PollTopic        Key = "poll_topic"
+	AnswerComing     Key = "answer_coming"
+	AnswerNotComing  Key = "answer_not_coming"
+	ErrEndTime       Key = "err_end_time"
+	ErrDuration      Key = "err_duration"
+	ErrNoDuration    Key = "err_no_duration"
+	ErrMissingTopic  Key = "err_missing_topic"
+	ErrPollExplained Key = "err_poll_explained"
+	ErrPollUnparsed  Key = "err_poll_unparsed"
+	AskDuration      Key = "ask_duration"
+	AskComingAnswer  Key = "ask_coming_answer"
+	PollSchedule     Key = "poll_schedule"
+	SlotMinutes      Key = "slot_minutes"
+	ErrSessionStart  Key = "err_session_start"
+	ErrSlotDuration  Key = "err_slot_duration"
+	ErrHalfSchedule  Key = "err_half_schedule"
+
+	// Queue
+	QueueEmpty         Key = "queue_empty"
+	VoterAnonymous     Key = "voter_anonymous"
+	VoterUnknown       Key = "voter_unknown"
+	QueueUnparsed      Key = "queue_unparsed"
+	QueueUnknownAction Key = "queue_unknown_action"
+	QueueAlreadyIn     Key = "queue_already_in"
+	QueueNotIn         Key = "queue_not_in"
+	QueueError         Key = "queue_error"
+
+	// Settings
+	LanguageCurrent   Key = "language_current"
+	LanguageChanged   Key = "language_changed"
+	LanguageUnknown   Key = "language_unknown"
+	LanguageAdminOnly Key = "language_admin_only"
+	SettingsError     Key = "settings_error"
+	TemplateCurrent   Key = "template_current"
+	TemplateChanged   Key = "template_changed"
+	TemplateUnknown   Key = "template_unknown"
+	TemplateInvalid   Key = "template_invalid"
+	TemplateNoBody    Key = "template_no_body"
+	TemplateAdminOnly Key = "template_admin_only"
+	TimezoneCurrent   Key = "timezone_current"
+	TimezoneChanged   Key = "timezone_changed"
+	TimezoneUnknown   Key = "timezone_unknown"
+	TimezoneAdminOnly Key = "timezone_admin_only"
+
+	// Dashboard
+	DashboardDisabled    Key = "dashboard_disabled"
+	DashboardLink        Key = "dashboard_link"
+	DashboardLinkSent    Key = "dashboard_link_sent"
+	DashboardStartBot    Key = "dashboard_start_bot"
+	DashboardTitle       Key = "dashboard_title"
+	DashboardChats       Key = "dashboard_chats"
+	DashboardLogout      Key = "dashboard_logout"
+	DashboardExpired     Key = "dashboard_expired"
+	DashboardForbidden   Key = "dashboard_forbidden"
+	DashboardHistory     Key = "dashboard_history"
+	DashboardNoPolls     Key = "dashboard_no_polls"
+	DashboardNewer       Key = "dashboard_newer"
+	DashboardOlder       Key = "dashboard_older"
+	DashboardPeople      Key = "dashboard_people"
+	DashboardNoPeople    Key = "dashboard_no_people"
+	DashboardPerson      Key = "dashboard_person"
+	DashboardPolls       Key = "dashboard_polls"
+	DashboardAttended    Key = "dashboard_attended"
+	DashboardRate        Key = "dashboard_rate"
+	DashboardAvgPosition Key = "dashboard_avg_position"
+	DashboardTopic       Key = "dashboard_topic"
+	DashboardStatus      Key = "dashboard_status"
+	DashboardEndsAt      Key = "dashboard_ends_at"
+	DashboardQueue       Key = "dashboard_queue"
+	DashboardVotes       Key = "dashboard_votes"
+	DashboardAnswer      Key = "dashboard_answer"
+	DashboardSlot        Key = "dashboard_slot"
+	DashboardBack        Key = "dashboard_back"
+	StatusActive         Key = "status_active"
+	StatusPublishing     Key = "status_publishing"
+	StatusProcessed      Key = "status_processed"
+	StatusCancelled      Key = "status_cancelled"
+	StatusDeleted        Key = "status_deleted"
+
+	// Export
+	ExportUsage      Key = "export_usage"
+	ExportEmpty      Key = "export_empty"
+	ExportCaption    Key = "export_caption"
+	ExportError      Key = "export_error"
+	CalendarDisabled Key = "calendar_disabled"
+	CalendarLink     Key = "calendar_link"
+	CalendarLinkSent Key = "calendar_link_sent"
+
+	// Webhooks
+	WebhookAdminOnly    Key = "webhook_admin_only"
+	WebhookUsage        Key = "webhook_usage"
+	WebhookList         Key = "webhook_list"
+	WebhookNone         Key = "webhook_none"
+	WebhookAllEvents    Key = "webhook_all_events"
+	WebhookInvalidURL   Key = "webhook_invalid_url"
+	WebhookUnknownEvent Key = "webhook_unknown_event"
+	WebhookAdded        Key = "webhook_added"
+	WebhookSecret       Key = "webhook_secret"
+	WebhookRemoved      Key = "webhook_removed"
+	WebhookNotFound     Key = "webhook_not_found"
+)
@@ -0,0 +1,101 @@
+package i18n
+
+var russian = map[Key]string{
+	LanguageName: "Русский",
+
+	PollTopic:        "📋 Тема: %s\n⏰ Завершится: %s",
+	AnswerComing:     "Иду",
+	AnswerNotComing:  "Не иду",
+	ErrEndTime:       "Ошибка обработки времени окончания: %v",
+	ErrDuration:      "Ошибка обработки длительности: %v",
+	ErrNoDuration:    "Не указана длительность или время окончания опроса",
+	ErrMissingTopic:  "❌ Тема опроса не указана.\n\nЧто добавить: укажите тему опроса\nПримеры:\n/poll Математика | 30m\n/poll Практика | до 13:48",
+	ErrPollExplained: "%s\n\nПримеры правильного формата:\n/poll Тема | 30m\n/poll Тема | до 13:48\n/poll Тема | завтра 13:48",
+	ErrPollUnparsed:  "❌ Не удалось разобрать запрос: %v\n\nПримеры правильного формата:\n/poll Тема | 30m\n/poll Тема | до 13:48\n/poll Тема | завтра 13:48",
+	AskDuration:      "⏰ До какого времени провести опрос «%s»?\n\nОтветьте на это сообщение, например: 30m, до 13:48, завтра 13:48",
+	AskComingAnswer:  "🙋 Какой вариант ответа означает «Иду»?\n\n%s\nОтветьте на это сообщение номером или текстом варианта",
+	PollSchedule:     "\n🕐 Начало: %s, по %s на человека",
+	SlotMinutes:      "%d мин",
+	ErrSessionStart:  "Ошибка обработки времени начала занятия: %v",
+	ErrSlotDuration:  "Ошибка обработки времени на человека: %v",
+	ErrHalfSchedule:  "Чтобы рассчитать время для каждого, укажите и начало занятия, и время на человека, например: /poll Практика | до 13:00 | начало 14:00 | по 7 минут",
+
+	QueueEmpty:         "Очередь пуста.",
+	VoterAnonymous:     "Аноним",
+	VoterUnknown:       "Неизвестный",
+	QueueUnparsed:      "Не могу понять ваш запрос: %v\n\nИспользуйте: 'хочу в очередь' или 'выхожу из очереди'",
+	QueueUnknownAction: "Не могу определить действие. Используйте: 'хочу в очередь' или 'выхожу из очереди'",
+	QueueAlreadyIn:     "вы уже в очереди",
+	QueueNotIn:         "вы не в очереди",
+	QueueError:         "Ошибка: %v",
+
+	LanguageCurrent:   "🌐 Язык чата: %s\n\nДоступные языки: %s\nИзменить: /language en",
+	LanguageChanged:   "🌐 Язык чата изменён: %s",
+	LanguageUnknown:   "Неизвестный язык «%s». Доступные языки: %s",
+	LanguageAdminOnly: "Менять язык могут только администраторы чата",
+	SettingsError:     "Не удалось сохранить настройки чата: %v",
+	TemplateCurrent:   "🧩 Шаблон результатов: %s\n\nВстроенные шаблоны: %s\nВыбрать: /template compact\nСвой шаблон: /template custom (или custom_html для HTML), а с новой строки — шаблон Go text/template, описание данных в README",
+	TemplateChanged:   "🧩 Шаблон результатов изменён: %s",
+	TemplateUnknown:   "Неизвестный шаблон «%s». Встроенные шаблоны: %s",
+	TemplateInvalid:   "Шаблон не работает: %v",
+	TemplateNoBody:    "Добавьте текст шаблона с новой строки после команды",
+	TemplateAdminOnly: "Менять шаблон результатов могут только администраторы чата",
+	TimezoneCurrent:   "🕐 Часовой пояс чата: %s\nИзменить: /timezone Europe/Moscow",
+	TimezoneChanged:   "🕐 Часовой пояс чата изменён: %s",
+	TimezoneUnknown:   "Неизвестный часовой пояс «%s». Используйте название из базы IANA, например Europe/Moscow",
+	TimezoneAdminOnly: "Менять часовой пояс могут только администраторы чата",
+
+	DashboardDisabled:    "Веб-панель не настроена для этого бота",
+	DashboardLink:        "📊 Ссылка для входа в панель чата «%s»:\n%s\n\nСсылка действует %d мин. Никому её не пересылайте.",
+	DashboardLinkSent:    "📊 Отправил ссылку на панель в личные сообщения",
+	DashboardStartBot:    "Не могу написать вам в личные сообщения. Откройте @%s, нажмите «Старт» и повторите /dashboard",
+	DashboardTitle:       "Lineup — история очередей",
+	DashboardChats:       "Ваши чаты",
+	DashboardLogout:      "Выйти",
+	DashboardExpired:     "Ссылка устарела или недействительна. Отправьте /dashboard в чате, чтобы получить новую.",
+	DashboardForbidden:   "У вас нет доступа к этому чату. Отправьте /dashboard в нём, чтобы получить ссылку.",
+	DashboardHistory:     "История опросов",
+	DashboardNoPolls:     "В этом чате ещё не было опросов.",
+	DashboardNewer:       "← Новее",
+	DashboardOlder:       "Старше →",
+	DashboardPeople:      "Посещаемость",
+	DashboardNoPeople:    "Статистика появится после первого завершённого опроса.",
+	DashboardPerson:      "Участник",
+	DashboardPolls:       "Опросов",
+	DashboardAttended:    "В очереди",
+	DashboardRate:        "Посещаемость",
+	DashboardAvgPosition: "Средняя позиция",
+	DashboardTopic:       "Тема",
+	DashboardStatus:      "Статус",
+	DashboardEndsAt:      "Завершение",
+	DashboardQueue:       "Очередь",
+	DashboardVotes:       "Голоса",
+	DashboardAnswer:      "Ответ",
+	DashboardSlot:        "Время",
+	DashboardBack:        "← К чату",
+	StatusActive:         "идёт",
+	StatusPublishing:     "публикуется",
+	StatusProcessed:      "завершён",
+	StatusCancelled:      "отменён",
+	StatusDeleted:        "удалён",
+
+	ExportUsage:      "Использование: /export [csv|json] [с ГГГГ-ММ-ДД] [по ГГГГ-ММ-ДД]\nНапример: /export csv 2024-09-01 2024-12-31\nБез дат — опросы за последние 30 дней.",
+	ExportEmpty:      "С %s по %s опросов не было",
+	ExportCaption:    "📦 Опросов: %d, с %s по %s",
+	ExportError:      "Не удалось выгрузить опросы: %v",
+	CalendarDisabled: "Календарь не настроен для этого бота",
+	CalendarLink:     "📅 Ваш календарь предстоящих занятий:\n%s\n\nДобавьте его в приложение календаря как подписку по ссылке. Ссылка личная и действует %d дн.",
+	CalendarLinkSent: "📅 Отправил ссылку на календарь в личные сообщения",
+
+	WebhookAdminOnly:    "Настраивать вебхуки могут только администраторы чата",
+	WebhookUsage:        "🔗 Вебхуки присылают события чата другим системам.\n\nДобавить: /webhook add https://example.com/hook [события]\nУдалить: /webhook remove <номер>\nСобытия: %s (по умолчанию все)",
+	WebhookList:         "🔗 Вебхуки чата:\n%s\nДобавить: /webhook add <url> [события]\nУдалить: /webhook remove <номер>",
+	WebhookNone:         "В этом чате нет вебхуков",
+	WebhookAllEvents:    "все события",
+	WebhookInvalidURL:   "Некорректный адрес «%s». Нужен полный https URL публичного хоста",
+	WebhookUnknownEvent: "Неизвестное событие «%s». События: %s",
+	WebhookAdded:        "🔗 Вебхук #%d добавлен. Секрет для проверки подписи отправил в личные сообщения",
+	WebhookSecret:       "🔐 Секрет вебхука #%d (%s):\n%s\n\nЗапросы подписаны в заголовке X-Lineup-Signature: sha256=HMAC-SHA256(секрет, X-Lineup-Timestamp + \".\" + тело запроса)",
+	WebhookRemoved:      "🔗 Вебхук #%d удалён",
+	WebhookNotFound:     "Вебхук #%s не найден",
+}
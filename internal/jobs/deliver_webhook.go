@@ -0,0 +1,92 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/webhooks"
+	"github.com/riverqueue/river"
+)
+
+// webhookTimeout limits a single delivery attempt.
+const webhookTimeout = 10 * time.Second
+
+type DeliverWebhookWorker struct {
+	river.WorkerDefaults[webhooks.DeliverWebhookArgs]
+	webhooks *webhooks.Repository
+	client   *http.Client
+}
+
+func NewDeliverWebhookWorker(repo *webhooks.Repository) *DeliverWebhookWorker {
+	return &DeliverWebhookWorker{webhooks: repo, client: webhooks.NewClient(webhookTimeout)}
+}
+
+func (w *DeliverWebhookWorker) Timeout(*river.Job[webhooks.DeliverWebhookArgs]) time.Duration {
+	return webhookTimeout + 5*time.Second
+}
+
+func (w *DeliverWebhookWorker) Work(ctx context.Context, job *river.Job[webhooks.DeliverWebhookArgs]) error {
+	args := job.Args
+	ctx = logging.With(ctx, logging.JobID(job.ID), logging.PollID(args.Event.PollID), logging.ChatID(args.Event.ChatID), slog.Int64("webhook_id", args.WebhookID))
+
+	hook, err := w.webhooks.GetWebhook(ctx, args.WebhookID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.InfoContext(ctx, "deliver event skipped: webhook was removed", slog.String("event", args.Event.Type), slog.String("event_id", args.Event.ID))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Webhooks added before only https and public hosts were accepted are not delivered
+	if _, err := webhooks.ParseURL(hook.URL); err != nil {
+		return river.JobCancel(fmt.Errorf("webhook %d: %w", hook.ID, err))
+	}
+
+	body, err := json.Marshal(args.Event)
+	if err != nil {
+		return river.JobCancel(err)
+	}
+
+	// Sign with the time of this attempt, so that receivers can reject stale replays
+	timestamp := time.Now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return river.JobCancel(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "lineup-webhooks")
+	req.Header.Set("X-Lineup-Event", args.Event.Type)
+	req.Header.Set("X-Lineup-Delivery", args.Event.ID)
+	req.Header.Set("X-Lineup-Timestamp", fmt.Sprint(timestamp))
+	req.Header.Set("X-Lineup-Signature", webhooks.Sign(hook.Secret, timestamp, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("deliver to webhook %d: %w", hook.ID, err)
+	}
+	defer resp.Body.Close()
+	// Reading the body lets the connection be reused; the delivery outcome only depends on the status
+	if _, err := io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)); err != nil {
+		slog.DebugContext(ctx, "read webhook response failed", logging.Err(err))
+	}
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("webhook %d responded %s", hook.ID, resp.Status)
+	default:
+		// Other client errors will not go away by retrying
+		return river.JobCancel(fmt.Errorf("webhook %d responded %s", hook.ID, resp.Status))
+	}
+}
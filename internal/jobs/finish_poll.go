@@ -2,83 +2,189 @@ package jobs
 
 import (
 	"context"
-	"log"
+	"errors"
+	"log/slog"
 	"math/rand"
+	"time"
 
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nikitkaralius/lineup/internal/chats"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/messenger"
+	"github.com/nikitkaralius/lineup/internal/metrics"
 	"github.com/nikitkaralius/lineup/internal/polls"
 	"github.com/nikitkaralius/lineup/internal/queue"
+	"github.com/nikitkaralius/lineup/internal/tracing"
 	"github.com/nikitkaralius/lineup/internal/voters"
+	"github.com/nikitkaralius/lineup/internal/webhooks"
 	"github.com/riverqueue/river"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type FinishPollWorker struct {
 	river.WorkerDefaults[polls.FinishPollArgs]
-	polls  *polls.Repository
-	voters *voters.Repository
-	bot    *tgbotapi.BotAPI
+	polls  polls.Repository
+	voters voters.Repository
+	chats  *chats.Repository
+	events webhooks.Publisher
+	bot    messenger.Messenger
 }
 
-func NewFinishPollWorker(polls *polls.Repository, voters *voters.Repository, bot *tgbotapi.BotAPI) *FinishPollWorker {
-	return &FinishPollWorker{polls: polls, voters: voters, bot: bot}
+func NewFinishPollWorker(polls polls.Repository, voters voters.Repository, chats *chats.Repository, events webhooks.Publisher, bot messenger.Messenger) *FinishPollWorker {
+	return &FinishPollWorker{polls: polls, voters: voters, chats: chats, events: events, bot: bot}
 }
 
+// Work finishes the poll in steps that are safe to retry: the poll is stopped and its
+// queue stored, moving it to publishing; then the lineup is sent and the poll marked
+// processed. A retry of a publishing poll sends the stored queue instead of drawing a new one.
 func (w *FinishPollWorker) Work(ctx context.Context, job *river.Job[polls.FinishPollArgs]) error {
 	args := job.Args
-	// Stop poll in chat
-	stopCfg := tgbotapi.NewStopPoll(args.ChatID, args.MessageID)
-	if _, err := w.bot.Send(stopCfg); err != nil {
-		log.Printf("stop poll error: %v", err)
-		// keep going; maybe already stopped
-	}
+	ctx = logging.With(ctx, logging.JobID(job.ID), logging.PollID(args.PollID), logging.ChatID(args.ChatID))
+	trace.SpanFromContext(ctx).SetAttributes(tracing.PollID.String(args.PollID), tracing.ChatID.Int64(args.ChatID))
 
-	// Get coming_answer_index from poll
+	// Get status, end time, coming_answer_index, forum topic and time slot schedule from poll
 	pollInfo, err := w.polls.GetPollInfo(ctx, args.PollID)
 	if err != nil {
 		return err
 	}
 
+	var queueUserIDs []int64
+	switch pollInfo.Status {
+	case polls.StatusActive:
+		// Closed early and extended polls get a job per change; only the due one finishes the poll
+		if pollInfo.EndsAt.After(time.Now().Add(time.Second)) {
+			slog.InfoContext(ctx, "finish poll skipped: poll was rescheduled", slog.Time("ends_at", pollInfo.EndsAt))
+			return nil
+		}
+		queueUserIDs, err = w.storeQueue(ctx, args, pollInfo)
+		if errors.Is(err, errPollGone) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	case polls.StatusPublishing:
+		// An earlier attempt stored the queue but did not finish publishing it
+		slog.InfoContext(ctx, "resuming publishing of the lineup", slog.Int("attempt", job.Attempt))
+		if queueUserIDs, err = w.voters.GetQueueUserIDs(ctx, args.PollID); err != nil {
+			return err
+		}
+	default:
+		slog.InfoContext(ctx, "finish poll skipped: poll is not active", slog.String("status", pollInfo.Status))
+		return nil
+	}
+
+	return w.publish(ctx, args, pollInfo, queueUserIDs)
+}
+
+// errPollGone is returned by storeQueue when the poll is no longer to be finished.
+var errPollGone = errors.New("poll is no longer active")
+
+// storeQueue stops the poll in the chat, draws the queue from the coming voters and
+// stores it, and marks the poll publishing.
+func (w *FinishPollWorker) storeQueue(ctx context.Context, args polls.FinishPollArgs, pollInfo *polls.TelegramPollDTO) ([]int64, error) {
+	switch err := w.bot.StopPoll(ctx, args.ChatID, args.MessageID); {
+	case errors.Is(err, messenger.ErrMessageNotFound):
+		// An admin deleted the poll: there is nothing to publish the lineup of
+		slog.InfoContext(ctx, "finish poll skipped: poll message was deleted")
+		if err := w.polls.MarkDeleted(ctx, args.PollID); err != nil && !errors.Is(err, polls.ErrPollNotActive) {
+			return nil, err
+		}
+		return nil, errPollGone
+	case errors.Is(err, messenger.ErrPollClosed):
+		// Stopped by an earlier attempt
+		slog.InfoContext(ctx, "poll was already stopped")
+	case err != nil:
+		slog.WarnContext(ctx, "stop poll failed", logging.Err(err))
+		// keep going, the lineup can still be published
+	}
+
 	// Get voters who selected the "coming" answer
 	vs, err := w.voters.GetComingVoters(ctx, args.PollID, pollInfo.ComingAnswerIndex)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	// Shuffle voters
 	shuffleVoters(vs)
-
-	// Convert to user IDs array
 	queueUserIDs := make([]int64, len(vs))
 	for i, v := range vs {
 		queueUserIDs[i] = v.UserID
 	}
 
-	// Get voter information from repository
+	// A queue stored by an earlier attempt is kept, so read back the one that counts
+	if err := w.voters.InsertPollResult(ctx, args.PollID, queueUserIDs); err != nil {
+		return nil, err
+	}
+	if queueUserIDs, err = w.voters.GetQueueUserIDs(ctx, args.PollID); err != nil {
+		return nil, err
+	}
+	if err := w.polls.MarkPublishing(ctx, args.PollID); errors.Is(err, polls.ErrPollNotActive) {
+		// Cancelled meanwhile, or finished by another job
+		slog.InfoContext(ctx, "finish poll skipped: poll is no longer active")
+		return nil, errPollGone
+	} else if err != nil {
+		return nil, err
+	}
+	return queueUserIDs, nil
+}
+
+// publish sends the lineup of the stored queue and marks the poll processed.
+func (w *FinishPollWorker) publish(ctx context.Context, args polls.FinishPollArgs, pollInfo *polls.TelegramPollDTO, queueUserIDs []int64) error {
 	votersMap, err := w.voters.GetVotersInfo(ctx, args.PollID, queueUserIDs)
 	if err != nil {
 		return err
 	}
 
 	// Format queue text using shared formatter
-	text := queue.FormatQueueText(args.Topic, queueUserIDs, votersMap)
-
-	msg := tgbotapi.NewMessage(args.ChatID, text)
-	sent, err := w.bot.Send(msg)
+	settings := w.chats.GetSettingsOrDefault(ctx, args.ChatID, pollInfo.MessageThreadID)
+	msg := messenger.Message{ChatID: args.ChatID, ThreadID: pollInfo.MessageThreadID}
+	msg.Text, msg.ParseMode = queue.FormatQueueText(settings, pollInfo, queueUserIDs, votersMap)
+
+	sent, err := w.bot.SendMessage(ctx, msg)
+	if errors.Is(err, messenger.ErrBadMarkup) {
+		// A retry would be rejected all the same, so the lineup is published with the default template
+		slog.WarnContext(ctx, "telegram rejected the results template, using the default one", logging.Err(err))
+		msg.Text, msg.ParseMode = queue.DefaultQueueText(settings, pollInfo, queueUserIDs, votersMap)
+		sent, err = w.bot.SendMessage(ctx, msg)
+	}
 	if err != nil {
 		return err
 	}
 
-	if err := w.polls.MarkProcessed(ctx, args.PollID, sent.MessageID, queueUserIDs); err != nil {
+	// Right after sending: failing here is the only way a retry posts the lineup twice
+	if err := w.polls.MarkProcessed(ctx, args.PollID, sent.MessageID); errors.Is(err, polls.ErrPollNotActive) {
+		slog.WarnContext(ctx, "lineup published by another job", slog.Int("message_id", sent.MessageID))
+		return nil
+	} else if err != nil {
 		return err
 	}
+	metrics.PollsFinished.Inc()
 
-	if err := w.voters.InsertPollResult(ctx, args.PollID, queueUserIDs); err != nil {
-		return err
+	data := queue.NewWebhookData(pollInfo, queueUserIDs, votersMap, webhooks.ReasonPublished, 0)
+	if err := w.events.Publish(ctx, args.ChatID, webhooks.EventPollFinished, args.PollID, data); err != nil {
+		slog.ErrorContext(ctx, "publish event failed", slog.String("event", webhooks.EventPollFinished), logging.Err(err))
 	}
-
 	return nil
 }
 
+// NextRetry retries failed finishes soon, since the chat is waiting for the lineup:
+// after 5s, doubling up to 10 minutes.
+func (w *FinishPollWorker) NextRetry(job *river.Job[polls.FinishPollArgs]) time.Time {
+	return time.Now().Add(finishRetryDelay(job.Attempt))
+}
+
+const (
+	finishRetryFirst = 5 * time.Second
+	finishRetryMax   = 10 * time.Minute
+)
+
+// finishRetryDelay returns the delay after the attempt-th attempt failed.
+func finishRetryDelay(attempt int) time.Duration {
+	delay := finishRetryFirst
+	for i := 1; i < attempt && delay < finishRetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, finishRetryMax)
+}
+
 func shuffleVoters(v []voters.TelegramVoterDTO) {
 	for i := range v {
 		j := rand.Intn(i + 1)
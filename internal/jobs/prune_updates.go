@@ -0,0 +1,50 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/nikitkaralius/lineup/internal/polls"
+	"github.com/nikitkaralius/lineup/internal/ratelimit"
+	"github.com/nikitkaralius/lineup/internal/updates"
+	"github.com/riverqueue/river"
+)
+
+// PruneUpdatesInterval is how often PruneUpdatesWorker runs.
+const PruneUpdatesInterval = time.Hour
+
+// PruneUpdatesWorker forgets handled updates and poll source messages once Telegram
+// can no longer redeliver them, and the send turns of chats the bot no longer sends to.
+type PruneUpdatesWorker struct {
+	river.WorkerDefaults[updates.PruneArgs]
+	updates *updates.Repository
+	polls   polls.Repository
+	turns   *ratelimit.Repository
+}
+
+func NewPruneUpdatesWorker(updates *updates.Repository, polls polls.Repository, turns *ratelimit.Repository) *PruneUpdatesWorker {
+	return &PruneUpdatesWorker{updates: updates, polls: polls, turns: turns}
+}
+
+func (w *PruneUpdatesWorker) Work(ctx context.Context, job *river.Job[updates.PruneArgs]) error {
+	before := time.Now().Add(-updates.Retention)
+
+	n, err := w.updates.DeleteProcessedBefore(ctx, before)
+	if err != nil {
+		return err
+	}
+	m, err := w.polls.DeleteSourcesBefore(ctx, before)
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "pruned processed updates and poll source messages", slog.Int64("updates", n), slog.Int64("sources", m), slog.Time("before", before))
+
+	idleBefore := time.Now().Add(-PruneUpdatesInterval)
+	k, err := w.turns.DeleteIdleBefore(ctx, idleBefore)
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "pruned idle send turns", slog.Int64("chats", k), slog.Time("before", idleBefore))
+	return nil
+}
@@ -0,0 +1,63 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/polls"
+	"github.com/riverqueue/river"
+)
+
+const (
+	// ReconcilePollsInterval is how often ReconcilePollsWorker runs.
+	ReconcilePollsInterval = 10 * time.Minute
+	// reconcileMaxAge is how long after its end a poll is still finished. Lineups of
+	// polls that could not be finished for longer than that are of no use anymore.
+	reconcileMaxAge = 24 * time.Hour
+)
+
+// ReconcilePollsWorker schedules a finish job for every poll that ended but is still
+// active or publishing: one whose job was discarded after its last attempt, or was
+// never inserted. Finish jobs are unique by poll and end time, so polls whose job is
+// still waiting or retrying are not scheduled twice.
+type ReconcilePollsWorker struct {
+	river.WorkerDefaults[polls.ReconcilePollsArgs]
+	polls polls.Repository
+}
+
+func NewReconcilePollsWorker(polls polls.Repository) *ReconcilePollsWorker {
+	return &ReconcilePollsWorker{polls: polls}
+}
+
+func (w *ReconcilePollsWorker) Work(ctx context.Context, job *river.Job[polls.ReconcilePollsArgs]) error {
+	client, err := river.ClientFromContextSafely[pgx.Tx](ctx)
+	if err != nil {
+		return fmt.Errorf("reconcile polls: %w", err)
+	}
+	service := polls.NewPollsService(client)
+
+	expired, err := w.polls.FindExpiredActivePolls(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	scheduled := 0
+	for _, p := range expired {
+		ctx := logging.With(ctx, logging.PollID(p.PollID), logging.ChatID(p.ChatID))
+		if now.Sub(p.EndsAt) > reconcileMaxAge {
+			slog.WarnContext(ctx, "poll was never finished, giving up", slog.Time("ends_at", p.EndsAt))
+			continue
+		}
+		args := polls.FinishPollArgs{PollID: p.PollID, ChatID: p.ChatID, MessageID: p.MessageID, Topic: p.Topic, EndsAt: p.EndsAt.UTC()}
+		if err := service.SchedulePollFinish(ctx, args, now); err != nil {
+			return err
+		}
+		scheduled++
+	}
+	slog.InfoContext(ctx, "reconciled unfinished polls", slog.Int("expired", len(expired)), slog.Int("scheduled", scheduled))
+	return nil
+}
@@ -10,7 +10,8 @@ import (
 	"github.com/firebase/genkit/go/ai"
 	"github.com/firebase/genkit/go/genkit"
 	"github.com/firebase/genkit/go/plugins/compat_oai/openai"
-	"github.com/nikitkaralius/lineup/internal/polls"
+	"github.com/nikitkaralius/lineup/internal/metrics"
+	"github.com/nikitkaralius/lineup/internal/tracing"
 	"github.com/openai/openai-go/option"
 )
 
@@ -20,27 +21,45 @@ type Client struct {
 	model  ai.Model
 }
 
-// NewClient creates a new LLM client with Genkit and Yandex GPT.
-// apiKey: Yandex Cloud API key
-// folderID: Yandex Cloud folder ID (e.g., "b1gqd6223i334ob9lgjc")
-func NewClient(ctx context.Context, apiKey, folderID string) (*Client, error) {
-	// Configure Yandex GPT options
+// Config selects the OpenAI-compatible API the client talks to.
+type Config struct {
+	BaseURL  string        // e.g. https://llm.api.cloud.yandex.net/v1
+	APIKey   string        // Yandex Cloud API key
+	FolderID string        // Yandex Cloud folder ID (e.g., "b1gqd6223i334ob9lgjc"); empty for other providers
+	Model    string        // model name, e.g. yandexgpt-lite/latest
+	Timeout  time.Duration // per request
+}
+
+// Defaults for Yandex GPT.
+const (
+	DefaultBaseURL = "https://llm.api.cloud.yandex.net/v1"
+	DefaultModel   = "yandexgpt-lite/latest"
+	DefaultTimeout = 15 * time.Second
+)
+
+// NewClient creates a new LLM client with Genkit, by default for Yandex GPT.
+func NewClient(ctx context.Context, cfg Config) (*Client, error) {
 	opts := []option.RequestOption{
-		option.WithAPIKey(apiKey),
-		option.WithBaseURL("https://llm.api.cloud.yandex.net/v1"),
-		option.WithHeader("OpenAI-Project", folderID),
-		option.WithRequestTimeout(15 * time.Second),
+		option.WithAPIKey(cfg.APIKey),
+		option.WithBaseURL(cfg.BaseURL),
+		option.WithRequestTimeout(cfg.Timeout),
+	}
+	if cfg.FolderID != "" {
+		opts = append(opts, option.WithHeader("OpenAI-Project", cfg.FolderID))
 	}
 
-	// Create OpenAI-compatible client with Yandex configuration
+	// Create OpenAI-compatible client
 	oai := &openai.OpenAI{
-		APIKey: apiKey,
+		APIKey: cfg.APIKey,
 		Opts:   opts,
 	}
 	g := genkit.Init(ctx, genkit.WithPlugins(oai))
 
-	// Use Yandex GPT model format: gpt://{folder_id}/{model_name}
-	modelName := fmt.Sprintf("gpt://%s/yandexgpt-lite/latest", folderID)
+	// Yandex GPT models are addressed as gpt://{folder_id}/{model_name}
+	modelName := cfg.Model
+	if cfg.FolderID != "" {
+		modelName = fmt.Sprintf("gpt://%s/%s", cfg.FolderID, cfg.Model)
+	}
 	model := oai.Model(g, modelName)
 
 	return &Client{
@@ -49,69 +68,80 @@ func NewClient(ctx context.Context, apiKey, folderID string) (*Client, error) {
 	}, nil
 }
 
+// generate sends prompt to the model, recording the latency and result of operation.
+func (c *Client) generate(ctx context.Context, operation, prompt string) (*ai.ModelResponse, error) {
+	ctx, span := tracing.Start(ctx, "llm."+operation)
+	start := time.Now()
+	resp, err := genkit.Generate(ctx, c.genkit,
+		ai.WithModel(c.model),
+		ai.WithPrompt(prompt),
+	)
+	tracing.End(span, err)
+	metrics.LLMDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	metrics.LLMRequests.WithLabelValues(operation, metrics.Result(err)).Inc()
+	return resp, err
+}
+
 // ParsePollIntent uses LLM to parse user intent for creating a poll.
+// Times are understood in loc, the chat's timezone.
 // Returns structured PollIntent or an error with helpful message.
-func (c *Client) ParsePollIntent(ctx context.Context, text string) (*PollIntent, error) {
-	now := time.Now()
-	moscowLocation, _ := time.LoadLocation("Europe/Moscow")
-	nowMoscow := now.In(moscowLocation)
-	currentYear := nowMoscow.Year()
-	currentDate := nowMoscow.Format("2006-01-02")
-	currentDateTime := nowMoscow.Format("2006-01-02 15:04")
+// If the request is understood but lacks the duration or the coming answer,
+// the returned error is an *IncompleteIntentError carrying the partial intent.
+func (c *Client) ParsePollIntent(ctx context.Context, text string, loc *time.Location) (*PollIntent, error) {
+	dc := newDateContext(loc)
 
 	prompt := fmt.Sprintf(`You are a helpful assistant that parses user requests for creating polls in Russian or English.
 
-CURRENT DATE CONTEXT: 
-- Today's date in Moscow timezone: %s
-- Current date and time in Moscow: %s MSK
-- Current year: %d
-Use this information when converting relative time references to absolute dates. When user says "15:08" or "до 15:08", they mean TODAY (%s) at 15:08 Moscow time, unless the time has already passed today (then use tomorrow).
+%[1]s
 
 The user wants to create a poll with:
 1. Topic (required) - what the poll is about
 2. End time OR Duration (at least one required):
-   - End time: when the poll should end in Moscow timezone (Europe/Moscow, UTC+3). 
+   - End time: when the poll should end in the chat's timezone (%[4]s, UTC%[5]s). 
      You MUST convert any relative time references (like "13:48", "tomorrow 13:48", "Monday 13:48", "next week", etc.) 
-     to an absolute ISO 8601 datetime string in Moscow timezone format: "%d-01-02T15:04:05+03:00"
-     Examples (today is %s):
-     * "13:48" or "end at 13:48" or "до 13:48" -> convert to TODAY (%s) at 13:48 Moscow time, unless 13:48 has already passed today (then use tomorrow)
-     * "tomorrow 13:48" or "завтра 13:48" -> convert to tomorrow's date at 13:48 Moscow time
-     * "Monday 13:48" or "понедельник 13:48" -> convert to next Monday at 13:48 Moscow time
+     to an absolute ISO 8601 datetime string in the chat's timezone format: "%[3]d-01-02T15:04:05%[5]s"
+     Examples (today is %[2]s):
+     * "13:48" or "end at 13:48" or "до 13:48" -> convert to TODAY (%[2]s) at 13:48 local time, unless 13:48 has already passed today (then use tomorrow)
+     * "tomorrow 13:48" or "завтра 13:48" -> convert to tomorrow's date at 13:48 local time
+     * "Monday 13:48" or "понедельник 13:48" -> convert to next Monday at 13:48 local time
    - Duration: how long the poll should last (e.g., "30m", "1h", "2h30m")
-3. Answers (optional) - custom poll answers. If not specified, use default: ["Иду", "Не иду"]
-4. Coming answer index (required if custom answers) - which answer index means "Иду" (0-based)
+3. Answers (optional) - custom poll answers. If not specified, omit them (the chat's default answers are used)
+4. Coming answer index (required if custom answers) - which answer index means going/attending (0-based)
+5. Session start and slot duration (optional) - when the session the lineup is for starts and how much time each person gets
+   (e.g. "занятие в 14:00, по 7 минут на человека" -> session_start "%[2]sT14:00:00%[5]s", slot_duration "7m").
+   session_start uses the same ISO 8601 local format as end_time.
 
 IMPORTANT: 
-- If user specifies an end time, ALWAYS return end_time as ISO 8601 format: "%d-01-02T15:04:05+03:00" (use current year %d and today's date %s if it's just a time like "15:08")
+- If user specifies an end time, ALWAYS return end_time as ISO 8601 format: "%[3]d-01-02T15:04:05%[5]s" (use current year %[3]d and today's date %[2]s if it's just a time like "15:08")
 - If user specifies duration, return duration field
 - If both are specified, prefer end_time
-- ALWAYS use year %d and today's date %s when converting simple times like "15:08" to absolute dates
+- If neither is specified, omit both fields (do NOT return an error, the user will be asked separately)
+- ALWAYS use year %[3]d and today's date %[2]s when converting simple times like "15:08" to absolute dates
 
-If the user specifies custom answers, you MUST identify which one means "Иду" (going/attending). 
-If you cannot determine which answer means "Иду", you must return an error message asking the user to specify explicitly.
+If the user specifies custom answers, you MUST identify which one means going/attending (like "Иду"). 
+If you cannot determine which answer means going, set coming_answer_index to -1 (the user will be asked separately).
 
 Parse the following user input and return ONLY valid JSON in this exact format (DO NOT wrap in markdown code blocks, return raw JSON only):
 {
   "topic": "string",
   "duration": "string (e.g., 30m, 1h)" (optional if end_time is provided),
-  "end_time": "string in ISO 8601 format: %d-01-02T15:04:05+03:00" (optional if duration is provided, MUST be in Moscow timezone, use year %d and today's date %s for simple times),
+  "end_time": "string in ISO 8601 format: %[3]d-01-02T15:04:05%[5]s" (optional if duration is provided, MUST be in the chat's timezone, use year %[3]d and today's date %[2]s for simple times),
   "answers": ["string"] (optional, omit if not specified),
-  "coming_answer_index": int (0-based index, required if answers are specified)
+  "coming_answer_index": int (0-based index, -1 if unknown, required if answers are specified),
+  "session_start": "string in ISO 8601 format: %[3]d-01-02T15:04:05%[5]s" (optional, omit if not specified),
+  "slot_duration": "string (e.g., 7m, 10m)" (optional, omit if not specified)
 }
 
 IMPORTANT: Return ONLY the raw JSON object, without any markdown formatting, code blocks, or additional text.
 
 If you cannot parse the intent, return ONLY an error message (not JSON) explaining:
-- What field is missing (topic, duration/end_time, or coming_answer_index if custom answers)
+- What field is missing (topic)
 - What the user should add to their request
 - Examples of correct formats
 
-User input: `, currentDate, currentDateTime, currentYear, currentDate, currentYear, currentDate, currentDate, currentYear, currentYear, currentDate, currentYear, currentYear, currentDate) + text
+User input: `, dc.prompt(), dc.date, dc.year, dc.zone, dc.offset) + text
 
-	resp, err := genkit.Generate(ctx, c.genkit,
-		ai.WithModel(c.model),
-		ai.WithPrompt(prompt),
-	)
+	resp, err := c.generate(ctx, "parse_poll_intent", prompt)
 	if err != nil {
 		return nil, fmt.Errorf("LLM request failed: %w", err)
 	}
@@ -124,34 +154,136 @@ User input: `, currentDate, currentDateTime, currentYear, currentDate, currentYe
 	// Check if LLM returned an error message instead of JSON
 	// If content doesn't start with {, it's likely an error message
 	if !strings.HasPrefix(strings.TrimSpace(content), "{") {
-		return nil, fmt.Errorf("%s\n\nПримеры правильного формата:\n/poll Тема | 30m\n/poll Тема | до 13:48\n/poll Тема | завтра 13:48", content)
+		return nil, &ExplanationError{Message: content}
 	}
 
-	var intent PollIntent
+	// An omitted coming_answer_index must not silently become the first answer
+	intent := PollIntent{ComingAnswerIndex: -1}
 	if err := json.Unmarshal([]byte(content), &intent); err != nil {
 		return nil, fmt.Errorf("failed to parse LLM response as JSON: %w. Response: %s", err, content)
 	}
 
-	// Validate required fields
+	// Default answers are filled in by the caller in the chat's language
+	if len(intent.Answers) == 0 {
+		intent.ComingAnswerIndex = 0
+	}
+
+	if err := ValidatePollIntent(&intent); err != nil {
+		return nil, err
+	}
+
+	return &intent, nil
+}
+
+// ValidatePollIntent checks that the intent has everything needed to create a poll.
+// Missing duration or coming answer is reported as *IncompleteIntentError.
+// Empty answers mean the default ones, whose coming answer is known.
+func ValidatePollIntent(intent *PollIntent) error {
 	if intent.Topic == "" {
-		return nil, fmt.Errorf("❌ Тема опроса не указана.\n\nЧто добавить: укажите тему опроса\nПримеры:\n/poll Математика | 30m\n/poll Практика | до 13:48")
+		return ErrMissingTopic
 	}
 	if intent.Duration == "" && intent.EndTime == "" {
-		return nil, fmt.Errorf("❌ Не указана длительность или время окончания опроса.\n\nЧто добавить: укажите длительность (например, 30m, 1h) или время окончания (например, до 13:48, завтра 13:48)\nПримеры:\n/poll Тема | 30m\n/poll Тема | до 13:48\n/poll Тема | завтра 13:48")
+		return &IncompleteIntentError{Intent: *intent, Missing: MissingDuration}
+	}
+	if len(intent.Answers) > 0 && (intent.ComingAnswerIndex < 0 || intent.ComingAnswerIndex >= len(intent.Answers)) {
+		return &IncompleteIntentError{Intent: *intent, Missing: MissingComingAnswer}
 	}
+	return nil
+}
 
-	// Set defaults if answers not specified
-	if len(intent.Answers) == 0 {
-		intent.Answers = polls.DefaultPollAnswers
-		intent.ComingAnswerIndex = polls.DefaultComingAnswerIndex
-	} else {
-		// Validate coming_answer_index if answers are specified
-		if intent.ComingAnswerIndex < 0 || intent.ComingAnswerIndex >= len(intent.Answers) {
-			return nil, fmt.Errorf("❌ Не указано, какой вариант ответа означает 'Иду'.\n\nЧто добавить: явно укажите в запросе, какой вариант означает, что человек идет\nПример: /poll Тема | 30m | Иду, Не иду (первый вариант - иду)")
+// ParseClarification uses LLM to extract the missing field from the user's answer
+// to a clarifying question and merges it into a copy of intent. Times are understood in loc.
+func (c *Client) ParseClarification(ctx context.Context, intent PollIntent, missing MissingField, text string, loc *time.Location) (*PollIntent, error) {
+	var prompt string
+	switch missing {
+	case MissingDuration:
+		dc := newDateContext(loc)
+		prompt = fmt.Sprintf(`You are a helpful assistant. The user was asked until when a poll should run and answered in Russian or English.
+
+%[1]s
+
+Return ONLY valid JSON in this exact format (DO NOT wrap in markdown code blocks, return raw JSON only):
+{
+  "duration": "string (e.g., 30m, 1h, 2h30m)" (omit if the user gave an end time),
+  "end_time": "string in ISO 8601 format: %[2]d-01-02T15:04:05%[3]s" (omit if the user gave a duration, MUST be in the chat's timezone)
+}
+
+If the answer contains neither a duration nor an end time, return an empty JSON object {}.
+
+User answer: `, dc.prompt(), dc.year, dc.offset) + text
+	case MissingComingAnswer:
+		answers, _ := json.Marshal(intent.Answers)
+		prompt = fmt.Sprintf(`You are a helpful assistant. The poll has these answers (0-based): %s
+The user was asked which answer means "going/attending" and answered in Russian or English.
+
+Return ONLY valid JSON in this exact format (DO NOT wrap in markdown code blocks, return raw JSON only):
+{
+  "coming_answer_index": int (0-based index into the answers, -1 if the answer is unclear)
+}
+
+User answer: `, answers) + text
+	default:
+		return nil, fmt.Errorf("unknown missing field %q", missing)
+	}
+
+	resp, err := c.generate(ctx, "parse_clarification", prompt)
+	if err != nil {
+		return nil, fmt.Errorf("LLM request failed: %w", err)
+	}
+
+	content := stripMarkdownCodeBlocks(resp.Text())
+
+	merged := intent
+	switch missing {
+	case MissingDuration:
+		var v struct {
+			Duration string `json:"duration"`
+			EndTime  string `json:"end_time"`
+		}
+		if err := json.Unmarshal([]byte(content), &v); err != nil {
+			return nil, fmt.Errorf("failed to parse LLM response: %w. Response: %s", err, content)
 		}
+		merged.Duration, merged.EndTime = v.Duration, v.EndTime
+	case MissingComingAnswer:
+		v := struct {
+			ComingAnswerIndex int `json:"coming_answer_index"`
+		}{ComingAnswerIndex: -1}
+		if err := json.Unmarshal([]byte(content), &v); err != nil {
+			return nil, fmt.Errorf("failed to parse LLM response: %w. Response: %s", err, content)
+		}
+		merged.ComingAnswerIndex = v.ComingAnswerIndex
 	}
 
-	return &intent, nil
+	return &merged, nil
+}
+
+// dateContext describes "now" in the chat's timezone for prompts that deal with relative times.
+type dateContext struct {
+	zone     string // IANA name, e.g. Europe/Moscow
+	offset   string // current UTC offset, e.g. +03:00
+	date     string
+	dateTime string
+	year     int
+}
+
+func newDateContext(loc *time.Location) dateContext {
+	now := time.Now().In(loc)
+	return dateContext{
+		zone:     loc.String(),
+		offset:   now.Format("-07:00"),
+		date:     now.Format("2006-01-02"),
+		dateTime: now.Format("2006-01-02 15:04 MST"),
+		year:     now.Year(),
+	}
+}
+
+func (dc dateContext) prompt() string {
+	return fmt.Sprintf(`CURRENT DATE CONTEXT: 
+- The chat's timezone: %[4]s (UTC%[5]s)
+- Today's date in the chat's timezone: %[1]s
+- Current date and time in the chat's timezone: %[2]s
+- Current year: %[3]d
+Use this information when converting relative time references to absolute dates. When user says "15:08" or "до 15:08", they mean TODAY (%[1]s) at 15:08 in the chat's timezone, unless the time has already passed today (then use tomorrow).`, dc.date, dc.dateTime, dc.year, dc.zone, dc.offset)
 }
 
 // stripMarkdownCodeBlocks removes markdown code block markers from the content.
@@ -197,10 +329,7 @@ Common Russian phrases:
 
 User input: ` + text
 
-	resp, err := genkit.Generate(ctx, c.genkit,
-		ai.WithModel(c.model),
-		ai.WithPrompt(prompt),
-	)
+	resp, err := c.generate(ctx, "parse_queue_intent", prompt)
 	if err != nil {
 		return nil, fmt.Errorf("LLM request failed: %w", err)
 	}
@@ -216,7 +345,7 @@ User input: ` + text
 	}
 
 	if intent.Action != "join" && intent.Action != "leave" {
-		return nil, fmt.Errorf("не могу определить действие. Используйте: 'хочу в очередь' или 'выхожу из очереди'")
+		return nil, ErrUnknownQueueAction
 	}
 
 	return &intent, nil
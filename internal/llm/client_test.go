@@ -0,0 +1,32 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDateContextUsesChatTimezone(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	dc := newDateContext(loc)
+	now := time.Now().In(loc)
+
+	if want := now.Format("-07:00"); dc.offset != want {
+		t.Errorf("offset = %s, want %s", dc.offset, want)
+	}
+	if want := now.Format("2006-01-02"); dc.date != want {
+		t.Errorf("date = %s, want today in London %s", dc.date, want)
+	}
+	prompt := dc.prompt()
+	for _, want := range []string{"Europe/London", "UTC" + dc.offset, dc.date} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt does not mention %s:\n%s", want, prompt)
+		}
+	}
+	if strings.Contains(prompt, "Moscow") {
+		t.Errorf("prompt of a London chat mentions Moscow:\n%s", prompt)
+	}
+}
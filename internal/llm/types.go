@@ -1,15 +1,54 @@
 package llm
 
+import (
+	"errors"
+	"fmt"
+)
+
 // PollIntent represents the parsed intent from user input for creating a poll.
 type PollIntent struct {
 	Topic             string   `json:"topic"`
-	Duration          string   `json:"duration,omitempty"`  // e.g., "30m", "1h", "2h30m" (optional if end_time is provided)
-	EndTime           string   `json:"end_time,omitempty"`  // ISO 8601 format in Moscow timezone, e.g., "2024-01-15T13:48:00+03:00" or "13:48" (today), "tomorrow 13:48", "Monday 13:48"
-	Answers           []string `json:"answers,omitempty"`   // Optional custom answers
-	ComingAnswerIndex int      `json:"coming_answer_index"` // Index of answer that means "coming"
+	Duration          string   `json:"duration,omitempty"`      // e.g., "30m", "1h", "2h30m" (optional if end_time is provided)
+	EndTime           string   `json:"end_time,omitempty"`      // ISO 8601 format in the chat's timezone, e.g., "2024-01-15T13:48:00+03:00" or "13:48" (today), "tomorrow 13:48", "Monday 13:48"
+	Answers           []string `json:"answers,omitempty"`       // Optional custom answers
+	ComingAnswerIndex int      `json:"coming_answer_index"`     // Index of answer that means "coming"
+	SessionStart      string   `json:"session_start,omitempty"` // Optional ISO 8601 start of the session the lineup is for, in the chat's timezone
+	SlotDuration      string   `json:"slot_duration,omitempty"` // Optional time each person gets, e.g. "7m"
 }
 
 // QueueIntent represents the parsed intent for queue operations.
 type QueueIntent struct {
 	Action string `json:"action"` // "join" or "leave"
 }
+
+// MissingField names a poll intent field that the user has to clarify.
+type MissingField string
+
+const (
+	MissingDuration     MissingField = "duration"            // neither duration nor end_time given
+	MissingComingAnswer MissingField = "coming_answer_index" // custom answers without a "coming" one
+)
+
+// IncompleteIntentError is returned when a poll intent lacks a field the user can provide later.
+type IncompleteIntentError struct {
+	Intent  PollIntent
+	Missing MissingField
+}
+
+func (e *IncompleteIntentError) Error() string {
+	return fmt.Sprintf("poll intent is missing %s", e.Missing)
+}
+
+// ExplanationError carries the LLM's own explanation of why a request could not be parsed.
+type ExplanationError struct {
+	Message string
+}
+
+func (e *ExplanationError) Error() string {
+	return e.Message
+}
+
+var (
+	ErrMissingTopic       = errors.New("poll topic is missing")
+	ErrUnknownQueueAction = errors.New("unknown queue action")
+)
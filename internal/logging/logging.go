@@ -0,0 +1,111 @@
+// Package logging configures log/slog for JSON output and carries correlation
+// attributes, such as the chat and poll being handled, in contexts.
+//
+// Log with the slog *Context functions so that the attributes added with With
+// appear on every line:
+//
+//	ctx = logging.With(ctx, logging.ChatID(chatID))
+//	slog.ErrorContext(ctx, "send reply failed", logging.Err(err))
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Setup makes a JSON logger writing to w at level the default one, also for the
+// standard log package and tgbotapi's debug output.
+func Setup(w io.Writer, level slog.Level) {
+	handler := &contextHandler{Handler: slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})}
+	slog.SetDefault(slog.New(handler))
+}
+
+// Fatal logs msg at error level and exits, for startup failures in main.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// ParseLevel parses debug, info, warn or error.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q, want debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// Attributes with the keys used across the code base; filter logs by chat, poll, user or update with them.
+func ChatID(id int64) slog.Attr    { return slog.Int64("chat_id", id) }
+func PollID(id string) slog.Attr   { return slog.String("poll_id", id) }
+func UserID(id int64) slog.Attr    { return slog.Int64("user_id", id) }
+func UpdateID(id int) slog.Attr    { return slog.Int("update_id", id) }
+func JobID(id int64) slog.Attr     { return slog.Int64("job_id", id) }
+func Err(err error) slog.Attr      { return slog.Any("error", err) }
+func Component(c string) slog.Attr { return slog.String("component", c) }
+
+type attrsKey struct{}
+
+// With returns a copy of ctx whose log lines carry attrs, in addition to those already added.
+// An attribute with the same key replaces the earlier one.
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	prev, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(prev)+len(attrs))
+	for _, a := range prev {
+		if !hasKey(attrs, a.Key) {
+			merged = append(merged, a)
+		}
+	}
+	return context.WithValue(ctx, attrsKey{}, append(merged, attrs...))
+}
+
+func hasKey(attrs []slog.Attr, key string) bool {
+	for _, a := range attrs {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// contextHandler adds the attributes stored in the record's context.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// BotLogger adapts slog to tgbotapi.BotLogger. tgbotapi logs requests and
+// responses when BotAPI.Debug is set, which the service does at debug level.
+type BotLogger struct{}
+
+func (BotLogger) Println(v ...any) {
+	slog.Debug(strings.TrimSuffix(fmt.Sprintln(v...), "\n"), Component("tgbotapi"))
+}
+
+func (BotLogger) Printf(format string, v ...any) {
+	slog.Debug(fmt.Sprintf(format, v...), Component("tgbotapi"))
+}
+
+// StdLogger returns a standard logger writing to slog at level, for libraries such as net/http.
+func StdLogger(level slog.Level) *log.Logger {
+	return slog.NewLogLogger(slog.Default().Handler(), level)
+}
@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestContextAttrs(t *testing.T) {
+	buf := bytes.Buffer{}
+	prev := slog.Default()
+	defer slog.SetDefault(prev)
+	Setup(&buf, slog.LevelInfo)
+
+	ctx := With(context.Background(), ChatID(1), PollID("p1"))
+	ctx = With(ctx, PollID("p2"), UserID(3))
+	slog.DebugContext(ctx, "hidden")
+	slog.InfoContext(ctx, "shown", slog.String("extra", "x"))
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("want exactly one JSON line, got %q: %v", buf.String(), err)
+	}
+	want := map[string]any{"msg": "shown", "chat_id": 1.0, "poll_id": "p2", "user_id": 3.0, "extra": "x"}
+	for k, v := range want {
+		if line[k] != v {
+			t.Errorf("%s = %v, want %v", k, line[k], v)
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, s := range []string{"debug", "INFO", "warn", "error"} {
+		if _, err := ParseLevel(s); err != nil {
+			t.Errorf("ParseLevel(%q): %v", s, err)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("ParseLevel(verbose) succeeded")
+	}
+}
@@ -0,0 +1,159 @@
+package messenger
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+// Fake is an in-memory Messenger that records everything sent through it,
+// so that tests can assert on exactly what the bot would have sent.
+// Message IDs are assigned from 1 in sending order across all chats.
+type Fake struct {
+	mu sync.Mutex
+
+	BotUsername string
+	Messages    []Message
+	Polls       []SentFakePoll
+	Stopped     []Sent
+	Edits       []Edit
+	Callbacks   []Callback
+	Documents   []Document
+	Admins      map[int64][]int64 // chat ID to administrator user IDs
+
+	// Err, if set, is returned by the next call instead of sending anything.
+	Err error
+
+	lastID int
+}
+
+// SentFakePoll is a poll recorded by Fake.
+type SentFakePoll struct {
+	Poll
+	SentPoll
+}
+
+// Callback is a callback answer recorded by Fake.
+type Callback struct {
+	ID   string
+	Text string
+}
+
+var _ Messenger = (*Fake)(nil)
+
+// NewFake creates an empty fake messenger for a bot with the given username.
+func NewFake(username string) *Fake {
+	return &Fake{BotUsername: username, Admins: make(map[int64][]int64)}
+}
+
+func (f *Fake) Username() string {
+	return f.BotUsername
+}
+
+func (f *Fake) SendMessage(ctx context.Context, msg Message) (Sent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.takeErr(); err != nil {
+		return Sent{}, err
+	}
+	f.Messages = append(f.Messages, msg)
+	return f.next(msg.ChatID), nil
+}
+
+func (f *Fake) SendPoll(ctx context.Context, poll Poll) (SentPoll, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.takeErr(); err != nil {
+		return SentPoll{}, err
+	}
+	sent := SentPoll{Sent: f.next(poll.ChatID)}
+	sent.PollID = "poll-" + strconv.Itoa(sent.MessageID)
+	f.Polls = append(f.Polls, SentFakePoll{Poll: poll, SentPoll: sent})
+	return sent, nil
+}
+
+func (f *Fake) StopPoll(ctx context.Context, chatID int64, messageID int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.takeErr(); err != nil {
+		return err
+	}
+	f.Stopped = append(f.Stopped, Sent{ChatID: chatID, MessageID: messageID})
+	return nil
+}
+
+func (f *Fake) EditMessage(ctx context.Context, edit Edit) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.takeErr(); err != nil {
+		return err
+	}
+	f.Edits = append(f.Edits, edit)
+	return nil
+}
+
+func (f *Fake) AnswerCallback(ctx context.Context, callbackID, text string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.takeErr(); err != nil {
+		return err
+	}
+	f.Callbacks = append(f.Callbacks, Callback{ID: callbackID, Text: text})
+	return nil
+}
+
+func (f *Fake) SendDocument(ctx context.Context, doc Document) (Sent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.takeErr(); err != nil {
+		return Sent{}, err
+	}
+	f.Documents = append(f.Documents, doc)
+	return f.next(doc.ChatID), nil
+}
+
+func (f *Fake) IsChatAdmin(ctx context.Context, chatID, userID int64) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.takeErr(); err != nil {
+		return false, err
+	}
+	for _, id := range f.Admins[chatID] {
+		if id == userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// MessagesTo returns the texts of messages sent to chatID in order.
+func (f *Fake) MessagesTo(chatID int64) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var texts []string
+	for _, m := range f.Messages {
+		if m.ChatID == chatID {
+			texts = append(texts, m.Text)
+		}
+	}
+	return texts
+}
+
+// Reset forgets everything recorded so far. Message IDs keep increasing.
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Messages, f.Polls, f.Stopped, f.Edits, f.Callbacks, f.Documents = nil, nil, nil, nil, nil, nil
+	f.Err = nil
+}
+
+func (f *Fake) next(chatID int64) Sent {
+	f.lastID++
+	return Sent{ChatID: chatID, MessageID: f.lastID}
+}
+
+func (f *Fake) takeErr() error {
+	err := f.Err
+	f.Err = nil
+	return err
+}
@@ -0,0 +1,91 @@
+package messenger
+
+import (
+	"context"
+	"errors"
+)
+
+// Parse modes of message text.
+const (
+	ModePlain = ""
+	ModeHTML  = "HTML"
+)
+
+// Errors StopPoll wraps, so that callers can tell why a poll could not be stopped.
+var (
+	// ErrMessageNotFound is returned when the poll's message was deleted.
+	ErrMessageNotFound = errors.New("message not found")
+	// ErrPollClosed is returned when the poll was already stopped.
+	ErrPollClosed = errors.New("poll is already closed")
+)
+
+// ErrBadMarkup is wrapped by SendMessage and EditMessage when the text's markup was rejected.
+var ErrBadMarkup = errors.New("text markup rejected")
+
+// Messenger is everything the bot sends to a chat platform. Handlers, services and
+// workers depend on it instead of a concrete client, so that the core is not tied to
+// Telegram and tests can record what would have been sent.
+type Messenger interface {
+	// Username returns the bot's own username without "@".
+	Username() string
+	// SendMessage sends a text message. It returns an error wrapping ErrBadMarkup if the text
+	// does not parse in its parse mode; EditMessage does the same.
+	SendMessage(ctx context.Context, msg Message) (Sent, error)
+	SendPoll(ctx context.Context, poll Poll) (SentPoll, error)
+	// StopPoll closes a poll. It returns an error wrapping ErrMessageNotFound if the poll's
+	// message was deleted and ErrPollClosed if the poll was already stopped.
+	StopPoll(ctx context.Context, chatID int64, messageID int) error
+	EditMessage(ctx context.Context, edit Edit) error
+	AnswerCallback(ctx context.Context, callbackID, text string) error
+	SendDocument(ctx context.Context, doc Document) (Sent, error)
+	IsChatAdmin(ctx context.Context, chatID, userID int64) (bool, error)
+}
+
+// Message is a text message to send.
+type Message struct {
+	ChatID                int64
+	ThreadID              int // forum topic to post in, 0 for the chat's General topic
+	Text                  string
+	ParseMode             string
+	ReplyToMessageID      int  // 0 for no reply
+	ForceReply            bool // ask the addressed user's client to open a reply to this message
+	DisableWebPagePreview bool
+}
+
+// Poll is a non-anonymous single-answer poll to send.
+type Poll struct {
+	ChatID   int64
+	ThreadID int // forum topic to post in
+	Question string
+	Options  []string
+}
+
+// Edit replaces the text of a sent message.
+type Edit struct {
+	ChatID    int64
+	MessageID int
+	Text      string
+	ParseMode string
+}
+
+// Document is a file to send.
+type Document struct {
+	ChatID           int64
+	ThreadID         int // forum topic to post in
+	FileName         string
+	Data             []byte
+	Caption          string
+	ReplyToMessageID int
+}
+
+// Sent identifies a sent message.
+type Sent struct {
+	ChatID    int64
+	MessageID int
+}
+
+// SentPoll identifies a sent poll.
+type SentPoll struct {
+	Sent
+	PollID string
+}
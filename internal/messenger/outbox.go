@@ -0,0 +1,322 @@
+package messenger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/metrics"
+)
+
+// Limits are the send rates an Outbox keeps to. Telegram allows about 30 messages
+// a second overall, one a second to a private chat and 20 a minute to a group.
+type Limits struct {
+	PerSecond     int           // requests a second across all chats
+	ChatInterval  time.Duration // between messages to one private chat
+	GroupInterval time.Duration // between messages to one group or channel
+}
+
+// outboxRetries is how often a rate limited request is sent again before its error is returned.
+const outboxRetries = 3
+
+// Turns hands out the turns of Bot API requests. All processes of a bot share them,
+// so that together they keep to the limits.
+type Turns interface {
+	// Take takes the global turn and, for a non-zero chatID, the turn of the chat if both
+	// are due, making the next ones due after global and chat. Otherwise it takes nothing
+	// and returns how long to wait before asking again.
+	Take(ctx context.Context, chatID int64, global, chat time.Duration) (time.Duration, error)
+	// Pause makes requests to chatID, or all requests for a zero chatID, wait at least d.
+	Pause(ctx context.Context, chatID int64, d time.Duration) error
+}
+
+// Outbox is a Messenger that queues everything sent through it to stay within Telegram's
+// rate limits. Calls wait for their turn, globally and in their chat, and requests Telegram
+// rejects with 429 Too Many Requests are sent again after the retry_after it asks for.
+// Edits of a message that are still waiting in this process are coalesced: the last text
+// is sent once and all of them return its result.
+type Outbox struct {
+	Messenger
+	limits Limits
+	turns  Turns
+
+	mu    sync.Mutex
+	edits map[editKey]*pendingEdit // edits waiting for their turn
+}
+
+type editKey struct {
+	chatID    int64
+	messageID int
+}
+
+// pendingEdit is an edit waiting for its turn. Later edits of the message replace its
+// text and wait for done. It is sent as long as any of its callers waits.
+type pendingEdit struct {
+	edit    Edit
+	waiters int                // callers waiting for done
+	cancel  context.CancelFunc // stops sending once no caller waits
+	done    chan struct{}
+	err     error
+}
+
+var _ Messenger = (*Outbox)(nil)
+
+// NewOutbox creates an Outbox sending through m within limits, taking turns from turns.
+func NewOutbox(m Messenger, limits Limits, turns Turns) *Outbox {
+	return &Outbox{
+		Messenger: m,
+		limits:    limits,
+		turns:     turns,
+		edits:     make(map[editKey]*pendingEdit),
+	}
+}
+
+func (o *Outbox) SendMessage(ctx context.Context, msg Message) (Sent, error) {
+	var sent Sent
+	err := o.do(ctx, msg.ChatID, func() (err error) {
+		sent, err = o.Messenger.SendMessage(ctx, msg)
+		return err
+	})
+	return sent, err
+}
+
+func (o *Outbox) SendPoll(ctx context.Context, poll Poll) (SentPoll, error) {
+	var sent SentPoll
+	err := o.do(ctx, poll.ChatID, func() (err error) {
+		sent, err = o.Messenger.SendPoll(ctx, poll)
+		return err
+	})
+	return sent, err
+}
+
+func (o *Outbox) StopPoll(ctx context.Context, chatID int64, messageID int) error {
+	return o.do(ctx, chatID, func() error {
+		return o.Messenger.StopPoll(ctx, chatID, messageID)
+	})
+}
+
+func (o *Outbox) SendDocument(ctx context.Context, doc Document) (Sent, error) {
+	var sent Sent
+	err := o.do(ctx, doc.ChatID, func() (err error) {
+		sent, err = o.Messenger.SendDocument(ctx, doc)
+		return err
+	})
+	return sent, err
+}
+
+// AnswerCallback is only limited globally, the answer is not a message in the chat.
+func (o *Outbox) AnswerCallback(ctx context.Context, callbackID, text string) error {
+	return o.do(ctx, 0, func() error {
+		return o.Messenger.AnswerCallback(ctx, callbackID, text)
+	})
+}
+
+// EditMessage joins a waiting edit of the same message if there is one, so that only the
+// latest text is sent. If the edit that is sent fails, all edits it replaced return its error.
+// The edit is sent on its own, so that a caller giving up does not fail the others; it is
+// dropped only once all of them gave up.
+func (o *Outbox) EditMessage(ctx context.Context, edit Edit) error {
+	key := editKey{chatID: edit.ChatID, messageID: edit.MessageID}
+	o.mu.Lock()
+	p, ok := o.edits[key]
+	if ok {
+		p.edit = edit
+		metrics.TelegramEditsCoalesced.Inc()
+	} else {
+		sendCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		p = &pendingEdit{edit: edit, cancel: cancel, done: make(chan struct{})}
+		o.edits[key] = p
+		go o.sendEdit(sendCtx, key, p)
+	}
+	p.waiters++
+	o.mu.Unlock()
+
+	select {
+	case <-p.done:
+		return p.err
+	case <-ctx.Done():
+		o.mu.Lock()
+		p.waiters--
+		if p.waiters == 0 {
+			// Nobody waits for the edit any more: drop it, later edits start over
+			p.cancel()
+			if o.edits[key] == p {
+				delete(o.edits, key)
+			}
+		}
+		o.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// sendEdit sends the latest text of p in its turn and hands the result to its callers.
+func (o *Outbox) sendEdit(ctx context.Context, key editKey, p *pendingEdit) {
+	defer p.cancel()
+	err := o.do(ctx, key.chatID, func() error {
+		o.mu.Lock()
+		if o.edits[key] != p {
+			// Rate limited before, and a newer edit is waiting now that will send the latest text
+			o.mu.Unlock()
+			return nil
+		}
+		delete(o.edits, key)
+		edit := p.edit
+		o.mu.Unlock()
+
+		err := o.Messenger.EditMessage(ctx, edit)
+		if _, limited := retryAfter(err); limited {
+			// Wait again, taking edits that come in meanwhile
+			o.mu.Lock()
+			if _, ok := o.edits[key]; !ok && ctx.Err() == nil {
+				o.edits[key] = p
+			}
+			o.mu.Unlock()
+		}
+		return err
+	})
+
+	o.mu.Lock()
+	if o.edits[key] == p {
+		delete(o.edits, key)
+	}
+	p.err = err
+	o.mu.Unlock()
+	close(p.done)
+}
+
+// do calls send in chatID's turn, or in the global one for a zero chatID, and calls it again
+// after the wait Telegram asks for when it is rate limited.
+func (o *Outbox) do(ctx context.Context, chatID int64, send func() error) error {
+	for attempt := 0; ; attempt++ {
+		if err := o.wait(ctx, chatID); err != nil {
+			return err
+		}
+		err := send()
+		d, limited := retryAfter(err)
+		if !limited {
+			return err
+		}
+		metrics.TelegramRateLimited.Inc()
+		if err := o.turns.Pause(ctx, chatID, d); err != nil {
+			slog.WarnContext(ctx, "pause telegram requests failed", logging.ChatID(chatID), logging.Err(err))
+		}
+		if attempt == outboxRetries {
+			return err
+		}
+		slog.WarnContext(ctx, "telegram rate limit hit, sending again", logging.ChatID(chatID), slog.Duration("retry_after", d))
+	}
+}
+
+// wait blocks until a request to chatID may be sent and takes the turn.
+func (o *Outbox) wait(ctx context.Context, chatID int64) error {
+	metrics.TelegramOutboxDepth.Inc()
+	defer metrics.TelegramOutboxDepth.Dec()
+	var global time.Duration
+	if o.limits.PerSecond > 0 {
+		global = time.Second / time.Duration(o.limits.PerSecond)
+	}
+	for {
+		d, err := o.turns.Take(ctx, chatID, global, o.interval(chatID))
+		if err != nil {
+			return fmt.Errorf("take telegram send turn: %w", err)
+		}
+		if d <= 0 {
+			return nil
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// interval returns the time between messages to chatID. Groups and channels have negative IDs.
+func (o *Outbox) interval(chatID int64) time.Duration {
+	if chatID < 0 {
+		return o.limits.GroupInterval
+	}
+	return o.limits.ChatInterval
+}
+
+// MemoryTurns are Turns kept in memory, for a single process and for tests.
+type MemoryTurns struct {
+	mu    sync.Mutex
+	next  time.Time           // earliest time of the next request
+	chats map[int64]time.Time // chat ID to the earliest time of its next message
+}
+
+var _ Turns = (*MemoryTurns)(nil)
+
+// chatsPruneSize is the number of remembered chats above which those free to send are forgotten.
+const chatsPruneSize = 1024
+
+// NewMemoryTurns creates Turns that are all due.
+func NewMemoryTurns() *MemoryTurns {
+	return &MemoryTurns{chats: make(map[int64]time.Time)}
+}
+
+func (t *MemoryTurns) Take(ctx context.Context, chatID int64, global, chat time.Duration) (time.Duration, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	at := t.next
+	if chatID != 0 && t.chats[chatID].After(at) {
+		at = t.chats[chatID]
+	}
+	if at.After(now) {
+		return at.Sub(now), nil
+	}
+
+	t.next = now.Add(global)
+	if chatID != 0 {
+		if len(t.chats) >= chatsPruneSize {
+			for id, next := range t.chats {
+				if !next.After(now) {
+					delete(t.chats, id)
+				}
+			}
+		}
+		t.chats[chatID] = now.Add(chat)
+	}
+	return 0, nil
+}
+
+func (t *MemoryTurns) Pause(ctx context.Context, chatID int64, d time.Duration) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	until := time.Now().Add(d)
+	if chatID == 0 {
+		t.next = later(t.next, until)
+		return nil
+	}
+	t.chats[chatID] = later(t.chats[chatID], until)
+	return nil
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// retryAfter reports whether err is Telegram's 429 Too Many Requests and how long it asks to wait.
+func retryAfter(err error) (time.Duration, bool) {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if apiErr.RetryAfter <= 0 {
+		return time.Second, true
+	}
+	return time.Duration(apiErr.RetryAfter) * time.Second, true
+}
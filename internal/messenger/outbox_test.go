@@ -0,0 +1,174 @@
+package messenger
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	group   int64 = -100
+	private int64 = 1
+)
+
+func TestOutboxChatInterval(t *testing.T) {
+	fake := NewFake("bot")
+	o := NewOutbox(fake, Limits{PerSecond: 1000, ChatInterval: 10 * time.Millisecond, GroupInterval: 50 * time.Millisecond}, NewMemoryTurns())
+	ctx := context.Background()
+
+	start := time.Now()
+	for range 3 {
+		if _, err := o.SendMessage(ctx, Message{ChatID: group, Text: "hi"}); err != nil {
+			t.Fatalf("SendMessage: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("three messages to a group took %v, want at least two group intervals", elapsed)
+	}
+
+	// Another chat does not wait for the group
+	start = time.Now()
+	if _, err := o.SendMessage(ctx, Message{ChatID: private, Text: "hi"}); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 50*time.Millisecond {
+		t.Errorf("message to another chat took %v, want no wait", elapsed)
+	}
+}
+
+func TestOutboxRetryAfter(t *testing.T) {
+	fake := NewFake("bot")
+	o := NewOutbox(fake, Limits{PerSecond: 1000}, NewMemoryTurns())
+	fake.Err = &tgbotapi.Error{Code: http.StatusTooManyRequests, Message: "Too Many Requests: retry after 1", ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 1}}
+
+	start := time.Now()
+	if _, err := o.SendMessage(context.Background(), Message{ChatID: group, Text: "late"}); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("sent again after %v, want the retry_after of a second", elapsed)
+	}
+	if len(fake.Messages) != 1 {
+		t.Errorf("messages = %+v, want one", fake.Messages)
+	}
+}
+
+func TestOutboxCoalescesEdits(t *testing.T) {
+	fake := NewFake("bot")
+	o := NewOutbox(fake, Limits{PerSecond: 1000, GroupInterval: time.Second}, NewMemoryTurns())
+	ctx := context.Background()
+
+	// The message takes the group's turn, so the edits have to wait for the next one
+	sent, err := o.SendMessage(ctx, Message{ChatID: group, Text: "queue"})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	key := editKey{chatID: group, messageID: sent.MessageID}
+	waiting := func(text string) {
+		t.Helper()
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			o.mu.Lock()
+			p := o.edits[key]
+			ok := p != nil && p.edit.Text == text
+			o.mu.Unlock()
+			if ok {
+				return
+			}
+		}
+		t.Fatalf("edit %q is not waiting", text)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i, text := range []string{"one", "two", "three"} {
+		wg.Go(func() {
+			errs[i] = o.EditMessage(ctx, Edit{ChatID: group, MessageID: sent.MessageID, Text: text})
+		})
+		waiting(text)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("edit %d: %v", i, err)
+		}
+	}
+	if len(fake.Edits) != 1 || fake.Edits[0].Text != "three" {
+		t.Errorf("edits = %+v, want only the last one", fake.Edits)
+	}
+}
+
+func TestOutboxContextCancelled(t *testing.T) {
+	fake := NewFake("bot")
+	o := NewOutbox(fake, Limits{PerSecond: 1000, GroupInterval: time.Minute}, NewMemoryTurns())
+	if _, err := o.SendMessage(context.Background(), Message{ChatID: group, Text: "first"}); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := o.SendMessage(ctx, Message{ChatID: group, Text: "second"}); err != context.DeadlineExceeded {
+		t.Errorf("SendMessage error = %v, want DeadlineExceeded", err)
+	}
+	if len(fake.Messages) != 1 {
+		t.Errorf("messages = %+v, want only the first", fake.Messages)
+	}
+}
+
+func TestOutboxEditOwnerCancelled(t *testing.T) {
+	fake := NewFake("bot")
+	o := NewOutbox(fake, Limits{PerSecond: 1000, GroupInterval: 200 * time.Millisecond}, NewMemoryTurns())
+	sent, err := o.SendMessage(context.Background(), Message{ChatID: group, Text: "queue"})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+
+	// The first edit gives up while waiting, the one that joined it still goes out
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		first <- o.EditMessage(ctx, Edit{ChatID: group, MessageID: sent.MessageID, Text: "one"})
+	}()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		o.mu.Lock()
+		_, waiting := o.edits[editKey{chatID: group, messageID: sent.MessageID}]
+		o.mu.Unlock()
+		if waiting {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("first edit is not waiting")
+		}
+	}
+	second := make(chan error, 1)
+	go func() {
+		second <- o.EditMessage(context.Background(), Edit{ChatID: group, MessageID: sent.MessageID, Text: "two"})
+	}()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		o.mu.Lock()
+		p := o.edits[editKey{chatID: group, messageID: sent.MessageID}]
+		joined := p != nil && p.waiters == 2
+		o.mu.Unlock()
+		if joined {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("second edit did not join the first")
+		}
+	}
+	cancel()
+
+	if err := <-first; err != context.Canceled {
+		t.Errorf("first edit error = %v, want Canceled", err)
+	}
+	if err := <-second; err != nil {
+		t.Errorf("second edit error = %v, want nil", err)
+	}
+	if len(fake.Edits) != 1 || fake.Edits[0].Text != "two" {
+		t.Errorf("edits = %+v, want the latest one", fake.Edits)
+	}
+}
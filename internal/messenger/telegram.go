@@ -0,0 +1,160 @@
+package messenger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Descriptions of the stopPoll errors that mean the poll is gone or already stopped.
+const (
+	stopPollNotFound = "Bad Request: message to stop not found"
+	stopPollClosed   = "Bad Request: poll has already been closed"
+)
+
+// Telegram is the Messenger backed by the Telegram Bot API.
+type Telegram struct {
+	bot *tgbotapi.BotAPI
+}
+
+var _ Messenger = (*Telegram)(nil)
+
+// NewTelegram creates a Telegram messenger.
+func NewTelegram(bot *tgbotapi.BotAPI) *Telegram {
+	return &Telegram{bot: bot}
+}
+
+func (t *Telegram) Username() string {
+	return t.bot.Self.UserName
+}
+
+// tgbotapi v5.5.1 predates forum topics and its request configs can't carry
+// message_thread_id, so messages, polls and documents are sent with raw parameters.
+
+func (t *Telegram) SendMessage(ctx context.Context, msg Message) (Sent, error) {
+	params := chatParams(msg.ChatID, msg.ThreadID)
+	params["text"] = msg.Text
+	params.AddNonEmpty("parse_mode", msg.ParseMode)
+	params.AddNonZero("reply_to_message_id", msg.ReplyToMessageID)
+	params.AddBool("disable_web_page_preview", msg.DisableWebPagePreview)
+	if msg.ForceReply {
+		if err := params.AddInterface("reply_markup", tgbotapi.ForceReply{ForceReply: true, Selective: true}); err != nil {
+			return Sent{}, err
+		}
+	}
+	sent, err := t.send("sendMessage", params, nil)
+	if err != nil {
+		return Sent{}, markupError(err)
+	}
+	return Sent{ChatID: msg.ChatID, MessageID: sent.MessageID}, nil
+}
+
+func (t *Telegram) SendPoll(ctx context.Context, poll Poll) (SentPoll, error) {
+	params := chatParams(poll.ChatID, poll.ThreadID)
+	params["question"] = poll.Question
+	params["is_anonymous"] = "false"
+	if err := params.AddInterface("options", poll.Options); err != nil {
+		return SentPoll{}, err
+	}
+	sent, err := t.send("sendPoll", params, nil)
+	if err != nil {
+		return SentPoll{}, err
+	}
+	if sent.Poll == nil {
+		return SentPoll{}, fmt.Errorf("poll send returned no poll")
+	}
+	return SentPoll{Sent: Sent{ChatID: poll.ChatID, MessageID: sent.MessageID}, PollID: sent.Poll.ID}, nil
+}
+
+func (t *Telegram) StopPoll(ctx context.Context, chatID int64, messageID int) error {
+	_, err := t.bot.Request(tgbotapi.NewStopPoll(chatID, messageID))
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	// Telegram tells the cases apart only by description. Any other error, e.g. a message
+	// without a poll, is returned as is rather than taken for a poll that is gone.
+	switch apiErr.Message {
+	case stopPollNotFound:
+		return fmt.Errorf("%w: %w", ErrMessageNotFound, err)
+	case stopPollClosed:
+		return fmt.Errorf("%w: %w", ErrPollClosed, err)
+	}
+	return err
+}
+
+func (t *Telegram) EditMessage(ctx context.Context, edit Edit) error {
+	cfg := tgbotapi.NewEditMessageText(edit.ChatID, edit.MessageID, edit.Text)
+	cfg.ParseMode = edit.ParseMode
+	_, err := t.bot.Send(cfg)
+	return markupError(err)
+}
+
+// markupError wraps ErrBadMarkup around Telegram's error for text it can't parse, e.g.
+// "Bad Request: can't parse entities: Unsupported start tag "div" at byte offset 0".
+func markupError(err error) error {
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) && strings.HasPrefix(apiErr.Message, "Bad Request: can't parse entities") {
+		return fmt.Errorf("%w: %w", ErrBadMarkup, err)
+	}
+	return err
+}
+
+func (t *Telegram) AnswerCallback(ctx context.Context, callbackID, text string) error {
+	_, err := t.bot.Request(tgbotapi.NewCallback(callbackID, text))
+	return err
+}
+
+func (t *Telegram) SendDocument(ctx context.Context, doc Document) (Sent, error) {
+	params := chatParams(doc.ChatID, doc.ThreadID)
+	params.AddNonEmpty("caption", doc.Caption)
+	params.AddNonZero("reply_to_message_id", doc.ReplyToMessageID)
+	files := []tgbotapi.RequestFile{{Name: "document", Data: tgbotapi.FileBytes{Name: doc.FileName, Bytes: doc.Data}}}
+	sent, err := t.send("sendDocument", params, files)
+	if err != nil {
+		return Sent{}, err
+	}
+	return Sent{ChatID: doc.ChatID, MessageID: sent.MessageID}, nil
+}
+
+func (t *Telegram) IsChatAdmin(ctx context.Context, chatID, userID int64) (bool, error) {
+	member, err := t.bot.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: userID},
+	})
+	if err != nil {
+		return false, err
+	}
+	return member.IsCreator() || member.IsAdministrator(), nil
+}
+
+func chatParams(chatID int64, threadID int) tgbotapi.Params {
+	params := tgbotapi.Params{"chat_id": strconv.FormatInt(chatID, 10)}
+	params.AddNonZero("message_thread_id", threadID)
+	return params
+}
+
+// send calls a method that returns the sent message, uploading files if there are any.
+func (t *Telegram) send(method string, params tgbotapi.Params, files []tgbotapi.RequestFile) (tgbotapi.Message, error) {
+	var (
+		resp *tgbotapi.APIResponse
+		err  error
+	)
+	if len(files) > 0 {
+		resp, err = t.bot.UploadFiles(method, params, files)
+	} else {
+		resp, err = t.bot.MakeRequest(method, params)
+	}
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
+	var msg tgbotapi.Message
+	if err := json.Unmarshal(resp.Result, &msg); err != nil {
+		return tgbotapi.Message{}, fmt.Errorf("%s: %w", method, err)
+	}
+	return msg, nil
+}
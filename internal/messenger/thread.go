@@ -0,0 +1,41 @@
+package messenger
+
+import "context"
+
+// InThread returns a Messenger that posts messages, polls and documents for chatID in
+// the forum topic threadID, unless they name a topic themselves. Handlers reply through
+// it, so that answers land in the topic they were asked in while direct messages to
+// users stay where they are. A zero threadID returns m.
+func InThread(m Messenger, chatID int64, threadID int) Messenger {
+	if threadID == 0 {
+		return m
+	}
+	return &threadMessenger{Messenger: m, chatID: chatID, threadID: threadID}
+}
+
+type threadMessenger struct {
+	Messenger
+	chatID   int64
+	threadID int
+}
+
+func (t *threadMessenger) SendMessage(ctx context.Context, msg Message) (Sent, error) {
+	if msg.ChatID == t.chatID && msg.ThreadID == 0 {
+		msg.ThreadID = t.threadID
+	}
+	return t.Messenger.SendMessage(ctx, msg)
+}
+
+func (t *threadMessenger) SendPoll(ctx context.Context, poll Poll) (SentPoll, error) {
+	if poll.ChatID == t.chatID && poll.ThreadID == 0 {
+		poll.ThreadID = t.threadID
+	}
+	return t.Messenger.SendPoll(ctx, poll)
+}
+
+func (t *threadMessenger) SendDocument(ctx context.Context, doc Document) (Sent, error) {
+	if doc.ChatID == t.chatID && doc.ThreadID == 0 {
+		doc.ThreadID = t.threadID
+	}
+	return t.Messenger.SendDocument(ctx, doc)
+}
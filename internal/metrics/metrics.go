@@ -0,0 +1,111 @@
+// Package metrics defines the Prometheus metrics of the service and the worker.
+// They are registered with the default registry and served by Handler.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Result label values.
+const (
+	ResultOK        = "ok"
+	ResultError     = "error"
+	ResultDuplicate = "duplicate"
+	ResultRejected  = "rejected"
+)
+
+var (
+	// UpdatesProcessed counts Telegram updates by type (message, poll_answer, poll, other) and result
+	// (ok, duplicate for redelivered ones, or error for ones cut off by shutdown).
+	UpdatesProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lineup_updates_processed_total",
+		Help: "Telegram updates handled, by update type and result.",
+	}, []string{"type", "result"})
+
+	// LLMRequests counts LLM requests by operation and result (ok or error).
+	LLMRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lineup_llm_requests_total",
+		Help: "LLM requests, by operation and result.",
+	}, []string{"operation", "result"})
+
+	// LLMDuration observes LLM request latency by operation.
+	LLMDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lineup_llm_request_duration_seconds",
+		Help:    "LLM request latency, by operation.",
+		Buckets: []float64{0.25, 0.5, 1, 2, 4, 8, 15, 30},
+	}, []string{"operation"})
+
+	// PollsCreated counts polls sent to chats.
+	PollsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "lineup_polls_created_total",
+		Help: "Polls sent to chats.",
+	})
+
+	// PollsFinished counts polls whose lineup was published.
+	PollsFinished = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "lineup_polls_finished_total",
+		Help: "Polls finished with a published lineup.",
+	})
+
+	// VoteCountMismatches counts poll updates whose voters per answer differ from the stored votes.
+	VoteCountMismatches = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "lineup_vote_count_mismatches_total",
+		Help: "Poll updates whose vote counts differ from the stored votes.",
+	})
+
+	// QueueOperations counts queue changes by operation (join, leave, reorder) and result.
+	QueueOperations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lineup_queue_operations_total",
+		Help: "Queue operations, by operation and result.",
+	}, []string{"operation", "result"})
+
+	// TelegramOutboxDepth is the number of Bot API requests waiting for their turn in the outbox.
+	TelegramOutboxDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "lineup_telegram_outbox_depth",
+		Help: "Bot API requests waiting for their turn under the rate limits.",
+	})
+
+	// TelegramRateLimited counts Bot API requests rejected with 429 Too Many Requests.
+	TelegramRateLimited = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "lineup_telegram_rate_limited_total",
+		Help: "Bot API requests rejected with 429 Too Many Requests.",
+	})
+
+	// TelegramEditsCoalesced counts message edits replaced by a later edit of the same message
+	// before they were sent.
+	TelegramEditsCoalesced = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "lineup_telegram_edits_coalesced_total",
+		Help: "Message edits replaced by a later edit before they were sent.",
+	})
+
+	// RiverJobs counts finished River job attempts by kind and outcome
+	// (completed, failed, cancelled, snoozed).
+	RiverJobs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lineup_river_jobs_total",
+		Help: "River job attempts, by job kind and outcome.",
+	}, []string{"kind", "outcome"})
+
+	// RiverJobDuration observes River job run time by kind.
+	RiverJobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lineup_river_job_duration_seconds",
+		Help:    "River job run time, by job kind.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"kind"})
+)
+
+// Result returns the result label for err.
+func Result(err error) string {
+	if err != nil {
+		return ResultError
+	}
+	return ResultOK
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"strings"
+
+	"github.com/riverqueue/river"
+)
+
+// RiverEventKinds are the events RecordRiverJobs expects a subscription to.
+var RiverEventKinds = []river.EventKind{
+	river.EventKindJobCompleted,
+	river.EventKindJobFailed,
+	river.EventKindJobCancelled,
+	river.EventKindJobSnoozed,
+}
+
+// RecordRiverJobs counts job outcomes from a River event subscription until it is closed.
+func RecordRiverJobs(events <-chan *river.Event) {
+	for e := range events {
+		if e.Job == nil {
+			continue
+		}
+		outcome := strings.TrimPrefix(string(e.Kind), "job_")
+		RiverJobs.WithLabelValues(e.Job.Kind, outcome).Inc()
+		if e.JobStats != nil {
+			RiverJobDuration.WithLabelValues(e.Job.Kind).Observe(e.JobStats.RunDuration.Seconds())
+		}
+	}
+}
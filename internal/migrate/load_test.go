@@ -0,0 +1,43 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/nikitkaralius/lineup/migrations"
+)
+
+func TestLoad(t *testing.T) {
+	ms, err := load(fstest.MapFS{
+		"000002_b.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+		"000002_b.down.sql": {Data: []byte("DROP TABLE b;")},
+		"000001_a.up.sql":   {Data: []byte("CREATE TABLE a ();")},
+		"000001_a.down.sql": {Data: []byte("DROP TABLE a;")},
+		"migrations.go":     {Data: []byte("package migrations")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 2 || ms[0].Version != 1 || ms[1].Name != "b" || ms[1].Down != "DROP TABLE b;" {
+		t.Errorf("got %+v", ms)
+	}
+
+	if _, err := load(fstest.MapFS{"000001_a.up.sql": {Data: []byte("SELECT 1;")}}); err == nil {
+		t.Error("migration without a down file accepted")
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	ms, err := load(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range ms {
+		if m.Version != i+1 {
+			t.Fatalf("migration %d_%s follows version %d", m.Version, m.Name, i)
+		}
+	}
+	if !isEmpty(ms[3].Up) || !isEmpty(ms[3].Down) {
+		t.Error("migration 000004 should only hold comments, River's schema is applied by rivermigrate")
+	}
+}
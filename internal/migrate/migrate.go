@@ -0,0 +1,341 @@
+// Package migrate applies the embedded SQL migrations and River's schema.
+//
+// The schema version is kept in golang-migrate's schema_migrations table, so that
+// databases migrated with the migrate CLI carry on from their version. River's
+// tables are migrated by rivermigrate, which records its versions in river_migration.
+//
+// Migrations hold a Postgres advisory lock, so that the service and the worker
+// starting together apply them once.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/migrations"
+	"github.com/riverqueue/river/riverdriver/riverpgxv5"
+	"github.com/riverqueue/river/rivermigrate"
+)
+
+// lockID is the advisory lock key held while migrating, "lineup" in ASCII.
+const lockID = 0x6c696e657570
+
+// riverBaseline is the River version migration 000004 used to create by hand.
+const riverBaseline = 6
+
+// ErrDirty is returned when an earlier migration failed halfway under golang-migrate.
+var ErrDirty = errors.New("database is dirty")
+
+// Migration is a single schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes the schema versions of a database.
+type Status struct {
+	Version      int // applied app schema version, 0 if none
+	Latest       int // latest embedded app schema version
+	Pending      []Migration
+	RiverVersion int // applied River schema version, 0 if none
+	RiverLatest  int
+}
+
+// Migrator migrates the database of pool.
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []Migration
+	river      *rivermigrate.Migrator[pgx.Tx]
+}
+
+// New returns a Migrator for the embedded migrations.
+func New(pool *pgxpool.Pool) (*Migrator, error) {
+	return newMigrator(pool, migrations.FS)
+}
+
+func newMigrator(pool *pgxpool.Pool, files fs.FS) (*Migrator, error) {
+	ms, err := load(files)
+	if err != nil {
+		return nil, err
+	}
+	// Migrations run in transactions of the locked connection, so the driver needs no pool
+	river, err := rivermigrate.New(riverpgxv5.New(nil), &rivermigrate.Config{Logger: slog.Default()})
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{pool: pool, migrations: ms, river: river}, nil
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// load reads the migrations in files, which must come in up and down pairs.
+func load(files fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		version, err := strconv.Atoi(m[1])
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", e.Name(), err)
+		}
+		sql, err := fs.ReadFile(files, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names, %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(sql)
+		} else {
+			mig.Down = string(sql)
+		}
+	}
+
+	ms := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		ms = append(ms, *m)
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+	return ms, nil
+}
+
+// Up applies River's migrations and then all pending app migrations.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.locked(ctx, func(conn *pgx.Conn) error {
+		if err := m.riverUp(ctx, conn); err != nil {
+			return err
+		}
+		version, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if mig.Version <= version {
+				continue
+			}
+			if err := apply(ctx, conn, mig.Up, mig.Version); err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", mig.Version, mig.Name, err)
+			}
+			slog.InfoContext(ctx, "applied migration", slog.Int("version", mig.Version), slog.String("name", mig.Name))
+		}
+		return nil
+	})
+}
+
+// Down reverts the last steps app migrations. River's schema is left in place,
+// it holds the jobs; remove it with the river CLI if needed.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.locked(ctx, func(conn *pgx.Conn) error {
+		version, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			mig := m.migrations[i]
+			if mig.Version > version {
+				continue
+			}
+			if mig.Version != version {
+				return fmt.Errorf("database version %d has no embedded migration", version)
+			}
+			previous := 0
+			if i > 0 {
+				previous = m.migrations[i-1].Version
+			}
+			if err := apply(ctx, conn, mig.Down, previous); err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", mig.Version, mig.Name, err)
+			}
+			slog.InfoContext(ctx, "reverted migration", slog.Int("version", mig.Version), slog.String("name", mig.Name))
+			version = previous
+			steps--
+		}
+		return nil
+	})
+}
+
+// Status reports the applied and pending migrations.
+func (m *Migrator) Status(ctx context.Context) (*Status, error) {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	status := &Status{}
+	if len(m.migrations) > 0 {
+		status.Latest = m.migrations[len(m.migrations)-1].Version
+	}
+	if status.Version, err = currentVersion(ctx, conn.Conn()); err != nil {
+		return nil, err
+	}
+	for _, mig := range m.migrations {
+		if mig.Version > status.Version {
+			status.Pending = append(status.Pending, mig)
+		}
+	}
+
+	all := m.river.AllVersions()
+	status.RiverLatest = all[len(all)-1].Version
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }() // read-only
+	existing, err := m.river.ExistingVersionsTx(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("river versions: %w", err)
+	}
+	if len(existing) > 0 {
+		status.RiverVersion = existing[len(existing)-1].Version
+	}
+	return status, nil
+}
+
+// locked runs fn on a connection holding the migration lock.
+func (m *Migrator) locked(ctx context.Context, fn func(*pgx.Conn) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		// A new context, the lock must be released even if ctx is done
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID); err != nil {
+			slog.Error("release migration lock failed", logging.Err(err))
+		}
+	}()
+
+	if _, err := conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)`); err != nil {
+		return err
+	}
+	return fn(conn.Conn())
+}
+
+// currentVersion returns the applied app schema version, 0 if none.
+func currentVersion(ctx context.Context, conn *pgx.Conn) (int, error) {
+	var exists bool
+	if err := conn.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil || !exists {
+		return 0, err
+	}
+	var (
+		version int
+		dirty   bool
+	)
+	err := conn.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return version, fmt.Errorf("%w at version %d: fix the schema by hand, then set schema_migrations.dirty to false", ErrDirty, version)
+	}
+	return version, nil
+}
+
+// apply runs sql and records version in one transaction.
+func apply(ctx context.Context, conn *pgx.Conn, sql string, version int) error {
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		if !isEmpty(sql) {
+			if _, err := tx.Exec(ctx, sql); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM schema_migrations`); err != nil {
+			return err
+		}
+		if version == 0 {
+			return nil
+		}
+		_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)`, version)
+		return err
+	})
+}
+
+// riverUp applies River's migrations. Databases that got River's tables from migration
+// 000004, before rivermigrate was used, are first recorded as being at riverBaseline.
+func (m *Migrator) riverUp(ctx context.Context, conn *pgx.Conn) error {
+	return pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+		var handMade bool
+		err := tx.QueryRow(ctx, `SELECT to_regclass('river_job') IS NOT NULL AND to_regclass('river_migration') IS NULL`).Scan(&handMade)
+		if err != nil {
+			return err
+		}
+		if handMade {
+			_, err := tx.Exec(ctx, `
+CREATE TABLE river_migration (
+    line       TEXT        NOT NULL,
+    version    BIGINT      NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT line_length CHECK (char_length(line) > 0 AND char_length(line) < 128),
+    CONSTRAINT version_gte_1 CHECK (version >= 1),
+    PRIMARY KEY (line, version)
+);
+INSERT INTO river_migration (line, version) SELECT 'main', generate_series(1, $1);`, riverBaseline)
+			if err != nil {
+				return fmt.Errorf("baseline river schema: %w", err)
+			}
+			slog.InfoContext(ctx, "recorded hand-made river schema", slog.Int("version", riverBaseline))
+		}
+
+		res, err := m.river.MigrateTx(ctx, tx, rivermigrate.DirectionUp, nil)
+		if err != nil {
+			return fmt.Errorf("river migrations: %w", err)
+		}
+		for _, v := range res.Versions {
+			slog.InfoContext(ctx, "applied river migration", slog.Int("version", v.Version), slog.String("name", v.Name))
+		}
+		return nil
+	})
+}
+
+// isEmpty reports whether sql has nothing but comments.
+func isEmpty(sql string) bool {
+	for _, line := range strings.Split(sql, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
+
+// Up applies all pending migrations of the embedded schema to the database of pool.
+func Up(ctx context.Context, pool *pgxpool.Pool) error {
+	m, err := New(pool)
+	if err != nil {
+		return err
+	}
+	return m.Up(ctx)
+}
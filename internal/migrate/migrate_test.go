@@ -0,0 +1,76 @@
+package migrate_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nikitkaralius/lineup/internal/migrate"
+	"github.com/nikitkaralius/lineup/internal/pgtest"
+)
+
+func TestUpDownStatus(t *testing.T) {
+	pool := pgtest.New(t) // applies all migrations
+	ctx := context.Background()
+
+	m, err := migrate.New(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Version != status.Latest || len(status.Pending) != 0 {
+		t.Fatalf("after New: version %d of %d, pending %v", status.Version, status.Latest, status.Pending)
+	}
+	if status.RiverVersion != status.RiverLatest {
+		t.Fatalf("river version %d of %d", status.RiverVersion, status.RiverLatest)
+	}
+
+	if err := m.Down(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if status, err = m.Status(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Pending) != 2 {
+		t.Fatalf("after down 2: %d pending", len(status.Pending))
+	}
+
+	// Up is idempotent and runs concurrently under the lock
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() { errs <- m.Up(ctx) }()
+	}
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if status, err = m.Status(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if status.Version != status.Latest {
+		t.Fatalf("after up: version %d of %d", status.Version, status.Latest)
+	}
+}
+
+func TestRiverBaseline(t *testing.T) {
+	pool := pgtest.New(t)
+	ctx := context.Background()
+
+	// Databases migrated by golang-migrate have River's tables but no river_migration
+	if _, err := pool.Exec(ctx, `DROP TABLE river_migration`); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrate.Up(ctx, pool); err != nil {
+		t.Fatal(err)
+	}
+	var versions int
+	if err := pool.QueryRow(ctx, `SELECT count(*) FROM river_migration WHERE line = 'main'`).Scan(&versions); err != nil {
+		t.Fatal(err)
+	}
+	if versions < 6 {
+		t.Errorf("river_migration has %d versions, want the baseline and later", versions)
+	}
+}
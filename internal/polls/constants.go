@@ -1,7 +1,43 @@
 package polls
 
-// DefaultPollAnswers are the default answers for polls.
-var DefaultPollAnswers = []string{"Иду", "Не иду"}
+import (
+	"github.com/nikitkaralius/lineup/internal/chats"
+	"github.com/nikitkaralius/lineup/internal/i18n"
+)
+
+// DefaultPollAnswers returns the default answers for polls in the given language.
+func DefaultPollAnswers(lang i18n.Lang) []string {
+	return []string{i18n.T(lang, i18n.AnswerComing), i18n.T(lang, i18n.AnswerNotComing)}
+}
+
+// ChatPollAnswers returns the answers of the chat's polls that name none and the index of
+// the "coming" one: the configured ones, or the default answers in the chat language.
+func ChatPollAnswers(settings *chats.ChatSettingsDTO) ([]string, int) {
+	if len(settings.Answers) > 0 {
+		return settings.Answers, settings.ComingAnswerIndex
+	}
+	return DefaultPollAnswers(settings.Language), DefaultComingAnswerIndex
+}
 
 // DefaultComingAnswerIndex is the index of the "coming" answer in default answers.
 const DefaultComingAnswerIndex = 0
+
+// Poll statuses stored in polls.status.
+const (
+	StatusActive     = "active"
+	StatusPublishing = "publishing" // the queue is stored, the lineup is being sent
+	StatusProcessed  = "processed"
+	StatusCancelled  = "cancelled"
+	StatusDeleted    = "deleted" // the poll's message was deleted from the chat before it finished
+)
+
+// isDefaultPollAnswers reports whether answers are the default ones in any language.
+func isDefaultPollAnswers(answers []string) bool {
+	for _, lang := range i18n.Supported() {
+		defaults := DefaultPollAnswers(lang)
+		if len(answers) == len(defaults) && answers[0] == defaults[0] && answers[1] == defaults[1] {
+			return true
+		}
+	}
+	return false
+}
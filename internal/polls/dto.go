@@ -5,6 +5,7 @@ import "time"
 type TelegramPollDTO struct {
 	PollID            string
 	ChatID            int64
+	MessageThreadID   int // forum topic the poll was posted in, 0 outside forum topics
 	MessageID         int
 	Topic             string
 	CreatorID         int64
@@ -13,6 +14,12 @@ type TelegramPollDTO struct {
 	StartedAt         time.Time
 	Duration          time.Duration
 	EndsAt            time.Time
+	Status            string
 	Answers           []string
 	ComingAnswerIndex int
+	ResultsMessageID  int
+	SessionStartAt    time.Time     // zero if the poll has no time slots
+	SlotDuration      time.Duration // time each person in the queue gets
+	SourceMessageID   int           // message the poll was requested with, 0 if not requested in the chat
+	VoterCounts       []int         // voters per answer as last reported by Telegram, empty until then
 }
@@ -1,14 +1,31 @@
 package polls
 
+import "time"
+
 // FinishPollArgs defines the arguments for a job that finalizes a Telegram poll
 // by stopping it and posting the results.
 // This type is shared between service (for enqueue) and worker (for processing).
+//
+// Jobs are unique by poll and end time: scheduling the same finish twice inserts one job,
+// while extending or closing a poll early schedules a new one.
 type FinishPollArgs struct {
-	PollID    string `json:"poll_id"`
-	ChatID    int64  `json:"chat_id"`
-	MessageID int    `json:"message_id"`
-	Topic     string `json:"topic"`
+	PollID    string    `json:"poll_id" river:"unique"`
+	ChatID    int64     `json:"chat_id"`
+	MessageID int       `json:"message_id"`
+	Topic     string    `json:"topic"`
+	EndsAt    time.Time `json:"ends_at" river:"unique"`
 }
 
 // Kind implements river.JobArgs to identify this job type.
 func (FinishPollArgs) Kind() string { return "finish_poll" }
+
+// FinishPollMaxAttempts is how often a finish job is tried before it is discarded.
+// Polls whose job was discarded are picked up again by the reconcile job.
+const FinishPollMaxAttempts = 10
+
+// ReconcilePollsArgs are the arguments of the periodic job that schedules a finish job for
+// polls that ended but were never finished, e.g. because their job was discarded.
+type ReconcilePollsArgs struct{}
+
+// Kind implements river.JobArgs to identify this job type.
+func (ReconcilePollsArgs) Kind() string { return "reconcile_polls" }
@@ -0,0 +1,154 @@
+package polls
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nikitkaralius/lineup/internal/i18n"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/messenger"
+	"github.com/nikitkaralius/lineup/internal/metrics"
+	"github.com/nikitkaralius/lineup/internal/utils"
+	"github.com/nikitkaralius/lineup/internal/webhooks"
+)
+
+// Manager sends polls to Telegram and drives them through their lifecycle.
+// It is shared by the bot handlers and the HTTP API.
+type Manager struct {
+	repo    Repository
+	service Service
+	events  webhooks.Publisher
+	bot     messenger.Messenger
+}
+
+// NewManager creates a poll manager. service may be nil, then polls are never finished automatically;
+// events may be nil, then no webhooks are notified.
+func NewManager(repo Repository, service Service, events webhooks.Publisher, bot messenger.Messenger) *Manager {
+	return &Manager{repo: repo, service: service, events: events, bot: bot}
+}
+
+// FormatTopic formats the poll question shown in Telegram: topic, end time and,
+// if the poll has time slots, the session start and time per person, in the given timezone.
+func FormatTopic(lang i18n.Lang, loc *time.Location, topic string, endsAt, sessionStartAt time.Time, slot time.Duration) string {
+	text := i18n.T(lang, i18n.PollTopic, topic, utils.FormatTimeForPoll(endsAt, loc))
+	if !sessionStartAt.IsZero() && slot > 0 {
+		text += i18n.T(lang, i18n.PollSchedule, utils.FormatTimeShort(sessionStartAt, loc), i18n.T(lang, i18n.SlotMinutes, int(slot/time.Minute)))
+	}
+	return text
+}
+
+// CreatePoll sends p to its chat as a non-anonymous poll, stores it and schedules its finish at p.EndsAt.
+// p.Topic is used as the poll question; PollID, MessageID and StartedAt are filled in from the sent poll.
+// A message creates at most one poll: with p.SourceMessageID set, ErrDuplicatePoll is returned
+// if the message already created one.
+func (m *Manager) CreatePoll(ctx context.Context, p *TelegramPollDTO) error {
+	if p.SourceMessageID != 0 {
+		claimed, err := m.repo.ClaimSource(ctx, p.ChatID, p.SourceMessageID)
+		if err != nil {
+			return fmt.Errorf("claim source message: %w", err)
+		}
+		if !claimed {
+			return ErrDuplicatePoll
+		}
+	}
+
+	sent, err := m.bot.SendPoll(ctx, messenger.Poll{ChatID: p.ChatID, ThreadID: p.MessageThreadID, Question: p.Topic, Options: p.Answers})
+	if err != nil {
+		if p.SourceMessageID != 0 {
+			// Nothing was sent, let the message be retried
+			if err := m.repo.ReleaseSource(ctx, p.ChatID, p.SourceMessageID); err != nil {
+				slog.ErrorContext(ctx, "release source message failed", logging.ChatID(p.ChatID), slog.Int("message_id", p.SourceMessageID), logging.Err(err))
+			}
+		}
+		return fmt.Errorf("send poll: %w", err)
+	}
+
+	p.PollID = sent.PollID
+	p.MessageID = sent.MessageID
+	p.StartedAt = time.Now().UTC()
+	p.Status = StatusActive
+
+	if err := m.repo.InsertPoll(ctx, p); err != nil {
+		return fmt.Errorf("insert poll: %w", err)
+	}
+	metrics.PollsCreated.Inc()
+
+	if m.events != nil {
+		if err := m.events.Publish(ctx, p.ChatID, webhooks.EventPollCreated, p.PollID, webhookPollData(p)); err != nil {
+			slog.ErrorContext(ctx, "publish event failed", slog.String("event", webhooks.EventPollCreated), logging.PollID(p.PollID), logging.ChatID(p.ChatID), logging.Err(err))
+		}
+	}
+
+	// Enqueue async job to finalize poll at EndsAt
+	return m.scheduleFinish(ctx, p, p.EndsAt)
+}
+
+// ClosePoll finishes an active poll now instead of at its end time.
+func (m *Manager) ClosePoll(ctx context.Context, pollID string) error {
+	return m.ExtendPoll(ctx, pollID, time.Now().UTC())
+}
+
+// ExtendPoll moves the end of an active poll to endsAt. The job scheduled for the old
+// end time finds the poll not yet due and leaves it to the new job.
+func (m *Manager) ExtendPoll(ctx context.Context, pollID string, endsAt time.Time) error {
+	p, err := m.repo.GetPoll(ctx, pollID)
+	if err != nil {
+		return err
+	}
+	if p.Status != StatusActive {
+		return ErrPollNotActive
+	}
+	if err := m.repo.UpdateEndsAt(ctx, pollID, endsAt); err != nil {
+		return err
+	}
+	return m.scheduleFinish(ctx, p, endsAt)
+}
+
+// CancelPoll stops an active poll in Telegram without publishing a lineup.
+func (m *Manager) CancelPoll(ctx context.Context, pollID string) error {
+	p, err := m.repo.GetPoll(ctx, pollID)
+	if err != nil {
+		return err
+	}
+	if err := m.repo.MarkCancelled(ctx, pollID); err != nil {
+		return err
+	}
+	if err := m.bot.StopPoll(ctx, p.ChatID, p.MessageID); err != nil {
+		slog.WarnContext(ctx, "stop cancelled poll failed", logging.PollID(pollID), logging.ChatID(p.ChatID), logging.Err(err))
+		// keep going; maybe already stopped
+	}
+	return nil
+}
+
+func (m *Manager) scheduleFinish(ctx context.Context, p *TelegramPollDTO, runAt time.Time) error {
+	if m.service == nil {
+		return nil
+	}
+	// Stored times have microseconds, so that the reconcile job schedules the same unique job
+	args := FinishPollArgs{PollID: p.PollID, ChatID: p.ChatID, MessageID: p.MessageID, Topic: p.Topic, EndsAt: runAt.UTC().Truncate(time.Microsecond)}
+	if err := m.service.SchedulePollFinish(ctx, args, runAt); err != nil {
+		return fmt.Errorf("enqueue finish poll: %w", err)
+	}
+	return nil
+}
+
+func webhookPollData(p *TelegramPollDTO) webhooks.PollData {
+	data := webhooks.PollData{
+		PollID:            p.PollID,
+		Topic:             p.Topic,
+		Status:            p.Status,
+		Answers:           p.Answers,
+		ComingAnswerIndex: p.ComingAnswerIndex,
+		StartedAt:         p.StartedAt,
+		EndsAt:            p.EndsAt,
+		SlotSeconds:       int(p.SlotDuration / time.Second),
+		CreatorID:         p.CreatorID,
+		CreatorName:       p.CreatorName,
+	}
+	if !p.SessionStartAt.IsZero() {
+		data.SessionStartAt = &p.SessionStartAt
+	}
+	return data
+}
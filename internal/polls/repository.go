@@ -2,42 +2,97 @@ package polls
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nikitkaralius/lineup/internal/i18n"
 )
 
-// This AI crap will be refactored
+// Repository stores polls. Methods looking up a single poll return pgx.ErrNoRows if there is none.
+type Repository interface {
+	// InsertPoll stores a new active poll; a poll with the same ID is left as is.
+	InsertPoll(ctx context.Context, p *TelegramPollDTO) error
+	// FindExpiredActivePolls returns active and publishing polls whose end time has passed.
+	FindExpiredActivePolls(ctx context.Context) ([]TelegramPollDTO, error)
+	// MarkPublishing marks an active poll as publishing: its queue is stored and its lineup
+	// is about to be sent. Returns ErrPollNotActive otherwise.
+	MarkPublishing(ctx context.Context, pollID string) error
+	// MarkProcessed marks a publishing poll processed with its published results message.
+	// Returns ErrPollNotActive if the poll is not publishing.
+	MarkProcessed(ctx context.Context, pollID string, resultsMessageID int) error
+	// FindPollByResultsMessageID finds a poll by its chat and results message ID.
+	FindPollByResultsMessageID(ctx context.Context, chatID int64, resultsMessageID int) (*TelegramPollDTO, error)
+	// GetPollInfo returns what finishing a poll needs: topic, status, end time, coming answer and schedule.
+	GetPollInfo(ctx context.Context, pollID string) (*TelegramPollDTO, error)
+	// GetPollInfoForQueue returns what queue operations need: chat, results message, topic and schedule.
+	GetPollInfoForQueue(ctx context.Context, pollID string) (*TelegramPollDTO, error)
+	// GetPoll returns all stored information about a poll.
+	GetPoll(ctx context.Context, pollID string) (*TelegramPollDTO, error)
+	// ListPollsByChat returns the chat's polls, newest first.
+	ListPollsByChat(ctx context.Context, chatID int64, limit, offset int) ([]TelegramPollDTO, error)
+	// ListPolls returns polls of all chats, newest first. An empty status matches any.
+	ListPolls(ctx context.Context, status string, limit int) ([]TelegramPollDTO, error)
+	// ListPollsByChatBetween returns the chat's polls started in [from, to), oldest first.
+	ListPollsByChatBetween(ctx context.Context, chatID int64, from, to time.Time) ([]TelegramPollDTO, error)
+	// UpdateEndsAt moves the end of an active poll. Returns ErrPollNotActive otherwise.
+	UpdateEndsAt(ctx context.Context, pollID string, endsAt time.Time) error
+	// MarkCancelled cancels an active poll so that it is never finished. Returns ErrPollNotActive otherwise.
+	MarkCancelled(ctx context.Context, pollID string) error
+	// MarkDeleted records that the message of an active poll was deleted from the chat,
+	// so that it is never finished. Returns ErrPollNotActive otherwise.
+	MarkDeleted(ctx context.Context, pollID string) error
+	// UpdateVoterCounts stores the voters per answer reported by Telegram.
+	UpdateVoterCounts(ctx context.Context, pollID string, counts []int) error
+	// ClaimSource reserves a chat message for creating a poll. It returns false if the
+	// message already created one or is creating it right now.
+	ClaimSource(ctx context.Context, chatID int64, messageID int) (bool, error)
+	// ReleaseSource frees a claimed message whose poll could not be created.
+	ReleaseSource(ctx context.Context, chatID int64, messageID int) error
+	// DeleteSourcesBefore forgets messages claimed before t and returns how many there were.
+	DeleteSourcesBefore(ctx context.Context, t time.Time) (int64, error)
+}
 
-type Repository struct {
-	DB *pgxpool.Pool
+// pgRepository implements Repository with Postgres.
+type pgRepository struct {
+	db *pgxpool.Pool
 }
 
-func NewRepository(db *pgxpool.Pool) *Repository {
-	return &Repository{DB: db}
+// NewRepository creates a Repository backed by db.
+func NewRepository(db *pgxpool.Pool) Repository {
+	return &pgRepository{db: db}
 }
 
-func (s *Repository) InsertPoll(ctx context.Context, p *TelegramPollDTO) error {
+func (s *pgRepository) InsertPoll(ctx context.Context, p *TelegramPollDTO) error {
 	answers := p.Answers
 	if len(answers) == 0 {
-		answers = DefaultPollAnswers
+		answers = DefaultPollAnswers(i18n.Default)
 	}
 	comingIndex := p.ComingAnswerIndex
-	if len(answers) == len(DefaultPollAnswers) && answers[0] == DefaultPollAnswers[0] && answers[1] == DefaultPollAnswers[1] {
+	if isDefaultPollAnswers(answers) {
 		comingIndex = DefaultComingAnswerIndex
 	}
 
-	_, err := s.DB.Query(ctx, `INSERT INTO polls (
-		poll_id, chat_id, message_id, topic, creator_id, creator_username, creator_name, started_at, duration_seconds, ends_at, status, answers, coming_answer_index
-	) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,'active',$11,$12)
+	var sessionStartAt *time.Time
+	if !p.SessionStartAt.IsZero() {
+		sessionStartAt = &p.SessionStartAt
+	}
+
+	_, err := s.db.Exec(ctx, `INSERT INTO polls (
+		poll_id, chat_id, message_id, topic, creator_id, creator_username, creator_name, started_at, duration_seconds, ends_at, status, answers, coming_answer_index, session_start_at, slot_seconds, message_thread_id
+	) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,'active',$11,$12,$13,NULLIF($14,0),$15)
 	ON CONFLICT (poll_id) DO NOTHING`,
-		p.PollID, p.ChatID, p.MessageID, p.Topic, p.CreatorID, p.CreatorUsername, p.CreatorName, p.StartedAt, int(p.Duration/time.Second), p.EndsAt, answers, comingIndex,
+		p.PollID, p.ChatID, p.MessageID, p.Topic, p.CreatorID, p.CreatorUsername, p.CreatorName, p.StartedAt, int(p.Duration/time.Second), p.EndsAt, answers, comingIndex, sessionStartAt, int(p.SlotDuration/time.Second), p.MessageThreadID,
 	)
+	if err != nil || p.SourceMessageID == 0 {
+		return err
+	}
+	_, err = s.db.Exec(ctx, `UPDATE poll_sources SET poll_id=$3 WHERE chat_id=$1 AND message_id=$2`, p.ChatID, p.SourceMessageID, p.PollID)
 	return err
 }
 
-func (s *Repository) FindExpiredActivePolls(ctx context.Context) ([]TelegramPollDTO, error) {
-	rows, err := s.DB.Query(ctx, `SELECT poll_id, chat_id, message_id, topic, ends_at FROM polls WHERE status='active' AND ends_at <= NOW()`)
+func (s *pgRepository) FindExpiredActivePolls(ctx context.Context) ([]TelegramPollDTO, error) {
+	rows, err := s.db.Query(ctx, `SELECT poll_id, chat_id, message_id, message_thread_id, topic, ends_at FROM polls WHERE status IN ('active','publishing') AND ends_at <= NOW()`)
 	if err != nil {
 		return nil, err
 	}
@@ -45,7 +100,7 @@ func (s *Repository) FindExpiredActivePolls(ctx context.Context) ([]TelegramPoll
 	var res []TelegramPollDTO
 	for rows.Next() {
 		var p TelegramPollDTO
-		if err := rows.Scan(&p.PollID, &p.ChatID, &p.MessageID, &p.Topic, &p.EndsAt); err != nil {
+		if err := rows.Scan(&p.PollID, &p.ChatID, &p.MessageID, &p.MessageThreadID, &p.Topic, &p.EndsAt); err != nil {
 			return nil, err
 		}
 		res = append(res, p)
@@ -53,18 +108,33 @@ func (s *Repository) FindExpiredActivePolls(ctx context.Context) ([]TelegramPoll
 	return res, rows.Err()
 }
 
-func (s *Repository) MarkProcessed(ctx context.Context, pollID string, resultsMessageID int, queueUserIDs []int64) error {
-	_, err := s.DB.Exec(ctx, `UPDATE polls SET status='processed', processed_at=NOW(), results_message_id=$2 WHERE poll_id=$1`, pollID, resultsMessageID)
+func (s *pgRepository) MarkPublishing(ctx context.Context, pollID string) error {
+	tag, err := s.db.Exec(ctx, `UPDATE polls SET status='publishing' WHERE poll_id=$1 AND status='active'`, pollID)
 	if err != nil {
 		return err
 	}
-	return err
+	if tag.RowsAffected() == 0 {
+		return ErrPollNotActive
+	}
+	return nil
+}
+
+func (s *pgRepository) MarkProcessed(ctx context.Context, pollID string, resultsMessageID int) error {
+	tag, err := s.db.Exec(ctx, `UPDATE polls SET status='processed', processed_at=NOW(), results_message_id=$2 WHERE poll_id=$1 AND status='publishing'`, pollID, resultsMessageID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPollNotActive
+	}
+	return nil
 }
 
-// FindPollByResultsMessageID finds a poll by its results message ID.
-func (s *Repository) FindPollByResultsMessageID(ctx context.Context, resultsMessageID int) (*TelegramPollDTO, error) {
+// FindPollByResultsMessageID finds a poll by its chat and results message ID.
+// Message IDs are only unique within a chat.
+func (s *pgRepository) FindPollByResultsMessageID(ctx context.Context, chatID int64, resultsMessageID int) (*TelegramPollDTO, error) {
 	var p TelegramPollDTO
-	err := s.DB.QueryRow(ctx, `SELECT poll_id, chat_id, message_id, topic FROM polls WHERE results_message_id=$1`, resultsMessageID).
+	err := s.db.QueryRow(ctx, `SELECT poll_id, chat_id, message_id, topic FROM polls WHERE chat_id=$1 AND results_message_id=$2`, chatID, resultsMessageID).
 		Scan(&p.PollID, &p.ChatID, &p.MessageID, &p.Topic)
 	if err != nil {
 		return nil, err
@@ -73,20 +143,175 @@ func (s *Repository) FindPollByResultsMessageID(ctx context.Context, resultsMess
 }
 
 // GetPollInfo retrieves poll information including coming_answer_index.
-// Note: This method only retrieves poll_id and coming_answer_index.
-func (s *Repository) GetPollInfo(ctx context.Context, pollID string) (*TelegramPollDTO, error) {
-	var p TelegramPollDTO
-	err := s.DB.QueryRow(ctx, `SELECT poll_id, COALESCE(coming_answer_index, 0) FROM polls WHERE poll_id=$1`, pollID).
-		Scan(&p.PollID, &p.ComingAnswerIndex)
+// Note: This method only retrieves poll_id, topic, status, ends_at, coming_answer_index, message_thread_id and the time slot schedule.
+func (s *pgRepository) GetPollInfo(ctx context.Context, pollID string) (*TelegramPollDTO, error) {
+	var (
+		p              TelegramPollDTO
+		sessionStartAt *time.Time
+		slotSeconds    int
+	)
+	err := s.db.QueryRow(ctx, `SELECT poll_id, topic, status, ends_at, COALESCE(coming_answer_index, 0), message_thread_id, session_start_at, COALESCE(slot_seconds, 0) FROM polls WHERE poll_id=$1`, pollID).
+		Scan(&p.PollID, &p.Topic, &p.Status, &p.EndsAt, &p.ComingAnswerIndex, &p.MessageThreadID, &sessionStartAt, &slotSeconds)
+	if err != nil {
+		return nil, err
+	}
+	setSchedule(&p, sessionStartAt, slotSeconds)
+	return &p, nil
+}
+
+// GetPollInfoForQueue retrieves poll information needed for queue operations:
+// chat and forum topic, results message, topic and the time slot schedule.
+func (s *pgRepository) GetPollInfoForQueue(ctx context.Context, pollID string) (*TelegramPollDTO, error) {
+	var (
+		p              TelegramPollDTO
+		sessionStartAt *time.Time
+		slotSeconds    int
+	)
+	err := s.db.QueryRow(ctx, `SELECT poll_id, chat_id, message_thread_id, COALESCE(results_message_id, 0), topic, session_start_at, COALESCE(slot_seconds, 0) FROM polls WHERE poll_id=$1`, pollID).
+		Scan(&p.PollID, &p.ChatID, &p.MessageThreadID, &p.ResultsMessageID, &p.Topic, &sessionStartAt, &slotSeconds)
 	if err != nil {
 		return nil, err
 	}
+	setSchedule(&p, sessionStartAt, slotSeconds)
 	return &p, nil
 }
 
-// GetPollInfoForQueue retrieves poll information needed for queue operations.
-func (s *Repository) GetPollInfoForQueue(ctx context.Context, pollID string) (chatID int64, resultsMessageID int, topic string, err error) {
-	err = s.DB.QueryRow(ctx, `SELECT chat_id, results_message_id, topic FROM polls WHERE poll_id=$1`, pollID).
-		Scan(&chatID, &resultsMessageID, &topic)
-	return
+func setSchedule(p *TelegramPollDTO, sessionStartAt *time.Time, slotSeconds int) {
+	if sessionStartAt != nil {
+		p.SessionStartAt = sessionStartAt.UTC()
+	}
+	p.SlotDuration = time.Duration(slotSeconds) * time.Second
+}
+
+var (
+	// ErrPollNotActive is returned when changing a poll that is already being finished, or was
+	// processed, cancelled or deleted.
+	ErrPollNotActive = errors.New("poll is not active")
+	// ErrDuplicatePoll is returned when a message that already created a poll is handled again.
+	ErrDuplicatePoll = errors.New("poll was already created from this message")
+)
+
+const pollColumns = `poll_id, chat_id, message_id, topic, creator_id, COALESCE(creator_username,''), COALESCE(creator_name,''), started_at, duration_seconds, ends_at, status,
+	COALESCE(answers, ARRAY[]::TEXT[]), COALESCE(coming_answer_index, 0), COALESCE(results_message_id, 0), session_start_at, COALESCE(slot_seconds, 0), message_thread_id,
+	COALESCE(voter_counts, ARRAY[]::INT[])`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPoll(row rowScanner) (*TelegramPollDTO, error) {
+	var (
+		p               TelegramPollDTO
+		durationSeconds int
+		sessionStartAt  *time.Time
+		slotSeconds     int
+	)
+	err := row.Scan(&p.PollID, &p.ChatID, &p.MessageID, &p.Topic, &p.CreatorID, &p.CreatorUsername, &p.CreatorName, &p.StartedAt, &durationSeconds, &p.EndsAt, &p.Status,
+		&p.Answers, &p.ComingAnswerIndex, &p.ResultsMessageID, &sessionStartAt, &slotSeconds, &p.MessageThreadID, &p.VoterCounts)
+	if err != nil {
+		return nil, err
+	}
+	p.Duration = time.Duration(durationSeconds) * time.Second
+	setSchedule(&p, sessionStartAt, slotSeconds)
+	return &p, nil
+}
+
+// GetPoll retrieves all stored information about a poll.
+func (s *pgRepository) GetPoll(ctx context.Context, pollID string) (*TelegramPollDTO, error) {
+	return scanPoll(s.db.QueryRow(ctx, `SELECT `+pollColumns+` FROM polls WHERE poll_id=$1`, pollID))
+}
+
+// ListPollsByChat returns the chat's polls, newest first.
+func (s *pgRepository) ListPollsByChat(ctx context.Context, chatID int64, limit, offset int) ([]TelegramPollDTO, error) {
+	return s.queryPolls(ctx, `SELECT `+pollColumns+` FROM polls WHERE chat_id=$1 ORDER BY started_at DESC LIMIT $2 OFFSET $3`, chatID, limit, offset)
+}
+
+// ListPolls returns polls of all chats, newest first. An empty status matches any.
+func (s *pgRepository) ListPolls(ctx context.Context, status string, limit int) ([]TelegramPollDTO, error) {
+	return s.queryPolls(ctx, `SELECT `+pollColumns+` FROM polls WHERE $1 = '' OR status = $1 ORDER BY started_at DESC LIMIT $2`, status, limit)
+}
+
+// ListPollsByChatBetween returns the chat's polls started in [from, to), oldest first.
+func (s *pgRepository) ListPollsByChatBetween(ctx context.Context, chatID int64, from, to time.Time) ([]TelegramPollDTO, error) {
+	return s.queryPolls(ctx, `SELECT `+pollColumns+` FROM polls WHERE chat_id=$1 AND started_at >= $2 AND started_at < $3 ORDER BY started_at`, chatID, from, to)
+}
+
+func (s *pgRepository) queryPolls(ctx context.Context, sql string, args ...any) ([]TelegramPollDTO, error) {
+	rows, err := s.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := []TelegramPollDTO{}
+	for rows.Next() {
+		p, err := scanPoll(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *p)
+	}
+	return res, rows.Err()
+}
+
+// UpdateEndsAt moves the end of an active poll. Returns ErrPollNotActive otherwise.
+func (s *pgRepository) UpdateEndsAt(ctx context.Context, pollID string, endsAt time.Time) error {
+	tag, err := s.db.Exec(ctx, `UPDATE polls SET ends_at=$2, duration_seconds=GREATEST(EXTRACT(EPOCH FROM ($2 - started_at))::INT, 0) WHERE poll_id=$1 AND status='active'`, pollID, endsAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPollNotActive
+	}
+	return nil
+}
+
+// MarkCancelled cancels an active poll so that it is never finished. Returns ErrPollNotActive otherwise.
+func (s *pgRepository) MarkCancelled(ctx context.Context, pollID string) error {
+	tag, err := s.db.Exec(ctx, `UPDATE polls SET status='cancelled', processed_at=NOW() WHERE poll_id=$1 AND status='active'`, pollID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPollNotActive
+	}
+	return nil
+}
+
+// MarkDeleted records that the message of an active poll was deleted. Returns ErrPollNotActive otherwise.
+func (s *pgRepository) MarkDeleted(ctx context.Context, pollID string) error {
+	tag, err := s.db.Exec(ctx, `UPDATE polls SET status='deleted', processed_at=NOW() WHERE poll_id=$1 AND status='active'`, pollID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPollNotActive
+	}
+	return nil
+}
+
+// UpdateVoterCounts stores the voters per answer reported by Telegram.
+func (s *pgRepository) UpdateVoterCounts(ctx context.Context, pollID string, counts []int) error {
+	_, err := s.db.Exec(ctx, `UPDATE polls SET voter_counts=$2 WHERE poll_id=$1`, pollID, counts)
+	return err
+}
+
+func (s *pgRepository) ClaimSource(ctx context.Context, chatID int64, messageID int) (bool, error) {
+	tag, err := s.db.Exec(ctx, `INSERT INTO poll_sources (chat_id, message_id, created_at) VALUES ($1,$2,NOW()) ON CONFLICT (chat_id, message_id) DO NOTHING`, chatID, messageID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (s *pgRepository) ReleaseSource(ctx context.Context, chatID int64, messageID int) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM poll_sources WHERE chat_id=$1 AND message_id=$2 AND poll_id IS NULL`, chatID, messageID)
+	return err
+}
+
+func (s *pgRepository) DeleteSourcesBefore(ctx context.Context, t time.Time) (int64, error) {
+	tag, err := s.db.Exec(ctx, `DELETE FROM poll_sources WHERE created_at < $1`, t)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
 }
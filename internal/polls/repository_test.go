@@ -0,0 +1,417 @@
+package polls
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/nikitkaralius/lineup/internal/i18n"
+	"github.com/nikitkaralius/lineup/internal/pgtest"
+)
+
+const chatID = -1001
+
+func newPoll(id string, startedAt time.Time) *TelegramPollDTO {
+	return &TelegramPollDTO{
+		PollID:            id,
+		ChatID:            chatID,
+		MessageID:         10,
+		Topic:             "Lab " + id,
+		CreatorID:         1,
+		CreatorUsername:   "alice",
+		CreatorName:       "Alice",
+		StartedAt:         startedAt,
+		Duration:          time.Hour,
+		EndsAt:            startedAt.Add(time.Hour),
+		Answers:           []string{"Monday", "Tuesday", "Never"},
+		ComingAnswerIndex: 1,
+	}
+}
+
+func insert(t *testing.T, repo Repository, p *TelegramPollDTO) {
+	t.Helper()
+	if err := repo.InsertPoll(context.Background(), p); err != nil {
+		t.Fatalf("InsertPoll(%s): %v", p.PollID, err)
+	}
+}
+
+func TestInsertAndGetPoll(t *testing.T) {
+	repo := NewRepository(pgtest.New(t))
+	ctx := context.Background()
+
+	started := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	p := newPoll("p1", started)
+	p.SessionStartAt = started.Add(2 * time.Hour)
+	p.SlotDuration = 15 * time.Minute
+	p.MessageThreadID = 42
+	insert(t, repo, p)
+
+	got, err := repo.GetPoll(ctx, "p1")
+	if err != nil {
+		t.Fatalf("GetPoll: %v", err)
+	}
+	if got.Topic != p.Topic || got.CreatorUsername != "alice" || got.CreatorName != "Alice" || got.Status != StatusActive {
+		t.Errorf("GetPoll = %+v", got)
+	}
+	if !got.StartedAt.Equal(started) || !got.EndsAt.Equal(p.EndsAt) || got.Duration != time.Hour {
+		t.Errorf("times = %v, %v, %v", got.StartedAt, got.EndsAt, got.Duration)
+	}
+	if !slices.Equal(got.Answers, p.Answers) || got.ComingAnswerIndex != 1 {
+		t.Errorf("answers = %v, coming %d", got.Answers, got.ComingAnswerIndex)
+	}
+	if !got.SessionStartAt.Equal(p.SessionStartAt) || got.SlotDuration != 15*time.Minute {
+		t.Errorf("schedule = %v, %v", got.SessionStartAt, got.SlotDuration)
+	}
+	if got.MessageThreadID != 42 {
+		t.Errorf("MessageThreadID = %d, want 42", got.MessageThreadID)
+	}
+	if info, err := repo.GetPollInfoForQueue(ctx, "p1"); err != nil || info.MessageThreadID != 42 {
+		t.Errorf("GetPollInfoForQueue = %+v, %v, want thread 42", info, err)
+	}
+
+	// A second insert of the same poll is ignored
+	dup := newPoll("p1", started)
+	dup.Topic = "Other"
+	insert(t, repo, dup)
+	if got, _ := repo.GetPoll(ctx, "p1"); got.Topic != p.Topic {
+		t.Errorf("topic after duplicate insert = %q, want %q", got.Topic, p.Topic)
+	}
+
+	if _, err := repo.GetPoll(ctx, "missing"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetPoll(missing) error = %v, want pgx.ErrNoRows", err)
+	}
+}
+
+func TestInsertPollDefaults(t *testing.T) {
+	repo := NewRepository(pgtest.New(t))
+	ctx := context.Background()
+
+	// No answers: the default ones are stored and the coming index follows them
+	p := newPoll("p1", time.Now().UTC())
+	p.Answers = nil
+	p.ComingAnswerIndex = 1
+	insert(t, repo, p)
+
+	got, err := repo.GetPoll(ctx, "p1")
+	if err != nil {
+		t.Fatalf("GetPoll: %v", err)
+	}
+	if !slices.Equal(got.Answers, DefaultPollAnswers(i18n.Default)) || got.ComingAnswerIndex != DefaultComingAnswerIndex {
+		t.Errorf("answers = %v, coming %d", got.Answers, got.ComingAnswerIndex)
+	}
+	if !got.SessionStartAt.IsZero() || got.SlotDuration != 0 {
+		t.Errorf("schedule = %v, %v, want none", got.SessionStartAt, got.SlotDuration)
+	}
+}
+
+func TestPollInfo(t *testing.T) {
+	repo := NewRepository(pgtest.New(t))
+	ctx := context.Background()
+
+	p := newPoll("p1", time.Now().UTC())
+	insert(t, repo, p)
+
+	info, err := repo.GetPollInfo(ctx, "p1")
+	if err != nil {
+		t.Fatalf("GetPollInfo: %v", err)
+	}
+	if info.Topic != p.Topic || info.Status != StatusActive || info.ComingAnswerIndex != 1 || !info.EndsAt.Equal(p.EndsAt) {
+		t.Errorf("GetPollInfo = %+v", info)
+	}
+
+	queueInfo, err := repo.GetPollInfoForQueue(ctx, "p1")
+	if err != nil {
+		t.Fatalf("GetPollInfoForQueue: %v", err)
+	}
+	if queueInfo.ChatID != chatID || queueInfo.ResultsMessageID != 0 {
+		t.Errorf("GetPollInfoForQueue = %+v", queueInfo)
+	}
+
+	if _, err := repo.GetPollInfo(ctx, "missing"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetPollInfo(missing) error = %v, want pgx.ErrNoRows", err)
+	}
+}
+
+func TestMarkProcessed(t *testing.T) {
+	repo := NewRepository(pgtest.New(t))
+	ctx := context.Background()
+
+	insert(t, repo, newPoll("p1", time.Now().UTC()))
+	// Only publishing polls are processed
+	if err := repo.MarkProcessed(ctx, "p1", 42); !errors.Is(err, ErrPollNotActive) {
+		t.Errorf("MarkProcessed(active) error = %v, want ErrPollNotActive", err)
+	}
+	if err := repo.MarkPublishing(ctx, "p1"); err != nil {
+		t.Fatalf("MarkPublishing: %v", err)
+	}
+	if got, _ := repo.GetPoll(ctx, "p1"); got.Status != StatusPublishing {
+		t.Errorf("Status = %q, want %q", got.Status, StatusPublishing)
+	}
+	if err := repo.MarkPublishing(ctx, "p1"); !errors.Is(err, ErrPollNotActive) {
+		t.Errorf("second MarkPublishing error = %v, want ErrPollNotActive", err)
+	}
+	// Publishing polls can't be extended or cancelled either
+	if err := repo.MarkCancelled(ctx, "p1"); !errors.Is(err, ErrPollNotActive) {
+		t.Errorf("MarkCancelled(publishing) error = %v, want ErrPollNotActive", err)
+	}
+	if err := repo.MarkProcessed(ctx, "p1", 42); err != nil {
+		t.Fatalf("MarkProcessed: %v", err)
+	}
+	if err := repo.MarkProcessed(ctx, "p1", 43); !errors.Is(err, ErrPollNotActive) {
+		t.Errorf("second MarkProcessed error = %v, want ErrPollNotActive", err)
+	}
+
+	info, err := repo.GetPollInfoForQueue(ctx, "p1")
+	if err != nil {
+		t.Fatalf("GetPollInfoForQueue: %v", err)
+	}
+	if info.ResultsMessageID != 42 {
+		t.Errorf("ResultsMessageID = %d, want 42", info.ResultsMessageID)
+	}
+
+	found, err := repo.FindPollByResultsMessageID(ctx, chatID, 42)
+	if err != nil {
+		t.Fatalf("FindPollByResultsMessageID: %v", err)
+	}
+	if found.PollID != "p1" || found.ChatID != chatID {
+		t.Errorf("FindPollByResultsMessageID = %+v", found)
+	}
+	if _, err := repo.FindPollByResultsMessageID(ctx, chatID, 43); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("FindPollByResultsMessageID(43) error = %v, want pgx.ErrNoRows", err)
+	}
+	// The same message ID in another chat is another message
+	if _, err := repo.FindPollByResultsMessageID(ctx, chatID-1, 42); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("FindPollByResultsMessageID(other chat, 42) error = %v, want pgx.ErrNoRows", err)
+	}
+
+	// Processed polls can't be extended or cancelled
+	if err := repo.UpdateEndsAt(ctx, "p1", time.Now().UTC()); !errors.Is(err, ErrPollNotActive) {
+		t.Errorf("UpdateEndsAt error = %v, want ErrPollNotActive", err)
+	}
+	if err := repo.MarkCancelled(ctx, "p1"); !errors.Is(err, ErrPollNotActive) {
+		t.Errorf("MarkCancelled error = %v, want ErrPollNotActive", err)
+	}
+}
+
+func TestFindExpiredActivePolls(t *testing.T) {
+	repo := NewRepository(pgtest.New(t))
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	insert(t, repo, newPoll("expired", now.Add(-2*time.Hour)))
+	insert(t, repo, newPoll("running", now))
+	insert(t, repo, newPoll("cancelled", now.Add(-2*time.Hour)))
+	if err := repo.MarkCancelled(ctx, "cancelled"); err != nil {
+		t.Fatalf("MarkCancelled: %v", err)
+	}
+	insert(t, repo, newPoll("publishing", now.Add(-3*time.Hour)))
+	if err := repo.MarkPublishing(ctx, "publishing"); err != nil {
+		t.Fatalf("MarkPublishing: %v", err)
+	}
+
+	expired, err := repo.FindExpiredActivePolls(ctx)
+	if err != nil {
+		t.Fatalf("FindExpiredActivePolls: %v", err)
+	}
+	ids := make([]string, len(expired))
+	for i, p := range expired {
+		ids[i] = p.PollID
+	}
+	slices.Sort(ids)
+	if !slices.Equal(ids, []string{"expired", "publishing"}) {
+		t.Fatalf("FindExpiredActivePolls = %v, want the expired and the publishing poll", ids)
+	}
+	for _, p := range expired {
+		if p.ChatID != chatID || p.MessageID != 10 {
+			t.Errorf("FindExpiredActivePolls poll = %+v", p)
+		}
+	}
+}
+
+func TestUpdateEndsAt(t *testing.T) {
+	repo := NewRepository(pgtest.New(t))
+	ctx := context.Background()
+
+	started := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	insert(t, repo, newPoll("p1", started))
+
+	endsAt := started.Add(3 * time.Hour)
+	if err := repo.UpdateEndsAt(ctx, "p1", endsAt); err != nil {
+		t.Fatalf("UpdateEndsAt: %v", err)
+	}
+	got, err := repo.GetPoll(ctx, "p1")
+	if err != nil {
+		t.Fatalf("GetPoll: %v", err)
+	}
+	if !got.EndsAt.Equal(endsAt) || got.Duration != 3*time.Hour {
+		t.Errorf("EndsAt = %v, Duration = %v", got.EndsAt, got.Duration)
+	}
+
+	// Ending before the start clamps the duration to zero
+	if err := repo.UpdateEndsAt(ctx, "p1", started.Add(-time.Minute)); err != nil {
+		t.Fatalf("UpdateEndsAt: %v", err)
+	}
+	if got, _ := repo.GetPoll(ctx, "p1"); got.Duration != 0 {
+		t.Errorf("Duration = %v, want 0", got.Duration)
+	}
+
+	if err := repo.UpdateEndsAt(ctx, "missing", endsAt); !errors.Is(err, ErrPollNotActive) {
+		t.Errorf("UpdateEndsAt(missing) error = %v, want ErrPollNotActive", err)
+	}
+}
+
+func TestMarkCancelled(t *testing.T) {
+	repo := NewRepository(pgtest.New(t))
+	ctx := context.Background()
+
+	insert(t, repo, newPoll("p1", time.Now().UTC()))
+	if err := repo.MarkCancelled(ctx, "p1"); err != nil {
+		t.Fatalf("MarkCancelled: %v", err)
+	}
+	if got, _ := repo.GetPoll(ctx, "p1"); got.Status != StatusCancelled {
+		t.Errorf("Status = %q, want %q", got.Status, StatusCancelled)
+	}
+	if err := repo.MarkCancelled(ctx, "p1"); !errors.Is(err, ErrPollNotActive) {
+		t.Errorf("second MarkCancelled error = %v, want ErrPollNotActive", err)
+	}
+}
+
+func TestMarkDeleted(t *testing.T) {
+	repo := NewRepository(pgtest.New(t))
+	ctx := context.Background()
+
+	insert(t, repo, newPoll("p1", time.Now().UTC()))
+	if err := repo.MarkDeleted(ctx, "p1"); err != nil {
+		t.Fatalf("MarkDeleted: %v", err)
+	}
+	if got, _ := repo.GetPoll(ctx, "p1"); got.Status != StatusDeleted {
+		t.Errorf("Status = %q, want %q", got.Status, StatusDeleted)
+	}
+	if err := repo.MarkDeleted(ctx, "p1"); !errors.Is(err, ErrPollNotActive) {
+		t.Errorf("second MarkDeleted error = %v, want ErrPollNotActive", err)
+	}
+}
+
+func TestUpdateVoterCounts(t *testing.T) {
+	repo := NewRepository(pgtest.New(t))
+	ctx := context.Background()
+
+	insert(t, repo, newPoll("p1", time.Now().UTC()))
+	if got, _ := repo.GetPoll(ctx, "p1"); len(got.VoterCounts) != 0 {
+		t.Errorf("VoterCounts before a poll update = %v, want none", got.VoterCounts)
+	}
+	if err := repo.UpdateVoterCounts(ctx, "p1", []int{2, 0, 1}); err != nil {
+		t.Fatalf("UpdateVoterCounts: %v", err)
+	}
+	if got, _ := repo.GetPoll(ctx, "p1"); !slices.Equal(got.VoterCounts, []int{2, 0, 1}) {
+		t.Errorf("VoterCounts = %v, want [2 0 1]", got.VoterCounts)
+	}
+}
+
+func TestListPolls(t *testing.T) {
+	repo := NewRepository(pgtest.New(t))
+	ctx := context.Background()
+
+	day := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i, id := range []string{"p1", "p2", "p3"} {
+		insert(t, repo, newPoll(id, day.Add(time.Duration(i)*24*time.Hour)))
+	}
+	other := newPoll("other", day.Add(-time.Hour))
+	other.ChatID = chatID - 1
+	insert(t, repo, other)
+
+	ids := func(ps []TelegramPollDTO) []string {
+		res := []string{}
+		for _, p := range ps {
+			res = append(res, p.PollID)
+		}
+		return res
+	}
+
+	page, err := repo.ListPollsByChat(ctx, chatID, 2, 0)
+	if err != nil {
+		t.Fatalf("ListPollsByChat: %v", err)
+	}
+	if got := ids(page); !slices.Equal(got, []string{"p3", "p2"}) {
+		t.Errorf("first page = %v, want [p3 p2]", got)
+	}
+	page, err = repo.ListPollsByChat(ctx, chatID, 2, 2)
+	if err != nil {
+		t.Fatalf("ListPollsByChat: %v", err)
+	}
+	if got := ids(page); !slices.Equal(got, []string{"p1"}) {
+		t.Errorf("second page = %v, want [p1]", got)
+	}
+
+	between, err := repo.ListPollsByChatBetween(ctx, chatID, day, day.Add(48*time.Hour))
+	if err != nil {
+		t.Fatalf("ListPollsByChatBetween: %v", err)
+	}
+	if got := ids(between); !slices.Equal(got, []string{"p1", "p2"}) {
+		t.Errorf("between = %v, want [p1 p2]", got)
+	}
+
+	if err := repo.MarkCancelled(ctx, "p2"); err != nil {
+		t.Fatalf("MarkCancelled: %v", err)
+	}
+	all, err := repo.ListPolls(ctx, "", 3)
+	if err != nil {
+		t.Fatalf("ListPolls: %v", err)
+	}
+	if got := ids(all); !slices.Equal(got, []string{"p3", "p2", "p1"}) {
+		t.Errorf("all chats = %v, want [p3 p2 p1]", got)
+	}
+	active, err := repo.ListPolls(ctx, StatusActive, 10)
+	if err != nil {
+		t.Fatalf("ListPolls(active): %v", err)
+	}
+	if got := ids(active); !slices.Equal(got, []string{"p3", "p1", "other"}) {
+		t.Errorf("active = %v, want [p3 p1 other]", got)
+	}
+
+	empty, err := repo.ListPollsByChat(ctx, 1, 10, 0)
+	if err != nil || empty == nil || len(empty) != 0 {
+		t.Errorf("ListPollsByChat(no polls) = %v, %v, want an empty slice", empty, err)
+	}
+}
+
+func TestPollSources(t *testing.T) {
+	repo := NewRepository(pgtest.New(t))
+	ctx := context.Background()
+
+	claimed, err := repo.ClaimSource(ctx, chatID, 7)
+	if err != nil || !claimed {
+		t.Fatalf("ClaimSource = %v, %v, want true", claimed, err)
+	}
+	if claimed, _ := repo.ClaimSource(ctx, chatID, 7); claimed {
+		t.Error("second ClaimSource succeeded")
+	}
+	if claimed, _ := repo.ClaimSource(ctx, chatID-1, 7); !claimed {
+		t.Error("ClaimSource of the same message ID in another chat failed")
+	}
+
+	// A released claim can be taken again, one with a poll can't be released
+	if err := repo.ReleaseSource(ctx, chatID, 7); err != nil {
+		t.Fatalf("ReleaseSource: %v", err)
+	}
+	if claimed, _ := repo.ClaimSource(ctx, chatID, 7); !claimed {
+		t.Error("ClaimSource after ReleaseSource failed")
+	}
+	p := newPoll("p1", time.Now().UTC())
+	p.SourceMessageID = 7
+	insert(t, repo, p)
+	if err := repo.ReleaseSource(ctx, chatID, 7); err != nil {
+		t.Fatalf("ReleaseSource: %v", err)
+	}
+	if claimed, _ := repo.ClaimSource(ctx, chatID, 7); claimed {
+		t.Error("ClaimSource succeeded for a message that created a poll")
+	}
+
+	n, err := repo.DeleteSourcesBefore(ctx, time.Now().Add(time.Minute))
+	if err != nil || n != 2 {
+		t.Errorf("DeleteSourcesBefore = %d, %v, want 2", n, err)
+	}
+}
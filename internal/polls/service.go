@@ -3,8 +3,11 @@ package polls
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"time"
 
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/tracing"
 	"github.com/riverqueue/river"
 )
 
@@ -20,12 +23,21 @@ func NewPollsService[TTx any](client *river.Client[TTx]) Service {
 	return &pollService[TTx]{client: client}
 }
 
-func (r *pollService[TTx]) SchedulePollFinish(ctx context.Context, args FinishPollArgs, runAt time.Time) error {
-	opts := &river.InsertOpts{MaxAttempts: 1}
+func (r *pollService[TTx]) SchedulePollFinish(ctx context.Context, args FinishPollArgs, runAt time.Time) (err error) {
+	ctx, span := tracing.Start(ctx, "SchedulePollFinish", tracing.PollID.String(args.PollID), tracing.ChatID.Int64(args.ChatID))
+	defer func() { tracing.End(span, err) }()
+
+	opts := &river.InsertOpts{MaxAttempts: FinishPollMaxAttempts, UniqueOpts: river.UniqueOpts{ByArgs: true}}
 	if runAt.IsZero() {
 		return fmt.Errorf("runAt must be non zero")
 	}
 	opts.ScheduledAt = runAt
-	_, err := r.client.Insert(ctx, args, opts)
-	return err
+	res, err := r.client.Insert(ctx, args, opts)
+	if err != nil {
+		return err
+	}
+	if res.UniqueSkippedAsDuplicate {
+		slog.InfoContext(ctx, "finish poll already scheduled", logging.PollID(args.PollID), slog.Time("run_at", runAt))
+	}
+	return nil
 }
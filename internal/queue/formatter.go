@@ -1,50 +1,139 @@
 package queue
 
 import (
-	"fmt"
-	"strings"
+	"log/slog"
+	"time"
 
+	"github.com/nikitkaralius/lineup/internal/chats"
+	"github.com/nikitkaralius/lineup/internal/i18n"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/polls"
+	"github.com/nikitkaralius/lineup/internal/utils"
 	"github.com/nikitkaralius/lineup/internal/voters"
+	"github.com/nikitkaralius/lineup/internal/webhooks"
 )
 
-// FormatQueueText formats the queue as text with user information.
+// FormatQueueText formats the queue as text with user information using the chat's results template.
 // votersMap should contain user information for all userIDs in the queue.
-func FormatQueueText(topic string, queueUserIDs []int64, votersMap map[int64]voters.TelegramVoterDTO) string {
-	b := strings.Builder{}
-	b.WriteString(topic)
-	b.WriteString("\n")
-	if len(queueUserIDs) == 0 {
-		b.WriteString("No one is in the queue.")
-		return b.String()
+// Time slots are estimated from the poll's schedule and shown in the chat's timezone.
+// A custom template that fails to render falls back to the default one.
+func FormatQueueText(settings *chats.ChatSettingsDTO, poll *polls.TelegramPollDTO, queueUserIDs []int64, votersMap map[int64]voters.TelegramVoterDTO) (text string, parseMode string) {
+	return formatQueueText(ChatTemplate(settings), settings, poll, queueUserIDs, votersMap)
+}
+
+// DefaultQueueText formats the queue like FormatQueueText, but with the default template.
+// It is sent instead when Telegram rejects the markup of the chat's template.
+func DefaultQueueText(settings *chats.ChatSettingsDTO, poll *polls.TelegramPollDTO, queueUserIDs []int64, votersMap map[int64]voters.TelegramVoterDTO) (text string, parseMode string) {
+	return formatQueueText(Presets[DefaultTemplate], settings, poll, queueUserIDs, votersMap)
+}
+
+func formatQueueText(tpl Template, settings *chats.ChatSettingsDTO, poll *polls.TelegramPollDTO, queueUserIDs []int64, votersMap map[int64]voters.TelegramVoterDTO) (text string, parseMode string) {
+	data := NewTemplateData(settings.Language, utils.LoadLocation(settings.Timezone), poll, queueUserIDs, votersMap)
+
+	text, err := RenderTemplate(tpl, settings.Language, data)
+	if err != nil {
+		slog.Warn("render results template failed, using the default one", slog.String("template", tpl.Name), logging.ChatID(settings.ChatID), logging.Err(err))
+		tpl = Presets[DefaultTemplate]
+		if text, err = RenderTemplate(tpl, settings.Language, data); err != nil {
+			// The built-in presets always render with valid data, so this is a bug rather than bad input
+			slog.Error("render default results template failed", logging.ChatID(settings.ChatID), logging.Err(err))
+		}
+	}
+	return text, tpl.ParseMode
+}
+
+// ChatTemplate returns the results template selected in the chat settings.
+func ChatTemplate(settings *chats.ChatSettingsDTO) Template {
+	if settings.TemplateName == CustomTemplate && settings.TemplateBody != "" {
+		return Template{Name: CustomTemplate, Body: settings.TemplateBody, ParseMode: settings.TemplateParseMode}
+	}
+	if tpl, ok := Presets[settings.TemplateName]; ok {
+		return tpl
+	}
+	return Presets[DefaultTemplate]
+}
+
+// NewTemplateData builds the template data model for the queue of poll.
+// Slot times are formatted in loc.
+func NewTemplateData(lang i18n.Lang, loc *time.Location, poll *polls.TelegramPollDTO, queueUserIDs []int64, votersMap map[int64]voters.TelegramVoterDTO) TemplateData {
+	data := TemplateData{
+		Topic:    poll.Topic,
+		Lang:     string(lang),
+		Empty:    len(queueUserIDs) == 0,
+		HasSlots: hasSlots(poll),
+		Entries:  make([]TemplateEntry, 0, len(queueUserIDs)),
+	}
+
+	var slots []time.Time
+	if data.HasSlots {
+		slots = ComputeSlots(poll.SessionStartAt, poll.SlotDuration, len(queueUserIDs))
 	}
 
 	for i, userID := range queueUserIDs {
 		voter, exists := votersMap[userID]
-		if !exists {
-			voter = voters.TelegramVoterDTO{
-				UserID:   userID,
-				Username: "",
-				Name:     "Unknown",
-			}
+		e := TemplateEntry{
+			Position: i + 1,
+			UserID:   userID,
+			Username: voter.Username,
+			Name:     voter.Name,
+			Known:    exists,
 		}
 
-		b.WriteString(fmt.Sprintf("%d. ", i+1))
-		if voter.Username != "" {
-			b.WriteString("@")
-			b.WriteString(voter.Username)
-			if voter.Name != "" {
-				b.WriteString(" (")
-				b.WriteString(voter.Name)
-				b.WriteString(")")
-			}
-		} else {
-			if voter.Name != "" {
-				b.WriteString(voter.Name)
-			} else {
-				b.WriteString("Anonymous")
+		switch {
+		case e.Name != "":
+			e.Label = e.Name
+		case !exists:
+			e.Label = i18n.T(lang, i18n.VoterUnknown)
+		default:
+			e.Label = i18n.T(lang, i18n.VoterAnonymous)
+		}
+
+		if slots != nil {
+			e.SlotStart = slots[i]
+			e.Slot = utils.FormatTimeShort(slots[i], loc)
+		}
+
+		e.Display = e.Label
+		if e.Username != "" {
+			e.Display = "@" + e.Username
+			if e.Name != "" {
+				e.Display += " (" + e.Name + ")"
 			}
 		}
-		b.WriteString("\n")
+
+		data.Entries = append(data.Entries, e)
+	}
+	return data
+}
+
+// NewWebhookData builds the queue.updated and poll.finished event data for the queue of poll.
+func NewWebhookData(poll *polls.TelegramPollDTO, queueUserIDs []int64, votersMap map[int64]voters.TelegramVoterDTO, reason string, userID int64) webhooks.QueueData {
+	data := webhooks.QueueData{Topic: poll.Topic, Reason: reason, UserID: userID, Queue: make([]webhooks.QueueEntry, len(queueUserIDs))}
+
+	var slots []time.Time
+	if hasSlots(poll) {
+		slots = ComputeSlots(poll.SessionStartAt, poll.SlotDuration, len(queueUserIDs))
+	}
+	for i, id := range queueUserIDs {
+		v := votersMap[id]
+		data.Queue[i] = webhooks.QueueEntry{Position: i + 1, UserID: id, Username: v.Username, Name: v.Name}
+		if slots != nil {
+			data.Queue[i].SlotStart = &slots[i]
+		}
+	}
+	return data
+}
+
+// ComputeSlots returns the estimated start of each of n queue positions
+// when everyone gets slot time starting at start.
+func ComputeSlots(start time.Time, slot time.Duration, n int) []time.Time {
+	slots := make([]time.Time, n)
+	for i := range slots {
+		slots[i] = start.Add(time.Duration(i) * slot)
 	}
-	return b.String()
+	return slots
+}
+
+func hasSlots(poll *polls.TelegramPollDTO) bool {
+	return !poll.SessionStartAt.IsZero() && poll.SlotDuration > 0
 }
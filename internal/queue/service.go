@@ -2,83 +2,177 @@ package queue
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
 
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nikitkaralius/lineup/internal/chats"
 	"github.com/nikitkaralius/lineup/internal/llm"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/messenger"
+	"github.com/nikitkaralius/lineup/internal/metrics"
 	"github.com/nikitkaralius/lineup/internal/polls"
 	"github.com/nikitkaralius/lineup/internal/voters"
+	"github.com/nikitkaralius/lineup/internal/webhooks"
+)
+
+var (
+	ErrAlreadyInQueue = errors.New("user is already in the queue")
+	ErrNotInQueue     = errors.New("user is not in the queue")
+	ErrQueueMismatch  = errors.New("new order must contain exactly the users in the queue")
+	ErrNotPublished   = errors.New("lineup is not published yet")
 )
 
 // Service handles queue operations.
 type Service struct {
-	pollsRepo  *polls.Repository
-	votersRepo *voters.Repository
-	bot        *tgbotapi.BotAPI
+	pollsRepo  polls.Repository
+	votersRepo voters.Repository
+	chatsRepo  *chats.Repository
+	bot        messenger.Messenger
 	llmClient  *llm.Client
+	events     webhooks.Publisher
 }
 
-// NewService creates a new queue service.
-func NewService(pollsRepo *polls.Repository, votersRepo *voters.Repository, bot *tgbotapi.BotAPI, llmClient *llm.Client) *Service {
+// NewService creates a new queue service. events may be nil, then no webhooks are notified.
+func NewService(pollsRepo polls.Repository, votersRepo voters.Repository, chatsRepo *chats.Repository, bot messenger.Messenger, llmClient *llm.Client, events webhooks.Publisher) *Service {
 	return &Service{
 		pollsRepo:  pollsRepo,
 		votersRepo: votersRepo,
+		chatsRepo:  chatsRepo,
 		bot:        bot,
 		llmClient:  llmClient,
+		events:     events,
 	}
 }
 
 // JoinQueue adds a user to the end of the queue.
-func (s *Service) JoinQueue(ctx context.Context, pollID string, userID int64) error {
-	queueUserIDs, err := s.votersRepo.GetQueueUserIDs(ctx, pollID)
+func (s *Service) JoinQueue(ctx context.Context, pollID string, userID int64) (err error) {
+	defer func() { observeOperation("join", err) }()
+
+	queueUserIDs, err := s.changeQueue(ctx, pollID, func(queueUserIDs []int64) ([]int64, error) {
+		if slices.Contains(queueUserIDs, userID) {
+			return nil, ErrAlreadyInQueue
+		}
+		return append(queueUserIDs, userID), nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get queue: %w", err)
+		return err
 	}
+	s.publishQueueUpdated(ctx, pollID, webhooks.ReasonJoin, userID, queueUserIDs)
+
+	return s.UpdateQueueMessage(ctx, pollID)
+}
+
+// LeaveQueue removes a user from the queue.
+func (s *Service) LeaveQueue(ctx context.Context, pollID string, userID int64) (err error) {
+	defer func() { observeOperation("leave", err) }()
 
-	// Check if user is already in queue
-	for _, id := range queueUserIDs {
-		if id == userID {
-			return fmt.Errorf("вы уже в очереди")
+	queueUserIDs, err := s.changeQueue(ctx, pollID, func(queueUserIDs []int64) ([]int64, error) {
+		i := slices.Index(queueUserIDs, userID)
+		if i < 0 {
+			return nil, ErrNotInQueue
 		}
+		return slices.Delete(queueUserIDs, i, i+1), nil
+	})
+	if err != nil {
+		return err
 	}
+	s.publishQueueUpdated(ctx, pollID, webhooks.ReasonLeave, userID, queueUserIDs)
+
+	return s.UpdateQueueMessage(ctx, pollID)
+}
 
-	// Add to end
-	queueUserIDs = append(queueUserIDs, userID)
+// ReorderQueue replaces the queue order. userIDs must contain exactly the users already in the queue.
+func (s *Service) ReorderQueue(ctx context.Context, pollID string, userIDs []int64) (err error) {
+	defer func() { observeOperation("reorder", err) }()
 
-	if err := s.votersRepo.UpdateQueueUserIDs(ctx, pollID, queueUserIDs); err != nil {
-		return fmt.Errorf("failed to update queue: %w", err)
+	queueUserIDs, err := s.changeQueue(ctx, pollID, func(queueUserIDs []int64) ([]int64, error) {
+		if !samePeople(queueUserIDs, userIDs) {
+			return nil, ErrQueueMismatch
+		}
+		return userIDs, nil
+	})
+	if err != nil {
+		return err
 	}
+	s.publishQueueUpdated(ctx, pollID, webhooks.ReasonReorder, 0, queueUserIDs)
 
 	return s.UpdateQueueMessage(ctx, pollID)
 }
 
-// LeaveQueue removes a user from the queue.
-func (s *Service) LeaveQueue(ctx context.Context, pollID string, userID int64) error {
-	queueUserIDs, err := s.votersRepo.GetQueueUserIDs(ctx, pollID)
+// changeQueue applies change to the queue of a poll whose lineup is published and returns
+// the new queue. Changes of one poll apply one after the other, each to the queue the one
+// before left. Refusals by change are returned as is.
+func (s *Service) changeQueue(ctx context.Context, pollID string, change func([]int64) ([]int64, error)) ([]int64, error) {
+	poll, err := s.pollsRepo.GetPollInfoForQueue(ctx, pollID)
 	if err != nil {
-		return fmt.Errorf("failed to get queue: %w", err)
+		return nil, fmt.Errorf("failed to get poll info: %w", err)
 	}
-
-	// Remove user from queue
-	newQueue := make([]int64, 0, len(queueUserIDs))
-	found := false
-	for _, id := range queueUserIDs {
-		if id != userID {
-			newQueue = append(newQueue, id)
-		} else {
-			found = true
-		}
+	// The queue of a poll still publishing is about to be sent as it is
+	if poll.ResultsMessageID == 0 {
+		return nil, ErrNotPublished
+	}
+	queueUserIDs, err := s.votersRepo.ChangeQueue(ctx, pollID, change)
+	if err != nil && !rejected(err) {
+		return nil, fmt.Errorf("failed to update queue: %w", err)
 	}
+	return queueUserIDs, err
+}
 
-	if !found {
-		return fmt.Errorf("вы не в очереди")
+// observeOperation counts a queue operation; refusals like joining twice are not errors.
+func observeOperation(operation string, err error) {
+	result := metrics.Result(err)
+	if rejected(err) {
+		result = metrics.ResultRejected
 	}
+	metrics.QueueOperations.WithLabelValues(operation, result).Inc()
+}
 
-	if err := s.votersRepo.UpdateQueueUserIDs(ctx, pollID, newQueue); err != nil {
-		return fmt.Errorf("failed to update queue: %w", err)
+// rejected reports whether err refuses a queue change rather than failing it.
+func rejected(err error) bool {
+	return errors.Is(err, ErrAlreadyInQueue) || errors.Is(err, ErrNotInQueue) || errors.Is(err, ErrQueueMismatch) || errors.Is(err, ErrNotPublished)
+}
+
+// samePeople reports whether b is a permutation of a without duplicates.
+func samePeople(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[int64]bool, len(a))
+	for _, id := range a {
+		seen[id] = true
+	}
+	for _, id := range b {
+		if !seen[id] {
+			return false
+		}
+		delete(seen, id)
 	}
+	return true
+}
 
-	return s.UpdateQueueMessage(ctx, pollID)
+// publishQueueUpdated notifies the chat's webhooks of a queue change. Failures are only logged,
+// the change itself is already saved.
+func (s *Service) publishQueueUpdated(ctx context.Context, pollID, reason string, userID int64, queueUserIDs []int64) {
+	if s.events == nil {
+		return
+	}
+	ctx = logging.With(ctx, logging.PollID(pollID))
+	poll, err := s.pollsRepo.GetPollInfoForQueue(ctx, pollID)
+	if err != nil {
+		slog.ErrorContext(ctx, "publish event failed", slog.String("event", webhooks.EventQueueUpdated), logging.Err(err))
+		return
+	}
+	votersMap, err := s.votersRepo.GetVotersInfo(ctx, pollID, queueUserIDs)
+	if err != nil {
+		slog.ErrorContext(ctx, "publish event failed", slog.String("event", webhooks.EventQueueUpdated), logging.ChatID(poll.ChatID), logging.Err(err))
+		return
+	}
+	data := NewWebhookData(poll, queueUserIDs, votersMap, reason, userID)
+	if err := s.events.Publish(ctx, poll.ChatID, webhooks.EventQueueUpdated, pollID, data); err != nil {
+		slog.ErrorContext(ctx, "publish event failed", slog.String("event", webhooks.EventQueueUpdated), logging.ChatID(poll.ChatID), logging.Err(err))
+	}
 }
 
 // GetQueue retrieves the current queue order.
@@ -92,15 +186,16 @@ func (s *Service) ParseQueueIntent(ctx context.Context, text string) (*llm.Queue
 }
 
 // UpdateQueueMessage regenerates and updates the result message in Telegram.
+// Time slots of everyone in the queue are recomputed from the new order.
 func (s *Service) UpdateQueueMessage(ctx context.Context, pollID string) error {
 	// Get poll info from repository
-	chatID, resultsMessageID, topic, err := s.pollsRepo.GetPollInfoForQueue(ctx, pollID)
+	poll, err := s.pollsRepo.GetPollInfoForQueue(ctx, pollID)
 	if err != nil {
 		return fmt.Errorf("failed to get poll info: %w", err)
 	}
 
-	if resultsMessageID == 0 {
-		return fmt.Errorf("results message not found")
+	if poll.ResultsMessageID == 0 {
+		return ErrNotPublished
 	}
 
 	queueUserIDs, err := s.votersRepo.GetQueueUserIDs(ctx, pollID)
@@ -115,10 +210,16 @@ func (s *Service) UpdateQueueMessage(ctx context.Context, pollID string) error {
 	}
 
 	// Format queue text
-	text := FormatQueueText(topic, queueUserIDs, votersMap)
+	settings := s.chatsRepo.GetSettingsOrDefault(ctx, poll.ChatID, poll.MessageThreadID)
+	edit := messenger.Edit{ChatID: poll.ChatID, MessageID: poll.ResultsMessageID}
+	edit.Text, edit.ParseMode = FormatQueueText(settings, poll, queueUserIDs, votersMap)
 
 	// Update message
-	editMsg := tgbotapi.NewEditMessageText(chatID, resultsMessageID, text)
-	_, err = s.bot.Send(editMsg)
+	err = s.bot.EditMessage(ctx, edit)
+	if errors.Is(err, messenger.ErrBadMarkup) {
+		slog.WarnContext(ctx, "telegram rejected the results template, using the default one", logging.ChatID(poll.ChatID), logging.Err(err))
+		edit.Text, edit.ParseMode = DefaultQueueText(settings, poll, queueUserIDs, votersMap)
+		err = s.bot.EditMessage(ctx, edit)
+	}
 	return err
 }
@@ -0,0 +1,225 @@
+package queue
+
+import (
+	"fmt"
+	"html"
+	htmltemplate "html/template"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/nikitkaralius/lineup/internal/i18n"
+	"github.com/nikitkaralius/lineup/internal/messenger"
+)
+
+// TemplateData is the data model results templates are executed with.
+//
+//	.Topic    poll topic as shown in the poll (with its end time)
+//	.Lang     chat language code, e.g. "ru"
+//	.Empty    true if nobody is in the queue
+//	.HasSlots true if the poll has a session start and a slot length
+//	.Entries  queue in order, each entry has:
+//	  .Position  1-based place in the queue
+//	  .UserID    Telegram user ID
+//	  .Username  Telegram username without "@", may be empty
+//	  .Name      Telegram display name, may be empty
+//	  .Label     Name, or a localized placeholder if it is empty
+//	  .Display   "@username (Name)" if the user has a username, otherwise Label
+//	  .Known     false if the user never voted in the poll
+//	  .Slot      estimated start as HH:MM in the chat timezone, empty without slots
+//	  .SlotStart estimated start as time.Time, zero without slots
+//
+// Templates with HTML parse mode are executed with html/template, so user-supplied
+// topics and names are escaped automatically.
+//
+// Template functions:
+//
+//	t "key"     catalog message in the chat language, e.g. {{t "queue_empty"}}
+//	escape s    HTML-escapes s; a no-op for HTML templates, which escape anyway
+//	mention e   HTML mention of entry e: "@username (Name)", or Label linked to tg://user?id=
+//	            for users without a username, so that everyone is notified; Display in plain text
+//	link e      entry's Label linked to tg://user?id=, whether or not it has a username;
+//	            Label in plain text, which cannot link
+type TemplateData struct {
+	Topic    string
+	Lang     string
+	Empty    bool
+	HasSlots bool
+	Entries  []TemplateEntry
+}
+
+// TemplateEntry is a single queue position in TemplateData.
+type TemplateEntry struct {
+	Position  int
+	UserID    int64
+	Username  string
+	Name      string
+	Label     string
+	Display   string
+	Known     bool
+	Slot      string
+	SlotStart time.Time
+}
+
+// Template is a results message layout.
+type Template struct {
+	Name      string
+	Body      string
+	ParseMode string // "" for plain text or messenger.ModeHTML
+}
+
+// Template names stored in chat settings.
+const (
+	DefaultTemplate = "default"
+	CustomTemplate  = "custom"
+)
+
+// MaxTemplateSize limits uploaded templates to fit in a Telegram message.
+const MaxTemplateSize = 3500
+
+// Presets are the built-in results templates selectable with /template.
+// All but no_usernames mention everyone in the queue.
+var Presets = map[string]Template{
+	DefaultTemplate: {
+		Name: DefaultTemplate,
+		Body: `{{.Topic}}
+{{if .Empty}}{{t "queue_empty"}}{{else}}{{range .Entries}}{{.Position}}. {{if .Slot}}{{.Slot}} — {{end}}{{mention .}}
+{{end}}{{end}}`,
+		ParseMode: messenger.ModeHTML,
+	},
+	"no_usernames": {
+		Name: "no_usernames",
+		Body: `{{.Topic}}
+{{if .Empty}}{{t "queue_empty"}}{{else}}{{range .Entries}}{{.Position}}. {{if .Slot}}{{.Slot}} — {{end}}{{.Label}}
+{{end}}{{end}}`,
+	},
+	"html": {
+		Name: "html",
+		Body: `<b>{{.Topic}}</b>
+{{if .Empty}}<i>{{t "queue_empty"}}</i>{{else}}{{range .Entries}}<b>{{.Position}}.</b> {{if .Slot}}<i>{{.Slot}}</i> — {{end}}{{mention .}}
+{{end}}{{end}}`,
+		ParseMode: messenger.ModeHTML,
+	},
+	"compact": {
+		Name: "compact",
+		Body: `{{.Topic}}
+{{if .Empty}}{{t "queue_empty"}}{{else}}{{range $i, $e := .Entries}}{{if $i}} → {{end}}{{link $e}}{{end}}{{end}}`,
+		ParseMode: messenger.ModeHTML,
+	},
+}
+
+// PresetNames returns the names of the built-in templates sorted alphabetically.
+func PresetNames() []string {
+	names := make([]string, 0, len(Presets))
+	for name := range Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RenderTemplate executes tpl with data in the given language.
+func RenderTemplate(tpl Template, lang i18n.Lang, data TemplateData) (string, error) {
+	t, err := parseTemplate(tpl, lang)
+	if err != nil {
+		return "", err
+	}
+	b := strings.Builder{}
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(b.String()) == "" {
+		return "", fmt.Errorf("template rendered an empty message")
+	}
+	return b.String(), nil
+}
+
+// ValidateTemplate checks that tpl parses and renders sample data, and for HTML templates,
+// that the result only has tags Telegram supports.
+func ValidateTemplate(tpl Template) error {
+	if len(tpl.Body) > MaxTemplateSize {
+		return fmt.Errorf("template is longer than %d bytes", MaxTemplateSize)
+	}
+	sample := TemplateData{
+		Topic:    "Topic",
+		Lang:     string(i18n.Default),
+		HasSlots: true,
+		Entries: []TemplateEntry{
+			{Position: 1, UserID: 1, Username: "username", Name: "Name", Label: "Name", Display: "@username (Name)", Known: true, Slot: "12:00", SlotStart: time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC)},
+		},
+	}
+	text, err := RenderTemplate(tpl, i18n.Default, sample)
+	if err != nil {
+		return err
+	}
+	if tpl.ParseMode == messenger.ModeHTML {
+		return checkTelegramHTML(text)
+	}
+	return nil
+}
+
+// telegramTags are the HTML tags Telegram supports in messages. A span must be a spoiler.
+var telegramTags = map[string]bool{
+	"b": true, "strong": true, "i": true, "em": true, "u": true, "ins": true, "s": true, "strike": true, "del": true,
+	"span": true, "tg-spoiler": true, "a": true, "tg-emoji": true, "code": true, "pre": true, "blockquote": true,
+}
+
+var htmlTag = regexp.MustCompile(`<\s*/?\s*([a-zA-Z][a-zA-Z0-9-]*)([^>]*)>`)
+
+// checkTelegramHTML returns an error for the first tag in text that Telegram rejects.
+func checkTelegramHTML(text string) error {
+	for _, m := range htmlTag.FindAllStringSubmatch(text, -1) {
+		tag, attrs := strings.ToLower(m[1]), m[2]
+		if !telegramTags[tag] {
+			return fmt.Errorf("tag <%s> is not supported by Telegram, use b, i, u, s, a, code, pre, blockquote or tg-spoiler", tag)
+		}
+		if tag == "span" && !strings.HasPrefix(m[0], "</") && !strings.Contains(attrs, `class="tg-spoiler"`) {
+			return fmt.Errorf(`tag <span> is only supported by Telegram with class="tg-spoiler"`)
+		}
+	}
+	return nil
+}
+
+type executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+func parseTemplate(tpl Template, lang i18n.Lang) (executor, error) {
+	translate := func(key string) string { return i18n.T(lang, i18n.Key(key)) }
+
+	if tpl.ParseMode != messenger.ModeHTML {
+		funcs := template.FuncMap{
+			"t":       translate,
+			"escape":  html.EscapeString,
+			"mention": func(e TemplateEntry) string { return e.Display },
+			"link":    func(e TemplateEntry) string { return e.Label },
+		}
+		return template.New("results").Funcs(funcs).Parse(tpl.Body)
+	}
+
+	funcs := htmltemplate.FuncMap{
+		"t":       translate,
+		"escape":  func(s string) string { return s },
+		"mention": mentionHTML,
+		"link":    linkHTML,
+	}
+	return htmltemplate.New("results").Funcs(funcs).Parse(tpl.Body)
+}
+
+// mentionHTML renders an entry as "@username (Name)" or, for users without a username,
+// as their label linked to tg://user?id=. User-supplied parts are escaped.
+func mentionHTML(e TemplateEntry) htmltemplate.HTML {
+	if e.Username != "" {
+		return htmltemplate.HTML(html.EscapeString(e.Display))
+	}
+	return linkHTML(e)
+}
+
+// linkHTML renders the entry's label linked to tg://user?id=, which notifies the user.
+func linkHTML(e TemplateEntry) htmltemplate.HTML {
+	return htmltemplate.HTML(`<a href="tg://user?id=` + strconv.FormatInt(e.UserID, 10) + `">` + html.EscapeString(e.Label) + `</a>`)
+}
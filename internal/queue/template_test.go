@@ -0,0 +1,120 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/nikitkaralius/lineup/internal/i18n"
+	"github.com/nikitkaralius/lineup/internal/messenger"
+)
+
+func TestRenderPlainTemplate(t *testing.T) {
+	tpl := Template{Name: CustomTemplate, Body: `{{range .Entries}}{{.Position}}. {{mention .}} / {{link .}}
+{{end}}`}
+	data := TemplateData{Entries: []TemplateEntry{
+		{Position: 1, UserID: 1, Username: "alice", Name: "Alice", Label: "Alice", Display: "@alice (Alice)"},
+		{Position: 2, UserID: 2, Name: "Bob", Label: "Bob", Display: "Bob"},
+	}}
+
+	got, err := RenderTemplate(tpl, i18n.English, data)
+	if err != nil {
+		t.Fatalf("RenderTemplate: %v", err)
+	}
+	// Sent without a parse mode, so markup would show up as is
+	want := "1. @alice (Alice) / Alice\n2. Bob / Bob\n"
+	if got != want {
+		t.Errorf("RenderTemplate = %q, want %q", got, want)
+	}
+}
+
+func TestRenderTemplateEscapesHTML(t *testing.T) {
+	hostile := TemplateData{
+		Topic: `<b>Lab</b> & "more"`,
+		Entries: []TemplateEntry{
+			{Position: 1, UserID: 1, Username: "alice", Name: `<i>A</i>`, Label: `<i>A</i>`, Display: `@alice (<i>A</i>)`},
+			{Position: 2, UserID: 2, Name: `Tom & "Jerry"`, Label: `Tom & "Jerry"`, Display: `Tom & "Jerry"`},
+		},
+	}
+	tests := []struct {
+		name string
+		tpl  Template
+		want string
+	}{
+		{
+			name: "default",
+			tpl:  Presets[DefaultTemplate],
+			want: "&lt;b&gt;Lab&lt;/b&gt; &amp; &#34;more&#34;\n" +
+				"1. @alice (&lt;i&gt;A&lt;/i&gt;)\n" +
+				"2. <a href=\"tg://user?id=2\">Tom &amp; &#34;Jerry&#34;</a>\n",
+		},
+		{
+			name: "compact",
+			tpl:  Presets["compact"],
+			want: "&lt;b&gt;Lab&lt;/b&gt; &amp; &#34;more&#34;\n" +
+				"<a href=\"tg://user?id=1\">&lt;i&gt;A&lt;/i&gt;</a> → <a href=\"tg://user?id=2\">Tom &amp; &#34;Jerry&#34;</a>",
+		},
+		{
+			name: "escape is a no-op",
+			tpl:  Template{Name: CustomTemplate, Body: `{{escape .Topic}}`, ParseMode: messenger.ModeHTML},
+			want: "&lt;b&gt;Lab&lt;/b&gt; &amp; &#34;more&#34;",
+		},
+		{
+			name: "label in attribute",
+			tpl:  Template{Name: CustomTemplate, Body: `{{range .Entries}}<a title="{{.Label}}">{{.Position}}</a>{{end}}`, ParseMode: messenger.ModeHTML},
+			want: `<a title="&lt;i&gt;A&lt;/i&gt;">1</a><a title="Tom &amp; &#34;Jerry&#34;">2</a>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderTemplate(tt.tpl, i18n.English, hostile)
+			if err != nil {
+				t.Fatalf("RenderTemplate: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("RenderTemplate = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMentionHTMLEscapes(t *testing.T) {
+	tests := []struct {
+		entry TemplateEntry
+		want  string
+	}{
+		{TemplateEntry{UserID: 1, Username: "a", Display: `@a (<script>)`}, `@a (&lt;script&gt;)`},
+		{TemplateEntry{UserID: 2, Label: `a & b`}, `<a href="tg://user?id=2">a &amp; b</a>`},
+		{TemplateEntry{UserID: 3, Label: `"><b>x</b>`}, `<a href="tg://user?id=3">&#34;&gt;&lt;b&gt;x&lt;/b&gt;</a>`},
+	}
+	for _, tt := range tests {
+		if got := string(mentionHTML(tt.entry)); got != tt.want {
+			t.Errorf("mentionHTML(%+v) = %q, want %q", tt.entry, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTemplateTelegramTags(t *testing.T) {
+	tests := []struct {
+		body    string
+		wantErr bool
+	}{
+		{`<b>{{.Topic}}</b> <i>x</i> <u>x</u> <s>x</s> <code>x</code> <pre>x</pre> <blockquote>x</blockquote>`, false},
+		{`{{range .Entries}}{{mention .}} {{link .}}{{end}}`, false},
+		{`<span class="tg-spoiler">{{.Topic}}</span> <tg-spoiler>x</tg-spoiler>`, false},
+		{`<div>{{.Topic}}</div>`, true},
+		{`<p>{{.Topic}}</p>`, true},
+		{`{{.Topic}}<br>`, true},
+		{`<span>{{.Topic}}</span>`, true},
+		{`<B>{{.Topic}}</B>`, false},
+	}
+	for _, tt := range tests {
+		err := ValidateTemplate(Template{Name: CustomTemplate, Body: tt.body, ParseMode: messenger.ModeHTML})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateTemplate(%q) = %v, want error %v", tt.body, err, tt.wantErr)
+		}
+	}
+
+	// Plain templates are sent without a parse mode, so tags are just text
+	if err := ValidateTemplate(Template{Name: CustomTemplate, Body: `<div>{{.Topic}}</div>`}); err != nil {
+		t.Errorf("ValidateTemplate of a plain template = %v, want nil", err)
+	}
+}
@@ -0,0 +1,85 @@
+// Package ratelimit keeps the turns of Bot API requests in Postgres, so that the service
+// and the worker keep to Telegram's rate limits together.
+package ratelimit
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nikitkaralius/lineup/internal/messenger"
+)
+
+// globalChatID is the send_turns row of the turn all requests share.
+const globalChatID = 0
+
+type Repository struct {
+	DB *pgxpool.Pool
+}
+
+var _ messenger.Turns = (*Repository)(nil)
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{DB: db}
+}
+
+// Take takes the global turn and, for a non-zero chatID, the chat's turn if both are due.
+// The rows are locked in chat ID order, so that concurrent calls cannot deadlock.
+//
+// Every Bot API request locks the global row for one short transaction, so requests of all
+// processes take their turns one at a time. That allows far more than the 30 requests a
+// second Telegram does, and a request waits for at most the ones taking a turn at once.
+func (r *Repository) Take(ctx context.Context, chatID int64, global, chat time.Duration) (time.Duration, error) {
+	ids := []int64{globalChatID}
+	if chatID != globalChatID {
+		ids = append(ids, chatID)
+	}
+	slices.Sort(ids)
+
+	var wait time.Duration
+	err := pgx.BeginFunc(ctx, r.DB, func(tx pgx.Tx) error {
+		// Locks existing rows too, so that DeleteIdleBefore cannot remove them meanwhile
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO send_turns (chat_id, next_at) SELECT unnest($1::BIGINT[]), 'epoch'::TIMESTAMPTZ
+			ON CONFLICT (chat_id) DO UPDATE SET next_at = send_turns.next_at`, ids); err != nil {
+			return err
+		}
+		var waitUs int64
+		err := tx.QueryRow(ctx, `
+			SELECT GREATEST(CEIL(EXTRACT(EPOCH FROM MAX(next_at) - clock_timestamp()) * 1000000), 0)::BIGINT
+			FROM (SELECT next_at FROM send_turns WHERE chat_id = ANY($1) ORDER BY chat_id FOR UPDATE) turns`, ids).Scan(&waitUs)
+		if err != nil {
+			return err
+		}
+		if waitUs > 0 {
+			wait = time.Duration(waitUs) * time.Microsecond
+			return nil
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE send_turns
+			SET next_at = clock_timestamp() + CASE WHEN chat_id = 0 THEN $2::BIGINT ELSE $3::BIGINT END * INTERVAL '1 microsecond'
+			WHERE chat_id = ANY($1)`, ids, global.Microseconds(), chat.Microseconds())
+		return err
+	})
+	return wait, err
+}
+
+// Pause makes requests to chatID, or all requests for a zero chatID, wait at least d.
+func (r *Repository) Pause(ctx context.Context, chatID int64, d time.Duration) error {
+	_, err := r.DB.Exec(ctx, `
+		INSERT INTO send_turns (chat_id, next_at) VALUES ($1, clock_timestamp() + $2::BIGINT * INTERVAL '1 microsecond')
+		ON CONFLICT (chat_id) DO UPDATE SET next_at = GREATEST(send_turns.next_at, EXCLUDED.next_at)`, chatID, d.Microseconds())
+	return err
+}
+
+// DeleteIdleBefore forgets the turns of chats that were due before t and returns how many
+// there were. A forgotten chat's turn is due, as it was.
+func (r *Repository) DeleteIdleBefore(ctx context.Context, t time.Time) (int64, error) {
+	tag, err := r.DB.Exec(ctx, `DELETE FROM send_turns WHERE chat_id <> $1 AND next_at < $2`, globalChatID, t)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nikitkaralius/lineup/internal/pgtest"
+)
+
+const group int64 = -100
+
+func TestTake(t *testing.T) {
+	db := pgtest.New(t)
+	// Two repositories on one database stand for the service and the worker
+	serve, work := NewRepository(db), NewRepository(db)
+	ctx := context.Background()
+
+	if wait, err := serve.Take(ctx, group, time.Millisecond, time.Hour); err != nil || wait != 0 {
+		t.Fatalf("first Take = %v, %v, want the turn", wait, err)
+	}
+	wait, err := work.Take(ctx, group, time.Millisecond, time.Hour)
+	if err != nil {
+		t.Fatalf("Take: %v", err)
+	}
+	if wait < 59*time.Minute {
+		t.Errorf("second Take in the group waits %v, want the group interval", wait)
+	}
+
+	// Another chat only waits for the global turn
+	time.Sleep(5 * time.Millisecond)
+	if wait, err := work.Take(ctx, 1, time.Hour, time.Second); err != nil || wait != 0 {
+		t.Errorf("Take in another chat = %v, %v, want the turn", wait, err)
+	}
+	if wait, err := serve.Take(ctx, 2, time.Millisecond, time.Second); err != nil || wait < 59*time.Minute {
+		t.Errorf("Take after a global interval of an hour = %v, %v, want to wait", wait, err)
+	}
+}
+
+func TestPause(t *testing.T) {
+	repo := NewRepository(pgtest.New(t))
+	ctx := context.Background()
+
+	if err := repo.Pause(ctx, group, time.Minute); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	// A shorter pause does not shorten the wait
+	if err := repo.Pause(ctx, group, time.Second); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	if wait, err := repo.Take(ctx, group, 0, 0); err != nil || wait < 50*time.Second {
+		t.Errorf("Take in a paused chat = %v, %v, want to wait about a minute", wait, err)
+	}
+	if wait, err := repo.Take(ctx, 1, 0, 0); err != nil || wait != 0 {
+		t.Errorf("Take in another chat = %v, %v, want the turn", wait, err)
+	}
+}
+
+func TestDeleteIdleBefore(t *testing.T) {
+	repo := NewRepository(pgtest.New(t))
+	ctx := context.Background()
+
+	if _, err := repo.Take(ctx, group, 0, time.Millisecond); err != nil {
+		t.Fatalf("Take: %v", err)
+	}
+	if _, err := repo.Take(ctx, 1, 0, time.Hour); err != nil {
+		t.Fatalf("Take: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// Only the group is idle; the global turn is never forgotten
+	n, err := repo.DeleteIdleBefore(ctx, time.Now())
+	if err != nil || n != 1 {
+		t.Fatalf("DeleteIdleBefore = %d, %v, want 1", n, err)
+	}
+	if wait, err := repo.Take(ctx, group, 0, time.Hour); err != nil || wait != 0 {
+		t.Errorf("Take in the forgotten group = %v, %v, want the turn", wait, err)
+	}
+	if wait, err := repo.Take(ctx, 1, 0, time.Hour); err != nil || wait < 59*time.Minute {
+		t.Errorf("Take in the busy chat = %v, %v, want to wait", wait, err)
+	}
+}
@@ -0,0 +1,19 @@
+package stats
+
+// PersonStatsDTO summarizes how a person took part in a chat's finished polls.
+type PersonStatsDTO struct {
+	UserID          int64
+	Username        string
+	Name            string
+	Polls           int     // finished polls the person voted in or was queued for
+	Attended        int     // finished polls the person ended up in the queue of
+	AveragePosition float64 // mean 1-based queue position over attended polls, 0 if none
+}
+
+// AttendanceRate returns the share of Polls the person attended, from 0 to 1.
+func (p PersonStatsDTO) AttendanceRate() float64 {
+	if p.Polls == 0 {
+		return 0
+	}
+	return float64(p.Attended) / float64(p.Polls)
+}
@@ -0,0 +1,53 @@
+package stats
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repository struct {
+	DB *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{DB: db}
+}
+
+// GetPersonStats computes attendance for everyone who voted in or was queued for
+// a processed poll of the chat, most attended first.
+func (s *Repository) GetPersonStats(ctx context.Context, chatID int64) ([]PersonStatsDTO, error) {
+	rows, err := s.DB.Query(ctx, `WITH finished AS (
+		SELECT p.poll_id, r.queue_user_ids
+		FROM polls p JOIN poll_results r ON r.poll_id = p.poll_id
+		WHERE p.chat_id = $1 AND p.status = 'processed'
+	), participants AS (
+		SELECT f.poll_id, v.user_id FROM finished f JOIN poll_votes v ON v.poll_id = f.poll_id
+		UNION
+		SELECT f.poll_id, q.user_id FROM finished f, unnest(f.queue_user_ids) AS q(user_id)
+	)
+	SELECT pt.user_id,
+		COALESCE((array_agg(v.username ORDER BY v.updated_at DESC) FILTER (WHERE v.username IS NOT NULL))[1], ''),
+		COALESCE((array_agg(v.name ORDER BY v.updated_at DESC) FILTER (WHERE v.name IS NOT NULL))[1], ''),
+		count(*),
+		count(array_position(f.queue_user_ids, pt.user_id)),
+		COALESCE(avg(array_position(f.queue_user_ids, pt.user_id)), 0)::float8
+	FROM participants pt
+	JOIN finished f ON f.poll_id = pt.poll_id
+	LEFT JOIN poll_votes v ON v.poll_id = pt.poll_id AND v.user_id = pt.user_id
+	GROUP BY pt.user_id
+	ORDER BY 5 DESC, 6, 1`, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := []PersonStatsDTO{}
+	for rows.Next() {
+		var p PersonStatsDTO
+		if err := rows.Scan(&p.UserID, &p.Username, &p.Name, &p.Polls, &p.Attended, &p.AveragePosition); err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,611 @@
+// Package telegramtest provides an in-process stand-in for the Telegram Bot API,
+// so that the bot can be tested end to end without a real token or group.
+//
+// Point a client at it with tgbotapi.NewBotAPIWithAPIEndpoint(Token, s.Endpoint()).
+// It implements the methods the bot uses: getMe, getUpdates, setWebhook, deleteWebhook,
+// getWebhookInfo, sendMessage, sendPoll, stopPoll, editMessageText, sendDocument,
+// getChatMember and answerCallbackQuery. Unknown methods answer 404 like the real API.
+package telegramtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Token is the bot token the server accepts.
+const Token = "123456:test-token"
+
+// Bot is the user the server reports from getMe.
+var Bot = tgbotapi.User{ID: 123456, IsBot: true, FirstName: "Lineup", UserName: "lineup_test_bot"}
+
+// Call is a recorded Bot API request.
+type Call struct {
+	Method string
+	Params url.Values
+	Files  map[string][]byte // uploaded files by form field
+}
+
+// Server is a fake Bot API server. It is safe for concurrent use.
+type Server struct {
+	srv *httptest.Server
+
+	mu           sync.Mutex
+	updateAdded  chan struct{} // closed and replaced whenever an update is queued
+	calls        []Call
+	updates      []update
+	nextUpdateID int
+	nextMsgID    map[int64]int
+	sent         []tgbotapi.Message // everything the bot sent, edits applied
+	threads      map[msgKey]int     // forum topics of sent messages
+	polls        map[string]*tgbotapi.Poll
+	votes        map[string]map[int64][]int // poll ID to each voter's options
+	failures     map[string][]apiResponse   // method to the errors its next calls return
+	webhookURL   string
+	secretToken  string
+	admins       map[int64]map[int64]bool
+	now          func() time.Time
+}
+
+// NewServer starts a fake Bot API server. Close it when done.
+func NewServer() *Server {
+	s := &Server{
+		updateAdded:  make(chan struct{}),
+		nextUpdateID: 1,
+		nextMsgID:    make(map[int64]int),
+		threads:      make(map[msgKey]int),
+		polls:        make(map[string]*tgbotapi.Poll),
+		votes:        make(map[string]map[int64][]int),
+		failures:     make(map[string][]apiResponse),
+		admins:       make(map[int64]map[int64]bool),
+		now:          time.Now,
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Endpoint returns the API endpoint format for tgbotapi.NewBotAPIWithAPIEndpoint.
+func (s *Server) Endpoint() string {
+	return s.srv.URL + "/bot%s/%s"
+}
+
+// NewBot returns a client of the server, authorized as Bot.
+func (s *Server) NewBot() (*tgbotapi.BotAPI, error) {
+	return tgbotapi.NewBotAPIWithAPIEndpoint(Token, s.Endpoint())
+}
+
+// update is a queued update. tgbotapi.Message has no field for the forum topic,
+// so it is added when the update is encoded.
+type update struct {
+	tgbotapi.Update
+	threadID int
+}
+
+func (u update) MarshalJSON() ([]byte, error) {
+	if u.threadID == 0 || u.Message == nil {
+		return json.Marshal(u.Update)
+	}
+	msg := map[string]any{}
+	data, err := json.Marshal(u.Message)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	msg["message_thread_id"] = u.threadID
+	msg["is_topic_message"] = true
+	return json.Marshal(map[string]any{"update_id": u.UpdateID, "message": msg})
+}
+
+type msgKey struct {
+	chatID    int64
+	messageID int
+}
+
+type apiResponse struct {
+	Ok          bool   `json:"ok"`
+	Result      any    `json:"result,omitempty"`
+	ErrorCode   int    `json:"error_code,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	Parameters *tgbotapi.ResponseParameters `json:"parameters,omitempty"`
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	token, method, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/bot"), "/")
+	if !ok || token != Token {
+		writeResponse(w, http.StatusUnauthorized, apiResponse{ErrorCode: http.StatusUnauthorized, Description: "Unauthorized"})
+		return
+	}
+
+	call, err := parseCall(r, method)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, apiResponse{ErrorCode: http.StatusBadRequest, Description: "Bad Request: " + err.Error()})
+		return
+	}
+
+	// getUpdates waits outside the lock for updates to arrive
+	if method == "getUpdates" {
+		s.record(call)
+		result := s.getUpdates(r, call.Params)
+		writeResponse(w, http.StatusOK, apiResponse{Ok: true, Result: result})
+		return
+	}
+
+	s.mu.Lock()
+	s.calls = append(s.calls, call)
+	if failures := s.failures[method]; len(failures) > 0 {
+		s.failures[method] = failures[1:]
+		s.mu.Unlock()
+		writeResponse(w, failures[0].ErrorCode, failures[0])
+		return
+	}
+	result, err := s.handle(call)
+	s.mu.Unlock()
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, apiResponse{ErrorCode: http.StatusBadRequest, Description: "Bad Request: " + err.Error()})
+		return
+	}
+	if result == nil {
+		writeResponse(w, http.StatusNotFound, apiResponse{ErrorCode: http.StatusNotFound, Description: "Not Found"})
+		return
+	}
+	writeResponse(w, http.StatusOK, apiResponse{Ok: true, Result: result})
+}
+
+func parseCall(r *http.Request, method string) (Call, error) {
+	call := Call{Method: method, Params: url.Values{}, Files: map[string][]byte{}}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return call, err
+		}
+		for name, headers := range r.MultipartForm.File {
+			f, err := headers[0].Open()
+			if err != nil {
+				return call, err
+			}
+			data, err := io.ReadAll(f)
+			f.Close()
+			if err != nil {
+				return call, err
+			}
+			call.Files[name] = data
+		}
+	} else if err := r.ParseForm(); err != nil {
+		return call, err
+	}
+	for k, v := range r.Form {
+		call.Params[k] = v
+	}
+	return call, nil
+}
+
+// handle runs a method with s.mu held. A nil result means the method is unknown.
+func (s *Server) handle(c Call) (any, error) {
+	p := c.Params
+	switch c.Method {
+	case "getMe":
+		return Bot, nil
+	case "setWebhook":
+		s.webhookURL = p.Get("url")
+		s.secretToken = p.Get("secret_token")
+		return true, nil
+	case "deleteWebhook":
+		s.webhookURL = ""
+		s.secretToken = ""
+		if p.Get("drop_pending_updates") == "true" {
+			s.updates = nil
+		}
+		return true, nil
+	case "getWebhookInfo":
+		return tgbotapi.WebhookInfo{URL: s.webhookURL, PendingUpdateCount: len(s.updates)}, nil
+	case "sendMessage":
+		chatID, err := int64Param(p, "chat_id")
+		if err != nil {
+			return nil, err
+		}
+		if p.Get("text") == "" {
+			return nil, fmt.Errorf("message text is empty")
+		}
+		msg := s.newMessage(chatID, p)
+		msg.Text = p.Get("text")
+		return s.store(msg), nil
+	case "sendPoll":
+		chatID, err := int64Param(p, "chat_id")
+		if err != nil {
+			return nil, err
+		}
+		var options []string
+		if err := json.Unmarshal([]byte(p.Get("options")), &options); err != nil || len(options) < 2 {
+			return nil, fmt.Errorf("poll must have at least 2 option")
+		}
+		msg := s.newMessage(chatID, p)
+		poll := &tgbotapi.Poll{
+			ID:                    strconv.FormatInt(chatID, 10) + "_" + strconv.Itoa(msg.MessageID),
+			Question:              p.Get("question"),
+			IsAnonymous:           p.Get("is_anonymous") != "false",
+			Type:                  "regular",
+			AllowsMultipleAnswers: p.Get("allows_multiple_answers") == "true",
+		}
+		for _, o := range options {
+			poll.Options = append(poll.Options, tgbotapi.PollOption{Text: o})
+		}
+		s.polls[poll.ID] = poll
+		msg.Poll = poll
+		return s.store(msg), nil
+	case "stopPoll":
+		msg, err := s.findMessage(p)
+		if err != nil {
+			return nil, fmt.Errorf("message to stop not found")
+		}
+		if msg.Poll == nil {
+			return nil, fmt.Errorf("message with poll to stop not found")
+		}
+		if msg.Poll.IsClosed {
+			return nil, fmt.Errorf("poll has already been closed")
+		}
+		msg.Poll.IsClosed = true
+		return *msg.Poll, nil
+	case "editMessageText":
+		msg, err := s.findMessage(p)
+		if err != nil {
+			return nil, err
+		}
+		if msg.Text == p.Get("text") {
+			return nil, fmt.Errorf("message is not modified")
+		}
+		msg.Text = p.Get("text")
+		msg.EditDate = int(s.now().Unix())
+		return *msg, nil
+	case "sendDocument":
+		chatID, err := int64Param(p, "chat_id")
+		if err != nil {
+			return nil, err
+		}
+		data, ok := c.Files["document"]
+		if !ok {
+			return nil, fmt.Errorf("there is no document in the request")
+		}
+		msg := s.newMessage(chatID, p)
+		msg.Caption = p.Get("caption")
+		msg.Document = &tgbotapi.Document{FileID: fmt.Sprintf("doc%d", msg.MessageID), FileSize: len(data)}
+		return s.store(msg), nil
+	case "getChatMember":
+		chatID, err := int64Param(p, "chat_id")
+		if err != nil {
+			return nil, err
+		}
+		userID, err := int64Param(p, "user_id")
+		if err != nil {
+			return nil, err
+		}
+		status := "member"
+		if s.admins[chatID][userID] {
+			status = "administrator"
+		}
+		return tgbotapi.ChatMember{User: &tgbotapi.User{ID: userID}, Status: status}, nil
+	case "answerCallbackQuery":
+		return true, nil
+	}
+	return nil, nil
+}
+
+func (s *Server) newMessage(chatID int64, p url.Values) tgbotapi.Message {
+	s.nextMsgID[chatID]++
+	msg := tgbotapi.Message{
+		MessageID: s.nextMsgID[chatID],
+		From:      &Bot,
+		Date:      int(s.now().Unix()),
+		Chat:      &tgbotapi.Chat{ID: chatID},
+	}
+	if id, err := strconv.Atoi(p.Get("reply_to_message_id")); err == nil && id != 0 {
+		msg.ReplyToMessage = &tgbotapi.Message{MessageID: id, Chat: msg.Chat}
+	}
+	if id, err := strconv.Atoi(p.Get("message_thread_id")); err == nil && id != 0 {
+		s.threads[msgKey{chatID, msg.MessageID}] = id
+	}
+	return msg
+}
+
+func (s *Server) store(msg tgbotapi.Message) tgbotapi.Message {
+	s.sent = append(s.sent, msg)
+	return msg
+}
+
+func (s *Server) findMessage(p url.Values) (*tgbotapi.Message, error) {
+	chatID, err := int64Param(p, "chat_id")
+	if err != nil {
+		return nil, err
+	}
+	messageID, err := strconv.Atoi(p.Get("message_id"))
+	if err != nil {
+		return nil, fmt.Errorf("message_id is invalid")
+	}
+	for i := range s.sent {
+		if s.sent[i].Chat.ID == chatID && s.sent[i].MessageID == messageID {
+			return &s.sent[i], nil
+		}
+	}
+	return nil, fmt.Errorf("message to edit not found")
+}
+
+// getUpdates answers with updates after offset, waiting up to the requested timeout for one to arrive.
+func (s *Server) getUpdates(r *http.Request, p url.Values) []update {
+	offset, _ := strconv.Atoi(p.Get("offset"))
+	timeout, _ := strconv.Atoi(p.Get("timeout"))
+	deadline := time.After(time.Duration(timeout) * time.Second)
+	for {
+		s.mu.Lock()
+		// Like Telegram, asking for a later offset confirms the earlier updates
+		pending := s.updates[:0]
+		for _, u := range s.updates {
+			if u.UpdateID >= offset {
+				pending = append(pending, u)
+			}
+		}
+		s.updates = pending
+		result := append([]update(nil), pending...)
+		added := s.updateAdded
+		s.mu.Unlock()
+
+		if len(result) > 0 || timeout == 0 {
+			return result
+		}
+		select {
+		case <-added:
+		case <-deadline:
+			return []update{}
+		case <-r.Context().Done():
+			return []update{}
+		}
+	}
+}
+
+func (s *Server) record(c Call) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, c)
+}
+
+func int64Param(p url.Values, name string) (int64, error) {
+	v, err := strconv.ParseInt(p.Get(name), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s is invalid", name)
+	}
+	return v, nil
+}
+
+func writeResponse(w http.ResponseWriter, status int, resp apiResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// PushUpdate queues an update. With a webhook set it is POSTed to the webhook instead,
+// with the secret token it was set with, and the webhook's response status is returned.
+func (s *Server) PushUpdate(u tgbotapi.Update) (int, error) {
+	return s.pushUpdate(update{Update: u})
+}
+
+func (s *Server) pushUpdate(u update) (int, error) {
+	s.mu.Lock()
+	u.UpdateID = s.nextUpdateID
+	s.nextUpdateID++
+	webhookURL, secretToken := s.webhookURL, s.secretToken
+	if webhookURL == "" {
+		s.updates = append(s.updates, u)
+		close(s.updateAdded)
+		s.updateAdded = make(chan struct{})
+		s.mu.Unlock()
+		return 0, nil
+	}
+	s.mu.Unlock()
+
+	body, err := json.Marshal(u)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secretToken != "" {
+		req.Header.Set("X-Telegram-Bot-Api-Secret-Token", secretToken)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// SendText injects a text message from user to chat. Text starting with "/" is marked as a bot command.
+func (s *Server) SendText(chat tgbotapi.Chat, from tgbotapi.User, text string) (int, error) {
+	return s.SendTopicText(chat, 0, from, text)
+}
+
+// SendTopicText injects a text message from user to the forum topic threadID of chat,
+// or to the chat itself if threadID is 0.
+func (s *Server) SendTopicText(chat tgbotapi.Chat, threadID int, from tgbotapi.User, text string) (int, error) {
+	s.mu.Lock()
+	s.nextMsgID[chat.ID]++
+	msg := &tgbotapi.Message{
+		MessageID: s.nextMsgID[chat.ID],
+		From:      &from,
+		Date:      int(s.now().Unix()),
+		Chat:      &chat,
+		Text:      text,
+	}
+	s.mu.Unlock()
+	if strings.HasPrefix(text, "/") {
+		command, _, _ := strings.Cut(text, " ")
+		msg.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len([]rune(command))}}
+	}
+	return s.pushUpdate(update{Update: tgbotapi.Update{Message: msg}, threadID: threadID})
+}
+
+// AnswerPoll injects a poll answer of user and counts it in the poll. No optionIDs retract the vote.
+func (s *Server) AnswerPoll(pollID string, user tgbotapi.User, optionIDs ...int) (int, error) {
+	s.mu.Lock()
+	poll, ok := s.polls[pollID]
+	if ok {
+		if s.votes[pollID] == nil {
+			s.votes[pollID] = make(map[int64][]int)
+		}
+		if len(optionIDs) == 0 {
+			delete(s.votes[pollID], user.ID)
+		} else {
+			s.votes[pollID][user.ID] = optionIDs
+		}
+		for i := range poll.Options {
+			poll.Options[i].VoterCount = 0
+		}
+		for _, options := range s.votes[pollID] {
+			for _, id := range options {
+				if id >= 0 && id < len(poll.Options) {
+					poll.Options[id].VoterCount++
+				}
+			}
+		}
+		poll.TotalVoterCount = len(s.votes[pollID])
+	}
+	s.mu.Unlock()
+	if !ok {
+		return 0, fmt.Errorf("poll %s was not sent", pollID)
+	}
+	return s.PushUpdate(tgbotapi.Update{PollAnswer: &tgbotapi.PollAnswer{PollID: pollID, User: user, OptionIDs: optionIDs}})
+}
+
+// PushPoll injects a poll update with the current state of the poll, as Telegram
+// sends them to the bot that sent the poll.
+func (s *Server) PushPoll(pollID string) (int, error) {
+	s.mu.Lock()
+	poll, ok := s.polls[pollID]
+	var state tgbotapi.Poll
+	if ok {
+		state = *poll
+		state.Options = append([]tgbotapi.PollOption(nil), poll.Options...)
+	}
+	s.mu.Unlock()
+	if !ok {
+		return 0, fmt.Errorf("poll %s was not sent", pollID)
+	}
+	return s.PushUpdate(tgbotapi.Update{Poll: &state})
+}
+
+// DeleteMessage deletes a message the bot sent, as a chat administrator would.
+func (s *Server) DeleteMessage(chatID int64, messageID int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, m := range s.sent {
+		if m.Chat.ID == chatID && m.MessageID == messageID {
+			s.sent = append(s.sent[:i], s.sent[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// SetAdmin makes getChatMember report user as an administrator of chat.
+func (s *Server) SetAdmin(chatID, userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.admins[chatID] == nil {
+		s.admins[chatID] = make(map[int64]bool)
+	}
+	s.admins[chatID][userID] = true
+}
+
+// FailNext makes the next call of method fail with the HTTP status and error code code
+// and the given description, without doing anything. Calls fail in the order FailNext
+// was called for them.
+func (s *Server) FailNext(method string, code int, description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method] = append(s.failures[method], apiResponse{ErrorCode: code, Description: description})
+}
+
+// RateLimitNext makes the next call of method fail with 429 Too Many Requests,
+// asking to retry after retryAfter seconds.
+func (s *Server) RateLimitNext(method string, retryAfter int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method] = append(s.failures[method], apiResponse{
+		ErrorCode:   http.StatusTooManyRequests,
+		Description: fmt.Sprintf("Too Many Requests: retry after %d", retryAfter),
+		Parameters:  &tgbotapi.ResponseParameters{RetryAfter: retryAfter},
+	})
+}
+
+// Calls returns the recorded requests of method, or all of them if method is empty.
+func (s *Server) Calls(method string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []Call
+	for _, c := range s.calls {
+		if method == "" || c.Method == method {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// Messages returns the messages the bot sent to chat, with edits applied.
+func (s *Server) Messages(chatID int64) []tgbotapi.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []tgbotapi.Message
+	for _, m := range s.sent {
+		if m.Chat.ID == chatID {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+// Thread returns the forum topic the bot sent a message to, 0 if none.
+func (s *Server) Thread(chatID int64, messageID int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.threads[msgKey{chatID, messageID}]
+}
+
+// Poll returns the poll with the given ID as the bot sent it.
+func (s *Server) Poll(pollID string) (tgbotapi.Poll, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.polls[pollID]
+	if !ok {
+		return tgbotapi.Poll{}, false
+	}
+	res := *p
+	res.Options = append([]tgbotapi.PollOption(nil), p.Options...)
+	return res, true
+}
+
+// WebhookURL returns the URL set with setWebhook, if any.
+func (s *Server) WebhookURL() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.webhookURL
+}
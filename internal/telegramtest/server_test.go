@@ -0,0 +1,302 @@
+package telegramtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/nikitkaralius/lineup/internal/messenger"
+)
+
+var (
+	chat  = tgbotapi.Chat{ID: -100, Type: "supergroup", Title: "Lab"}
+	alice = tgbotapi.User{ID: 1, FirstName: "Alice", UserName: "alice"}
+)
+
+func newBot(t *testing.T) (*Server, *tgbotapi.BotAPI) {
+	t.Helper()
+	s := NewServer()
+	t.Cleanup(s.Close)
+	bot, err := s.NewBot()
+	if err != nil {
+		t.Fatalf("NewBot: %v", err)
+	}
+	return s, bot
+}
+
+func TestGetMe(t *testing.T) {
+	_, bot := newBot(t)
+	if bot.Self.UserName != Bot.UserName {
+		t.Errorf("Self.UserName = %q, want %q", bot.Self.UserName, Bot.UserName)
+	}
+}
+
+func TestPollLifecycle(t *testing.T) {
+	s, bot := newBot(t)
+	ctx := context.Background()
+	tg := messenger.NewTelegram(bot)
+
+	sent, err := tg.SendPoll(ctx, messenger.Poll{ChatID: chat.ID, Question: "Lab 1", Options: []string{"Coming", "Not coming"}})
+	if err != nil {
+		t.Fatalf("SendPoll: %v", err)
+	}
+	if _, err := s.AnswerPoll(sent.PollID, alice, 0); err != nil {
+		t.Fatalf("AnswerPoll: %v", err)
+	}
+
+	updates, err := bot.GetUpdates(tgbotapi.UpdateConfig{Offset: 0, Timeout: 1})
+	if err != nil {
+		t.Fatalf("GetUpdates: %v", err)
+	}
+	if len(updates) != 1 || updates[0].PollAnswer == nil {
+		t.Fatalf("updates = %+v, want one poll answer", updates)
+	}
+	pa := updates[0].PollAnswer
+	if pa.PollID != sent.PollID || pa.User.ID != alice.ID || len(pa.OptionIDs) != 1 || pa.OptionIDs[0] != 0 {
+		t.Errorf("poll answer = %+v", pa)
+	}
+
+	// A later offset confirms the update
+	updates, err = bot.GetUpdates(tgbotapi.UpdateConfig{Offset: updates[0].UpdateID + 1})
+	if err != nil {
+		t.Fatalf("GetUpdates: %v", err)
+	}
+	if len(updates) != 0 {
+		t.Errorf("updates after confirming = %d, want 0", len(updates))
+	}
+
+	if err := tg.StopPoll(ctx, chat.ID, sent.MessageID); err != nil {
+		t.Fatalf("StopPoll: %v", err)
+	}
+	if err := tg.StopPoll(ctx, chat.ID, sent.MessageID); !errors.Is(err, messenger.ErrPollClosed) {
+		t.Errorf("second StopPoll error = %v, want ErrPollClosed", err)
+	}
+	if p, _ := s.Poll(sent.PollID); !p.IsClosed {
+		t.Error("poll is not closed")
+	}
+}
+
+func TestEditMessage(t *testing.T) {
+	s, bot := newBot(t)
+	ctx := context.Background()
+	tg := messenger.NewTelegram(bot)
+
+	sent, err := tg.SendMessage(ctx, messenger.Message{ChatID: chat.ID, Text: "1. @alice"})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if err := tg.EditMessage(ctx, messenger.Edit{ChatID: chat.ID, MessageID: sent.MessageID, Text: "1. @bob"}); err != nil {
+		t.Fatalf("EditMessage: %v", err)
+	}
+	if err := tg.EditMessage(ctx, messenger.Edit{ChatID: chat.ID, MessageID: sent.MessageID, Text: "1. @bob"}); err == nil {
+		t.Error("unchanged EditMessage succeeded, want \"message is not modified\"")
+	}
+
+	msgs := s.Messages(chat.ID)
+	if len(msgs) != 1 || msgs[0].Text != "1. @bob" {
+		t.Errorf("messages = %+v, want the edited one", msgs)
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	s, bot := newBot(t)
+
+	received := make(chan tgbotapi.Update, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var u tgbotapi.Update
+		if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		received <- u
+	}))
+	defer hook.Close()
+
+	wh, err := tgbotapi.NewWebhook(hook.URL)
+	if err != nil {
+		t.Fatalf("NewWebhook: %v", err)
+	}
+	if _, err := bot.Request(wh); err != nil {
+		t.Fatalf("setWebhook: %v", err)
+	}
+
+	status, err := s.SendText(chat, alice, "/help")
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("SendText = %d, %v", status, err)
+	}
+	u := <-received
+	if u.Message == nil || !u.Message.IsCommand() || u.Message.Command() != "help" {
+		t.Errorf("webhook update = %+v, want /help command", u.Message)
+	}
+}
+
+func TestForumTopic(t *testing.T) {
+	s, bot := newBot(t)
+	ctx := context.Background()
+	topic := messenger.InThread(messenger.NewTelegram(bot), chat.ID, 7)
+
+	sent, err := topic.SendMessage(ctx, messenger.Message{ChatID: chat.ID, Text: "in the topic"})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if got := s.Thread(chat.ID, sent.MessageID); got != 7 {
+		t.Errorf("message thread = %d, want 7", got)
+	}
+	poll, err := topic.SendPoll(ctx, messenger.Poll{ChatID: chat.ID, Question: "Lab 1", Options: []string{"Coming", "Not coming"}})
+	if err != nil {
+		t.Fatalf("SendPoll: %v", err)
+	}
+	if got := s.Thread(chat.ID, poll.MessageID); got != 7 {
+		t.Errorf("poll thread = %d, want 7", got)
+	}
+
+	// Messages to other chats, such as direct messages, are left alone
+	dm, err := topic.SendMessage(ctx, messenger.Message{ChatID: alice.ID, Text: "direct"})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if got := s.Thread(alice.ID, dm.MessageID); got != 0 {
+		t.Errorf("direct message thread = %d, want 0", got)
+	}
+}
+
+func TestPollState(t *testing.T) {
+	s, bot := newBot(t)
+	ctx := context.Background()
+	tg := messenger.NewTelegram(bot)
+
+	sent, err := tg.SendPoll(ctx, messenger.Poll{ChatID: chat.ID, Question: "Lab 1", Options: []string{"Coming", "Not coming"}})
+	if err != nil {
+		t.Fatalf("SendPoll: %v", err)
+	}
+	bob := tgbotapi.User{ID: 2, FirstName: "Bob"}
+	s.AnswerPoll(sent.PollID, alice, 0)
+	s.AnswerPoll(sent.PollID, bob, 0)
+	s.AnswerPoll(sent.PollID, bob) // retracted
+	if _, err := s.PushPoll(sent.PollID); err != nil {
+		t.Fatalf("PushPoll: %v", err)
+	}
+
+	updates, err := bot.GetUpdates(tgbotapi.UpdateConfig{Timeout: 1})
+	if err != nil {
+		t.Fatalf("GetUpdates: %v", err)
+	}
+	if len(updates) != 4 || updates[3].Poll == nil {
+		t.Fatalf("updates = %+v, want three answers and a poll", updates)
+	}
+	p := updates[3].Poll
+	if p.ID != sent.PollID || p.TotalVoterCount != 1 || p.Options[0].VoterCount != 1 || p.Options[1].VoterCount != 0 {
+		t.Errorf("poll state = %+v, want Alice's vote only", p)
+	}
+
+	// Stopping a deleted poll tells why it failed
+	if !s.DeleteMessage(chat.ID, sent.MessageID) {
+		t.Fatal("DeleteMessage found no message")
+	}
+	if err := tg.StopPoll(ctx, chat.ID, sent.MessageID); !errors.Is(err, messenger.ErrMessageNotFound) {
+		t.Errorf("StopPoll of a deleted poll error = %v, want ErrMessageNotFound", err)
+	}
+}
+
+func TestFailNext(t *testing.T) {
+	s, bot := newBot(t)
+	ctx := context.Background()
+	tg := messenger.NewTelegram(bot)
+
+	s.FailNext("sendMessage", http.StatusInternalServerError, "Internal Server Error")
+	if _, err := tg.SendMessage(ctx, messenger.Message{ChatID: chat.ID, Text: "lost"}); err == nil {
+		t.Fatal("SendMessage succeeded, want the injected error")
+	}
+	if _, err := tg.SendMessage(ctx, messenger.Message{ChatID: chat.ID, Text: "sent"}); err != nil {
+		t.Fatalf("second SendMessage: %v", err)
+	}
+	if msgs := s.Messages(chat.ID); len(msgs) != 1 || msgs[0].Text != "sent" {
+		t.Errorf("messages = %+v, want only the second one", msgs)
+	}
+}
+
+func TestStopPollErrors(t *testing.T) {
+	s, bot := newBot(t)
+	ctx := context.Background()
+	tg := messenger.NewTelegram(bot)
+
+	sent, err := tg.SendMessage(ctx, messenger.Message{ChatID: chat.ID, Text: "no poll"})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	tests := []struct {
+		name        string
+		description string
+		want        error
+	}{
+		{"deleted", "Bad Request: message to stop not found", messenger.ErrMessageNotFound},
+		{"closed", "Bad Request: poll has already been closed", messenger.ErrPollClosed},
+		{"not a poll", "Bad Request: message with poll to stop not found", nil},
+		{"chat not found", "Bad Request: chat not found", nil},
+		{"rights", "Bad Request: not enough rights to stop the poll", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.FailNext("stopPoll", http.StatusBadRequest, tt.description)
+			err := tg.StopPoll(ctx, chat.ID, sent.MessageID)
+			if err == nil {
+				t.Fatal("StopPoll succeeded, want the injected error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("StopPoll error = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && (errors.Is(err, messenger.ErrMessageNotFound) || errors.Is(err, messenger.ErrPollClosed)) {
+				t.Errorf("StopPoll error = %v, want it surfaced as is", err)
+			}
+		})
+	}
+}
+
+func TestBadMarkup(t *testing.T) {
+	s, bot := newBot(t)
+	ctx := context.Background()
+	tg := messenger.NewTelegram(bot)
+
+	s.FailNext("sendMessage", http.StatusBadRequest, `Bad Request: can't parse entities: Unsupported start tag "div" at byte offset 0`)
+	if _, err := tg.SendMessage(ctx, messenger.Message{ChatID: chat.ID, Text: "<div>x</div>", ParseMode: messenger.ModeHTML}); !errors.Is(err, messenger.ErrBadMarkup) {
+		t.Errorf("SendMessage error = %v, want ErrBadMarkup", err)
+	}
+	sent, err := tg.SendMessage(ctx, messenger.Message{ChatID: chat.ID, Text: "x"})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	s.FailNext("editMessageText", http.StatusBadRequest, `Bad Request: can't parse entities: Unsupported start tag "p" at byte offset 0`)
+	if err := tg.EditMessage(ctx, messenger.Edit{ChatID: chat.ID, MessageID: sent.MessageID, Text: "<p>y</p>", ParseMode: messenger.ModeHTML}); !errors.Is(err, messenger.ErrBadMarkup) {
+		t.Errorf("EditMessage error = %v, want ErrBadMarkup", err)
+	}
+	s.FailNext("sendMessage", http.StatusBadRequest, "Bad Request: chat not found")
+	if _, err := tg.SendMessage(ctx, messenger.Message{ChatID: chat.ID, Text: "x"}); err == nil || errors.Is(err, messenger.ErrBadMarkup) {
+		t.Errorf("SendMessage error = %v, want it surfaced as is", err)
+	}
+}
+
+func TestRateLimitNext(t *testing.T) {
+	s, bot := newBot(t)
+	ctx := context.Background()
+	tg := messenger.NewOutbox(messenger.NewTelegram(bot), messenger.Limits{PerSecond: 100}, messenger.NewMemoryTurns())
+
+	s.RateLimitNext("sendMessage", 1)
+	start := time.Now()
+	if _, err := tg.SendMessage(ctx, messenger.Message{ChatID: chat.ID, Text: "late"}); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("sent again after %v, want the second retry_after asked for", elapsed)
+	}
+	if calls := s.Calls("sendMessage"); len(calls) != 2 {
+		t.Errorf("sendMessage calls = %d, want 2", len(calls))
+	}
+	if msgs := s.Messages(chat.ID); len(msgs) != 1 || msgs[0].Text != "late" {
+		t.Errorf("messages = %+v, want the message once", msgs)
+	}
+}
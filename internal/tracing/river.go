@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/rivertype"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// metadataKey holds the trace context in River job metadata.
+const metadataKey = "otel"
+
+// RiverMiddleware stores the inserting span's trace context in job metadata and
+// wraps every job attempt in a span linked to it. Jobs often run long after they
+// were inserted, so the job span starts a new trace instead of joining the old one.
+type RiverMiddleware struct {
+	river.MiddlewareDefaults
+}
+
+var (
+	_ rivertype.JobInsertMiddleware = (*RiverMiddleware)(nil)
+	_ rivertype.WorkerMiddleware    = (*RiverMiddleware)(nil)
+)
+
+func (m *RiverMiddleware) InsertMany(ctx context.Context, manyParams []*rivertype.JobInsertParams, doInner func(context.Context) ([]*rivertype.JobInsertResult, error)) ([]*rivertype.JobInsertResult, error) {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return doInner(ctx)
+	}
+
+	for _, params := range manyParams {
+		metadata := map[string]any{}
+		if len(params.Metadata) > 0 {
+			if err := json.Unmarshal(params.Metadata, &metadata); err != nil {
+				return nil, err
+			}
+		}
+		metadata[metadataKey] = carrier
+		encoded, err := json.Marshal(metadata)
+		if err != nil {
+			return nil, err
+		}
+		params.Metadata = encoded
+	}
+	return doInner(ctx)
+}
+
+func (m *RiverMiddleware) Work(ctx context.Context, job *rivertype.JobRow, doInner func(context.Context) error) error {
+	opts := []trace.SpanStartOption{
+		trace.WithNewRoot(),
+		trace.WithAttributes(
+			attribute.String("river.job.kind", job.Kind),
+			attribute.Int64("river.job.id", job.ID),
+			attribute.Int("river.job.attempt", job.Attempt),
+		),
+	}
+
+	var metadata struct {
+		Carrier propagation.MapCarrier `json:"otel"`
+	}
+	if err := json.Unmarshal(job.Metadata, &metadata); err == nil && len(metadata.Carrier) > 0 {
+		inserted := trace.SpanContextFromContext(otel.GetTextMapPropagator().Extract(context.Background(), metadata.Carrier))
+		if inserted.IsValid() {
+			opts = append(opts, trace.WithLinks(trace.Link{SpanContext: inserted}))
+		}
+	}
+
+	ctx, span := otel.Tracer(instrumentation).Start(ctx, "river.work "+job.Kind, opts...)
+	err := doInner(ctx)
+	End(span, err)
+	return err
+}
@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/riverqueue/river/rivertype"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRiverMiddlewareLinksJobToInsert(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	m := &RiverMiddleware{}
+
+	ctx, insertSpan := Start(context.Background(), "SchedulePollFinish")
+	params := &rivertype.JobInsertParams{Kind: "finish_poll", Metadata: []byte(`{"source":"test"}`)}
+	_, err := m.InsertMany(ctx, []*rivertype.JobInsertParams{params}, func(context.Context) ([]*rivertype.JobInsertResult, error) {
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("InsertMany: %v", err)
+	}
+	insertSpan.End()
+
+	job := &rivertype.JobRow{ID: 1, Kind: params.Kind, Metadata: params.Metadata}
+	if err := m.Work(context.Background(), job, func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("Work: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("recorded %d spans, want 2", len(spans))
+	}
+	work := spans[1]
+	if work.Name != "river.work finish_poll" {
+		t.Errorf("job span name = %q", work.Name)
+	}
+	if len(work.Links) != 1 || work.Links[0].SpanContext.SpanID() != insertSpan.SpanContext().SpanID() {
+		t.Errorf("job span links = %+v, want the insert span", work.Links)
+	}
+	if work.SpanContext.TraceID() == insertSpan.SpanContext().TraceID() {
+		t.Error("job span joined the insert trace, want a new one")
+	}
+	if string(params.Metadata) == `{"source":"test"}` {
+		t.Error("trace context was not added to the metadata")
+	}
+}
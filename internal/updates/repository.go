@@ -0,0 +1,55 @@
+// Package updates remembers which Telegram updates were already handled, so that
+// updates redelivered by Telegram or replayed after a restart are handled once.
+package updates
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Retention is how long handled update IDs are kept. Telegram keeps undelivered updates
+// for 24 hours, so older ones are never redelivered.
+const Retention = 48 * time.Hour
+
+type Repository struct {
+	DB *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{DB: db}
+}
+
+// MarkProcessed claims the update for handling. It returns false if it already was claimed,
+// so that of two workers given the same update only one handles it.
+func (s *Repository) MarkProcessed(ctx context.Context, updateID int) (bool, error) {
+	tag, err := s.DB.Exec(ctx, `INSERT INTO processed_updates (update_id, processed_at) VALUES ($1, NOW()) ON CONFLICT (update_id) DO NOTHING`, updateID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Release gives up the claim on an update that was not handled, so that it is handled
+// if it is delivered again.
+func (s *Repository) Release(ctx context.Context, updateID int) error {
+	_, err := s.DB.Exec(ctx, `DELETE FROM processed_updates WHERE update_id = $1`, updateID)
+	return err
+}
+
+// DeleteProcessedBefore forgets updates handled before t and returns how many there were.
+func (s *Repository) DeleteProcessedBefore(ctx context.Context, t time.Time) (int64, error) {
+	tag, err := s.DB.Exec(ctx, `DELETE FROM processed_updates WHERE processed_at < $1`, t)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// PruneArgs defines the periodic job forgetting handled updates and poll source messages
+// older than Retention, and idle send turns.
+type PruneArgs struct{}
+
+// Kind implements river.JobArgs to identify this job type.
+func (PruneArgs) Kind() string { return "prune_updates" }
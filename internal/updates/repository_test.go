@@ -0,0 +1,37 @@
+package updates
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nikitkaralius/lineup/internal/pgtest"
+)
+
+func TestMarkProcessed(t *testing.T) {
+	repo := NewRepository(pgtest.New(t))
+	ctx := context.Background()
+
+	if first, err := repo.MarkProcessed(ctx, 100); err != nil || !first {
+		t.Fatalf("MarkProcessed = %v, %v, want true", first, err)
+	}
+	if first, err := repo.MarkProcessed(ctx, 100); err != nil || first {
+		t.Errorf("MarkProcessed again = %v, %v, want false", first, err)
+	}
+	if err := repo.Release(ctx, 100); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if first, err := repo.MarkProcessed(ctx, 100); err != nil || !first {
+		t.Errorf("MarkProcessed after Release = %v, %v, want true", first, err)
+	}
+
+	if n, err := repo.DeleteProcessedBefore(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("DeleteProcessedBefore(an hour ago) = %d, %v, want 0", n, err)
+	}
+	if n, err := repo.DeleteProcessedBefore(ctx, time.Now().Add(time.Minute)); err != nil || n != 1 {
+		t.Errorf("DeleteProcessedBefore(now) = %d, %v, want 1", n, err)
+	}
+	if first, _ := repo.MarkProcessed(ctx, 100); !first {
+		t.Error("MarkProcessed after pruning = false, want true")
+	}
+}
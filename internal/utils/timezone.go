@@ -31,38 +31,59 @@ func ParseDurationInMoscow(durationStr string) (time.Duration, time.Time, error)
 	return dur, endsAtUTC, nil
 }
 
-// ParseEndTimeInMoscow parses an ISO 8601 datetime string in Moscow timezone and converts it to UTC.
-// The LLM should provide end_time in ISO 8601 format: "2006-01-02T15:04:05+03:00"
-func ParseEndTimeInMoscow(endTimeStr string) (time.Time, error) {
-	endTimeStr = strings.TrimSpace(endTimeStr)
+// ParseTimeIn parses an ISO 8601 datetime string and converts it to UTC.
+// A datetime without an offset is a wall clock time in loc.
+func ParseTimeIn(timeStr string, loc *time.Location) (time.Time, error) {
+	timeStr = strings.TrimSpace(timeStr)
 
 	// Parse ISO 8601 format with timezone
-	if t, err := time.Parse(time.RFC3339, endTimeStr); err == nil {
-		// Convert to Moscow timezone if not already
-		moscowTime := t.In(moscowLocation)
-		return moscowTime.UTC(), nil
+	if t, err := time.Parse(time.RFC3339, timeStr); err == nil {
+		return t.UTC(), nil
 	}
 
-	// Try ISO 8601 without timezone (assume Moscow)
-	if t, err := time.Parse("2006-01-02T15:04:05", endTimeStr); err == nil {
-		moscowTime := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, moscowLocation)
-		return moscowTime.UTC(), nil
+	// Try ISO 8601 without timezone (assume loc)
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05", timeStr, loc); err == nil {
+		return t.UTC(), nil
 	}
 
-	return time.Time{}, fmt.Errorf("неверный формат времени. Ожидается ISO 8601 формат (например, 2024-01-15T13:48:00+03:00), получено: %s", endTimeStr)
+	return time.Time{}, fmt.Errorf("invalid time format, expected ISO 8601 (e.g. 2024-01-15T13:48:00%s), got: %s", time.Now().In(loc).Format("-07:00"), timeStr)
 }
 
 // FormatMoscowTimeForPoll formats a UTC time as Moscow timezone string for poll topic display.
 // Format: "HH:MM DD.MM.YYYY MSK"
 func FormatMoscowTimeForPoll(utcTime time.Time) string {
-	moscowTime := utcTime.In(moscowLocation)
-	return moscowTime.Format("15:04 02.01.2006 MSK")
+	return FormatTimeForPoll(utcTime, moscowLocation)
 }
 
 // FormatMoscowTimeShort formats a UTC time as short Moscow timezone string (HH:MM).
 func FormatMoscowTimeShort(utcTime time.Time) string {
-	moscowTime := utcTime.In(moscowLocation)
-	return moscowTime.Format("15:04")
+	return FormatTimeShort(utcTime, moscowLocation)
+}
+
+// DefaultTimezone is the timezone of chats that never chose one.
+const DefaultTimezone = "Europe/Moscow"
+
+// LoadLocation returns the named IANA timezone, or Moscow if the name is empty or unknown.
+func LoadLocation(name string) *time.Location {
+	if name == "" {
+		return moscowLocation
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return moscowLocation
+	}
+	return loc
+}
+
+// FormatTimeForPoll formats a UTC time in the given timezone for poll topic display.
+// Format: "HH:MM DD.MM.YYYY TZ", e.g. "13:48 15.01.2024 MSK"
+func FormatTimeForPoll(utcTime time.Time, loc *time.Location) string {
+	return utcTime.In(loc).Format("15:04 02.01.2006 MST")
+}
+
+// FormatTimeShort formats a UTC time in the given timezone as HH:MM.
+func FormatTimeShort(utcTime time.Time, loc *time.Location) string {
+	return utcTime.In(loc).Format("15:04")
 }
 
 // MoscowToUTC converts a Moscow time to UTC.
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeIn(t *testing.T) {
+	london := LoadLocation("Europe/London")
+	for _, tt := range []struct {
+		in   string
+		loc  *time.Location
+		want time.Time
+	}{
+		// A London chat's "starts 14:00" in summer is 13:00 UTC, not 14:00 MSK
+		{"2025-07-01T14:00:00+01:00", london, time.Date(2025, 7, 1, 13, 0, 0, 0, time.UTC)},
+		{"2025-07-01T14:00:00", london, time.Date(2025, 7, 1, 13, 0, 0, 0, time.UTC)},
+		{"2025-01-15T14:00:00", london, time.Date(2025, 1, 15, 14, 0, 0, 0, time.UTC)},
+		{"2025-07-01T14:00:00", LoadLocation(DefaultTimezone), time.Date(2025, 7, 1, 11, 0, 0, 0, time.UTC)},
+	} {
+		got, err := ParseTimeIn(tt.in, tt.loc)
+		if err != nil {
+			t.Errorf("ParseTimeIn(%q, %s): %v", tt.in, tt.loc, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseTimeIn(%q, %s) = %v, want %v", tt.in, tt.loc, got, tt.want)
+		}
+		if shown := FormatTimeShort(got, tt.loc); shown != "14:00" {
+			t.Errorf("ParseTimeIn(%q, %s) is shown as %s, want 14:00", tt.in, tt.loc, shown)
+		}
+	}
+
+	if _, err := ParseTimeIn("14:00", london); err == nil {
+		t.Error("ParseTimeIn accepted a time without a date")
+	}
+}
@@ -1,7 +1,15 @@
 package voters
 
+import "time"
+
 type TelegramVoterDTO struct {
 	UserID   int64
 	Username string
 	Name     string
 }
+
+type VoteDTO struct {
+	TelegramVoterDTO
+	OptionIDs []int
+	UpdatedAt time.Time
+}
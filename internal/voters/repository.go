@@ -4,25 +4,55 @@ import (
 	"context"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// This AI crap will be refactored
+// Repository stores votes and the queues built from them.
+type Repository interface {
+	// UpsertVote saves the user's answer in the poll, replacing an earlier one.
+	UpsertVote(ctx context.Context, pollID string, u tgbotapi.User, optionIDs []int) error
+	// DeleteVote removes the user's answer in the poll, if any.
+	DeleteVote(ctx context.Context, pollID string, userID int64) error
+	// GetComingVoters returns the users who chose the coming answer.
+	GetComingVoters(ctx context.Context, pollID string, comingAnswerIndex int) ([]TelegramVoterDTO, error)
+	// InsertPollResult stores the queue of a finished poll; an existing queue is left as is.
+	InsertPollResult(ctx context.Context, pollID string, queueUserIDs []int64) error
+	// GetQueueUserIDs returns the queue of a finished poll, or pgx.ErrNoRows if it has none.
+	GetQueueUserIDs(ctx context.Context, pollID string) ([]int64, error)
+	// ChangeQueue replaces the queue of a finished poll with what change makes of it and returns
+	// the new queue. The queue is locked meanwhile, so that concurrent changes apply one after
+	// the other. If change fails, its error is returned and the queue is left as is.
+	// Returns pgx.ErrNoRows if the poll has no queue.
+	ChangeQueue(ctx context.Context, pollID string, change func(queueUserIDs []int64) ([]int64, error)) ([]int64, error)
+	// GetVotersInfo returns the voters among userIDs by user ID. Users who never voted are absent.
+	GetVotersInfo(ctx context.Context, pollID string, userIDs []int64) (map[int64]TelegramVoterDTO, error)
+	// GetVotes returns all votes in a poll, oldest first.
+	GetVotes(ctx context.Context, pollID string) ([]VoteDTO, error)
+}
 
-type Repository struct {
-	DB *pgxpool.Pool
+// pgRepository implements Repository with Postgres.
+type pgRepository struct {
+	db *pgxpool.Pool
 }
 
-func NewRepository(db *pgxpool.Pool) *Repository {
-	return &Repository{DB: db}
+// NewRepository creates a Repository backed by db.
+func NewRepository(db *pgxpool.Pool) Repository {
+	return &pgRepository{db: db}
 }
 
-func (s *Repository) UpsertVote(ctx context.Context, pollID string, u tgbotapi.User, optionIDs []int) error {
+// DisplayName returns the user's first and last name as stored with votes.
+func DisplayName(u tgbotapi.User) string {
 	name := u.FirstName
 	if u.LastName != "" {
 		name = name + " " + u.LastName
 	}
-	_, err := s.DB.Exec(ctx, `INSERT INTO poll_votes (poll_id, user_id, username, name, option_ids, updated_at)
+	return name
+}
+
+func (s *pgRepository) UpsertVote(ctx context.Context, pollID string, u tgbotapi.User, optionIDs []int) error {
+	name := DisplayName(u)
+	_, err := s.db.Exec(ctx, `INSERT INTO poll_votes (poll_id, user_id, username, name, option_ids, updated_at)
 	VALUES ($1,$2,$3,$4,$5, NOW())
 	ON CONFLICT (poll_id, user_id) DO UPDATE SET username=EXCLUDED.username, name=EXCLUDED.name, option_ids=EXCLUDED.option_ids, updated_at=NOW()`,
 		pollID, u.ID, u.UserName, name, intSliceToArray(optionIDs),
@@ -30,8 +60,13 @@ func (s *Repository) UpsertVote(ctx context.Context, pollID string, u tgbotapi.U
 	return err
 }
 
-func (s *Repository) GetComingVoters(ctx context.Context, pollID string, comingAnswerIndex int) ([]TelegramVoterDTO, error) {
-	rows, err := s.DB.Query(ctx, `SELECT user_id, COALESCE(username,''), COALESCE(name,'') FROM poll_votes WHERE poll_id=$1 AND $2 = ANY(option_ids)`, pollID, comingAnswerIndex)
+func (s *pgRepository) DeleteVote(ctx context.Context, pollID string, userID int64) error {
+	_, err := s.db.Exec(ctx, `DELETE FROM poll_votes WHERE poll_id=$1 AND user_id=$2`, pollID, userID)
+	return err
+}
+
+func (s *pgRepository) GetComingVoters(ctx context.Context, pollID string, comingAnswerIndex int) ([]TelegramVoterDTO, error) {
+	rows, err := s.db.Query(ctx, `SELECT user_id, COALESCE(username,''), COALESCE(name,'') FROM poll_votes WHERE poll_id=$1 AND $2 = ANY(option_ids)`, pollID, comingAnswerIndex)
 	if err != nil {
 		return nil, err
 	}
@@ -47,15 +82,15 @@ func (s *Repository) GetComingVoters(ctx context.Context, pollID string, comingA
 	return vs, rows.Err()
 }
 
-func (s *Repository) InsertPollResult(ctx context.Context, pollID string, queueUserIDs []int64) error {
-	_, err := s.DB.Exec(ctx, `INSERT INTO poll_results (poll_id, queue_user_ids, created_at) VALUES ($1,$2,NOW()) ON CONFLICT (poll_id) DO NOTHING`, pollID, queueUserIDs)
+func (s *pgRepository) InsertPollResult(ctx context.Context, pollID string, queueUserIDs []int64) error {
+	_, err := s.db.Exec(ctx, `INSERT INTO poll_results (poll_id, queue_user_ids, created_at) VALUES ($1,$2,NOW()) ON CONFLICT (poll_id) DO NOTHING`, pollID, queueUserIDs)
 	return err
 }
 
 // GetQueueUserIDs retrieves the current queue user IDs for a poll.
-func (s *Repository) GetQueueUserIDs(ctx context.Context, pollID string) ([]int64, error) {
+func (s *pgRepository) GetQueueUserIDs(ctx context.Context, pollID string) ([]int64, error) {
 	var queueUserIDs []int64
-	err := s.DB.QueryRow(ctx, `SELECT queue_user_ids FROM poll_results WHERE poll_id=$1`, pollID).
+	err := s.db.QueryRow(ctx, `SELECT queue_user_ids FROM poll_results WHERE poll_id=$1`, pollID).
 		Scan(&queueUserIDs)
 	if err != nil {
 		return nil, err
@@ -63,19 +98,34 @@ func (s *Repository) GetQueueUserIDs(ctx context.Context, pollID string) ([]int6
 	return queueUserIDs, nil
 }
 
-// UpdateQueueUserIDs updates the queue user IDs for a poll.
-func (s *Repository) UpdateQueueUserIDs(ctx context.Context, pollID string, queueUserIDs []int64) error {
-	_, err := s.DB.Exec(ctx, `UPDATE poll_results SET queue_user_ids=$2 WHERE poll_id=$1`, pollID, queueUserIDs)
-	return err
+func (s *pgRepository) ChangeQueue(ctx context.Context, pollID string, change func(queueUserIDs []int64) ([]int64, error)) ([]int64, error) {
+	var queueUserIDs []int64
+	err := pgx.BeginFunc(ctx, s.db, func(tx pgx.Tx) error {
+		var current []int64
+		if err := tx.QueryRow(ctx, `SELECT queue_user_ids FROM poll_results WHERE poll_id=$1 FOR UPDATE`, pollID).Scan(&current); err != nil {
+			return err
+		}
+		var err error
+		if queueUserIDs, err = change(current); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `UPDATE poll_results SET queue_user_ids=$2 WHERE poll_id=$1`, pollID, queueUserIDs)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return queueUserIDs, nil
 }
 
 // GetVotersInfo retrieves user information for a list of user IDs for a specific poll.
-func (s *Repository) GetVotersInfo(ctx context.Context, pollID string, userIDs []int64) (map[int64]TelegramVoterDTO, error) {
+// Users who never voted in the poll are absent from the result.
+func (s *pgRepository) GetVotersInfo(ctx context.Context, pollID string, userIDs []int64) (map[int64]TelegramVoterDTO, error) {
 	if len(userIDs) == 0 {
 		return make(map[int64]TelegramVoterDTO), nil
 	}
 
-	rows, err := s.DB.Query(ctx, `SELECT user_id, COALESCE(username,''), COALESCE(name,'') FROM poll_votes WHERE poll_id=$1 AND user_id = ANY($2)`, pollID, userIDs)
+	rows, err := s.db.Query(ctx, `SELECT user_id, COALESCE(username,''), COALESCE(name,'') FROM poll_votes WHERE poll_id=$1 AND user_id = ANY($2)`, pollID, userIDs)
 	if err != nil {
 		return nil, err
 	}
@@ -90,18 +140,33 @@ func (s *Repository) GetVotersInfo(ctx context.Context, pollID string, userIDs [
 		result[v.UserID] = v
 	}
 
-	// Fill in missing users with placeholder
-	for _, userID := range userIDs {
-		if _, exists := result[userID]; !exists {
-			result[userID] = TelegramVoterDTO{
-				UserID:   userID,
-				Username: "",
-				Name:     "Unknown",
-			}
+	// Users missing from poll_votes are left out; formatters render a placeholder
+	return result, rows.Err()
+}
+
+// GetVotes returns all votes in a poll, oldest first.
+func (s *pgRepository) GetVotes(ctx context.Context, pollID string) ([]VoteDTO, error) {
+	rows, err := s.db.Query(ctx, `SELECT user_id, COALESCE(username,''), COALESCE(name,''), option_ids, updated_at FROM poll_votes WHERE poll_id=$1 ORDER BY updated_at`, pollID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	vs := []VoteDTO{}
+	for rows.Next() {
+		var (
+			v         VoteDTO
+			optionIDs []int32
+		)
+		if err := rows.Scan(&v.UserID, &v.Username, &v.Name, &optionIDs, &v.UpdatedAt); err != nil {
+			return nil, err
 		}
+		v.OptionIDs = make([]int, len(optionIDs))
+		for i, id := range optionIDs {
+			v.OptionIDs[i] = int(id)
+		}
+		vs = append(vs, v)
 	}
-
-	return result, rows.Err()
+	return vs, rows.Err()
 }
 
 func intSliceToArray(a []int) any {
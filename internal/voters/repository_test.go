@@ -0,0 +1,211 @@
+package voters
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/nikitkaralius/lineup/internal/pgtest"
+)
+
+var (
+	alice = tgbotapi.User{ID: 1, FirstName: "Alice", LastName: "Smith", UserName: "alice"}
+	bob   = tgbotapi.User{ID: 2, FirstName: "Bob"}
+	carol = tgbotapi.User{ID: 3, FirstName: "Carol", UserName: "carol"}
+)
+
+func vote(t *testing.T, repo Repository, pollID string, u tgbotapi.User, optionIDs ...int) {
+	t.Helper()
+	if err := repo.UpsertVote(context.Background(), pollID, u, optionIDs); err != nil {
+		t.Fatalf("UpsertVote(%d): %v", u.ID, err)
+	}
+}
+
+func TestUpsertVote(t *testing.T) {
+	repo := NewRepository(pgtest.New(t))
+	ctx := context.Background()
+
+	vote(t, repo, "p1", alice, 1)
+	vote(t, repo, "p1", bob, 0, 2)
+	// Alice changes her mind and her name: the vote is replaced, not added
+	renamed := alice
+	renamed.FirstName = "Alicia"
+	vote(t, repo, "p1", renamed, 0)
+	// Retracted votes are stored as an empty array
+	vote(t, repo, "p1", carol)
+	vote(t, repo, "p2", bob, 1)
+
+	votes, err := repo.GetVotes(ctx, "p1")
+	if err != nil {
+		t.Fatalf("GetVotes: %v", err)
+	}
+	if len(votes) != 3 {
+		t.Fatalf("GetVotes = %+v, want 3 votes", votes)
+	}
+	byUser := map[int64]VoteDTO{}
+	for _, v := range votes {
+		byUser[v.UserID] = v
+	}
+	if v := byUser[alice.ID]; v.Name != "Alicia Smith" || v.Username != "alice" || !slices.Equal(v.OptionIDs, []int{0}) {
+		t.Errorf("Alice's vote = %+v", v)
+	}
+	if v := byUser[bob.ID]; v.Username != "" || !slices.Equal(v.OptionIDs, []int{0, 2}) {
+		t.Errorf("Bob's vote = %+v", v)
+	}
+	if v := byUser[carol.ID]; v.OptionIDs == nil || len(v.OptionIDs) != 0 {
+		t.Errorf("Carol's vote = %+v, want no options", v)
+	}
+	// Oldest first: Bob's vote is older than Alice's changed one
+	if votes[0].UserID != bob.ID {
+		t.Errorf("first vote is %d's, want Bob's", votes[0].UserID)
+	}
+
+	none, err := repo.GetVotes(ctx, "missing")
+	if err != nil || none == nil || len(none) != 0 {
+		t.Errorf("GetVotes(missing) = %v, %v, want an empty slice", none, err)
+	}
+}
+
+func TestDeleteVote(t *testing.T) {
+	repo := NewRepository(pgtest.New(t))
+	ctx := context.Background()
+
+	vote(t, repo, "p1", alice, 0)
+	vote(t, repo, "p1", bob, 0)
+	vote(t, repo, "p2", alice, 0)
+	if err := repo.DeleteVote(ctx, "p1", alice.ID); err != nil {
+		t.Fatalf("DeleteVote: %v", err)
+	}
+	// Deleting a vote that is not there is not an error
+	if err := repo.DeleteVote(ctx, "p1", carol.ID); err != nil {
+		t.Fatalf("DeleteVote(no vote): %v", err)
+	}
+
+	votes, err := repo.GetVotes(ctx, "p1")
+	if err != nil {
+		t.Fatalf("GetVotes: %v", err)
+	}
+	if len(votes) != 1 || votes[0].UserID != bob.ID {
+		t.Errorf("votes in p1 = %+v, want only Bob's", votes)
+	}
+	if votes, _ := repo.GetVotes(ctx, "p2"); len(votes) != 1 {
+		t.Errorf("votes in p2 = %+v, want Alice's to stay", votes)
+	}
+}
+
+func TestGetComingVoters(t *testing.T) {
+	repo := NewRepository(pgtest.New(t))
+	ctx := context.Background()
+
+	vote(t, repo, "p1", alice, 0)
+	vote(t, repo, "p1", bob, 1, 2)
+	vote(t, repo, "p1", carol, 2)
+	vote(t, repo, "p2", alice, 2)
+
+	coming, err := repo.GetComingVoters(ctx, "p1", 2)
+	if err != nil {
+		t.Fatalf("GetComingVoters: %v", err)
+	}
+	var ids []int64
+	for _, v := range coming {
+		ids = append(ids, v.UserID)
+	}
+	slices.Sort(ids)
+	if !slices.Equal(ids, []int64{bob.ID, carol.ID}) {
+		t.Errorf("coming = %v, want Bob and Carol", ids)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	repo := NewRepository(pgtest.New(t))
+	ctx := context.Background()
+
+	if _, err := repo.GetQueueUserIDs(ctx, "p1"); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("GetQueueUserIDs before finishing error = %v, want pgx.ErrNoRows", err)
+	}
+
+	if err := repo.InsertPollResult(ctx, "p1", []int64{3, 1, 2}); err != nil {
+		t.Fatalf("InsertPollResult: %v", err)
+	}
+	// A second result for the same poll is ignored
+	if err := repo.InsertPollResult(ctx, "p1", []int64{1}); err != nil {
+		t.Fatalf("InsertPollResult again: %v", err)
+	}
+	queue, err := repo.GetQueueUserIDs(ctx, "p1")
+	if err != nil {
+		t.Fatalf("GetQueueUserIDs: %v", err)
+	}
+	if !slices.Equal(queue, []int64{3, 1, 2}) {
+		t.Errorf("queue = %v, want [3 1 2]", queue)
+	}
+
+	dropFirst := func(q []int64) ([]int64, error) { return q[1:], nil }
+	if queue, err := repo.ChangeQueue(ctx, "p1", dropFirst); err != nil || !slices.Equal(queue, []int64{1, 2}) {
+		t.Fatalf("ChangeQueue = %v, %v, want [1 2]", queue, err)
+	}
+	if queue, _ := repo.GetQueueUserIDs(ctx, "p1"); !slices.Equal(queue, []int64{1, 2}) {
+		t.Errorf("queue after update = %v, want [1 2]", queue)
+	}
+	// A refused change leaves the queue as is
+	refused := errors.New("refused")
+	if _, err := repo.ChangeQueue(ctx, "p1", func([]int64) ([]int64, error) { return []int64{}, refused }); !errors.Is(err, refused) {
+		t.Errorf("ChangeQueue error = %v, want the change's error", err)
+	}
+	if queue, _ := repo.GetQueueUserIDs(ctx, "p1"); !slices.Equal(queue, []int64{1, 2}) {
+		t.Errorf("queue after a refused change = %v, want [1 2]", queue)
+	}
+	if _, err := repo.ChangeQueue(ctx, "p3", dropFirst); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("ChangeQueue without a queue error = %v, want pgx.ErrNoRows", err)
+	}
+
+	// Concurrent changes apply one after the other
+	var wg sync.WaitGroup
+	for id := int64(10); id < 20; id++ {
+		wg.Go(func() {
+			if _, err := repo.ChangeQueue(ctx, "p1", func(q []int64) ([]int64, error) { return append(q, id), nil }); err != nil {
+				t.Errorf("ChangeQueue: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+	if queue, _ := repo.GetQueueUserIDs(ctx, "p1"); len(queue) != 12 {
+		t.Errorf("queue after concurrent joins = %v, want all 10 added", queue)
+	}
+
+	// An empty queue is an empty array, not NULL
+	if err := repo.InsertPollResult(ctx, "p2", []int64{}); err != nil {
+		t.Fatalf("InsertPollResult(empty): %v", err)
+	}
+	if queue, err := repo.GetQueueUserIDs(ctx, "p2"); err != nil || len(queue) != 0 {
+		t.Errorf("empty queue = %v, %v", queue, err)
+	}
+}
+
+func TestGetVotersInfo(t *testing.T) {
+	repo := NewRepository(pgtest.New(t))
+	ctx := context.Background()
+
+	vote(t, repo, "p1", alice, 0)
+	vote(t, repo, "p1", bob, 0)
+	vote(t, repo, "p2", carol, 0)
+
+	info, err := repo.GetVotersInfo(ctx, "p1", []int64{alice.ID, carol.ID})
+	if err != nil {
+		t.Fatalf("GetVotersInfo: %v", err)
+	}
+	if len(info) != 1 {
+		t.Fatalf("GetVotersInfo = %+v, want only Alice: Bob wasn't asked for and Carol voted elsewhere", info)
+	}
+	if v := info[alice.ID]; v.Username != "alice" || v.Name != "Alice Smith" {
+		t.Errorf("Alice = %+v", v)
+	}
+
+	empty, err := repo.GetVotersInfo(ctx, "p1", nil)
+	if err != nil || empty == nil || len(empty) != 0 {
+		t.Errorf("GetVotersInfo(nil) = %v, %v, want an empty map", empty, err)
+	}
+}
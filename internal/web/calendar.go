@@ -0,0 +1,37 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nikitkaralius/lineup/internal/auth"
+	"github.com/nikitkaralius/lineup/internal/export"
+)
+
+// calendarLookback keeps today's sessions in the feed after they started.
+const calendarLookback = 24 * time.Hour
+
+// calendar serves a user's iCal feed of upcoming sessions. The token in the path
+// authenticates the request, because calendar apps cannot log in.
+func (s *Server) calendar(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimSuffix(r.PathValue("token"), ".ics")
+	claims, err := s.signer.Verify(token, auth.PurposeCalendar)
+	if err != nil {
+		http.Error(w, "invalid or expired calendar link", http.StatusUnauthorized)
+		return
+	}
+
+	now := time.Now()
+	sessions, err := s.exportRepo.GetUserSessions(r.Context(), claims.UserID, now.Add(-calendarLookback))
+	if err != nil {
+		s.internalError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	if err := export.WriteICal(w, sessions, "Lineup", now); err != nil {
+		s.internalError(w, r, err)
+	}
+}
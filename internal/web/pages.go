@@ -0,0 +1,185 @@
+package web
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/nikitkaralius/lineup/internal/auth"
+	"github.com/nikitkaralius/lineup/internal/i18n"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/polls"
+	"github.com/nikitkaralius/lineup/internal/queue"
+	"github.com/nikitkaralius/lineup/internal/stats"
+	"github.com/nikitkaralius/lineup/internal/utils"
+)
+
+// pageSize is the number of polls per history page.
+const pageSize = 20
+
+type chatLink struct {
+	ID    int64
+	Title string
+}
+
+func (s *Server) index(w http.ResponseWriter, r *http.Request, session *auth.Claims) {
+	links := make([]chatLink, 0, len(session.Chats))
+	for id, title := range session.Chats {
+		links = append(links, chatLink{ID: id, Title: title})
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].Title < links[j].Title })
+	s.render(w, http.StatusOK, "index", i18n.Default, utils.LoadLocation(""), session, links)
+}
+
+type chatPage struct {
+	Chat   chatLink
+	Polls  []polls.TelegramPollDTO
+	People []stats.PersonStatsDTO
+	Page   int
+	Newer  int // previous page number, 0 if this is the first page
+	Older  int // next page number, 0 if this is the last page
+}
+
+func (s *Server) chat(w http.ResponseWriter, r *http.Request, session *auth.Claims) {
+	ctx := r.Context()
+	chatID, ok := s.authorizeChat(w, r, session)
+	if !ok {
+		return
+	}
+	settings := s.chatsRepo.GetSettingsOrDefault(ctx, chatID, 0)
+
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	// One extra poll tells whether there is an older page
+	ps, err := s.pollsRepo.ListPollsByChat(ctx, chatID, pageSize+1, (page-1)*pageSize)
+	if err != nil {
+		s.internalError(w, r, err)
+		return
+	}
+	people, err := s.statsRepo.GetPersonStats(ctx, chatID)
+	if err != nil {
+		s.internalError(w, r, err)
+		return
+	}
+
+	data := chatPage{Chat: chatLink{ID: chatID, Title: session.Chats[chatID]}, Polls: ps, People: people, Page: page, Newer: page - 1}
+	if len(ps) > pageSize {
+		data.Polls = ps[:pageSize]
+		data.Older = page + 1
+	}
+	s.render(w, http.StatusOK, "chat", settings.Language, utils.LoadLocation(settings.Timezone), session, data)
+}
+
+type voteRow struct {
+	Name    string
+	Answers string
+}
+
+type pollPage struct {
+	Chat  chatLink
+	Poll  *polls.TelegramPollDTO
+	Votes []voteRow
+	Queue queue.TemplateData
+}
+
+func (s *Server) poll(w http.ResponseWriter, r *http.Request, session *auth.Claims) {
+	ctx := r.Context()
+	chatID, ok := s.authorizeChat(w, r, session)
+	if !ok {
+		return
+	}
+	p, err := s.pollsRepo.GetPoll(ctx, r.PathValue("pollID"))
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && p.ChatID != chatID) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		s.internalError(w, r, err)
+		return
+	}
+	settings := s.chatsRepo.GetSettingsOrDefault(ctx, chatID, p.MessageThreadID)
+	loc := utils.LoadLocation(settings.Timezone)
+
+	vs, err := s.votersRepo.GetVotes(ctx, p.PollID)
+	if err != nil {
+		s.internalError(w, r, err)
+		return
+	}
+	votes := make([]voteRow, len(vs))
+	for i, v := range vs {
+		answers := make([]string, 0, len(v.OptionIDs))
+		for _, id := range v.OptionIDs {
+			if id >= 0 && id < len(p.Answers) {
+				answers = append(answers, p.Answers[id])
+			}
+		}
+		name := v.Name
+		if v.Username != "" {
+			name = "@" + v.Username
+			if v.Name != "" {
+				name += " (" + v.Name + ")"
+			}
+		}
+		votes[i] = voteRow{Name: name, Answers: strings.Join(answers, ", ")}
+	}
+
+	queueUserIDs, err := s.votersRepo.GetQueueUserIDs(ctx, p.PollID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		s.internalError(w, r, err)
+		return
+	}
+	votersMap, err := s.votersRepo.GetVotersInfo(ctx, p.PollID, queueUserIDs)
+	if err != nil {
+		s.internalError(w, r, err)
+		return
+	}
+
+	data := pollPage{
+		Chat:  chatLink{ID: chatID, Title: session.Chats[chatID]},
+		Poll:  p,
+		Votes: votes,
+		Queue: queue.NewTemplateData(settings.Language, loc, p, queueUserIDs, votersMap),
+	}
+	s.render(w, http.StatusOK, "poll", settings.Language, loc, session, data)
+}
+
+// authorizeChat returns the chat ID from the path if the session grants access to it.
+func (s *Server) authorizeChat(w http.ResponseWriter, r *http.Request, session *auth.Claims) (int64, bool) {
+	chatID, err := strconv.ParseInt(r.PathValue("chatID"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return 0, false
+	}
+	if !session.HasChat(chatID) {
+		s.renderMessage(w, http.StatusForbidden, i18n.Default, i18n.DashboardForbidden)
+		return 0, false
+	}
+	return chatID, true
+}
+
+func (s *Server) internalError(w http.ResponseWriter, r *http.Request, err error) {
+	slog.ErrorContext(r.Context(), "web: request failed", slog.String("method", r.Method), slog.String("path", r.URL.Path), logging.Err(err))
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
+
+// statusKey maps a poll status to its catalog message.
+func statusKey(status string) string {
+	switch status {
+	case polls.StatusPublishing:
+		return string(i18n.StatusPublishing)
+	case polls.StatusProcessed:
+		return string(i18n.StatusProcessed)
+	case polls.StatusCancelled:
+		return string(i18n.StatusCancelled)
+	case polls.StatusDeleted:
+		return string(i18n.StatusDeleted)
+	default:
+		return string(i18n.StatusActive)
+	}
+}
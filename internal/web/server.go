@@ -0,0 +1,190 @@
+package web
+
+import (
+	"embed"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nikitkaralius/lineup/internal/auth"
+	"github.com/nikitkaralius/lineup/internal/chats"
+	"github.com/nikitkaralius/lineup/internal/export"
+	"github.com/nikitkaralius/lineup/internal/i18n"
+	"github.com/nikitkaralius/lineup/internal/logging"
+	"github.com/nikitkaralius/lineup/internal/polls"
+	"github.com/nikitkaralius/lineup/internal/stats"
+	"github.com/nikitkaralius/lineup/internal/utils"
+	"github.com/nikitkaralius/lineup/internal/voters"
+)
+
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+const (
+	sessionCookie = "lineup_session"
+	// SessionTTL is how long a dashboard session lasts after logging in with a link.
+	SessionTTL = 7 * 24 * time.Hour
+)
+
+// Server is the server-rendered dashboard with poll history and attendance statistics.
+// Users log in with a signed link the bot sends them in a private message.
+type Server struct {
+	pollsRepo  polls.Repository
+	votersRepo voters.Repository
+	chatsRepo  *chats.Repository
+	statsRepo  *stats.Repository
+	exportRepo *export.Repository
+	signer     *auth.Signer
+	pages      map[string]*template.Template
+}
+
+// NewServer creates the dashboard server. Tokens are verified with signer.
+func NewServer(pollsRepo polls.Repository, votersRepo voters.Repository, chatsRepo *chats.Repository, statsRepo *stats.Repository, exportRepo *export.Repository, signer *auth.Signer) *Server {
+	s := &Server{
+		pollsRepo:  pollsRepo,
+		votersRepo: votersRepo,
+		chatsRepo:  chatsRepo,
+		statsRepo:  statsRepo,
+		exportRepo: exportRepo,
+		signer:     signer,
+		pages:      make(map[string]*template.Template),
+	}
+	for _, name := range []string{"index", "chat", "poll", "message"} {
+		s.pages[name] = template.Must(template.New("layout.html").Funcs(placeholderFuncs).ParseFS(templatesFS, "templates/layout.html", "templates/"+name+".html"))
+	}
+	return s
+}
+
+// Register adds the dashboard routes to mux under /dashboard and the calendar feeds under /calendar.
+func (s *Server) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /calendar/{token}", s.calendar)
+	mux.HandleFunc("GET /dashboard/login", s.login)
+	mux.HandleFunc("POST /dashboard/logout", s.logout)
+	mux.HandleFunc("GET /dashboard/{$}", s.session(s.index))
+	mux.HandleFunc("GET /dashboard/chats/{chatID}", s.session(s.chat))
+	mux.HandleFunc("GET /dashboard/chats/{chatID}/polls/{pollID}", s.session(s.poll))
+}
+
+// login exchanges a login link token for a session cookie. Chats of an existing
+// session of the same user are kept, so one session can cover several chats.
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	claims, err := s.signer.Verify(r.URL.Query().Get("token"), auth.PurposeLogin)
+	if err != nil {
+		s.renderMessage(w, http.StatusUnauthorized, i18n.Default, i18n.DashboardExpired)
+		return
+	}
+
+	session := auth.Claims{Purpose: auth.PurposeSession, UserID: claims.UserID, Chats: claims.Chats}
+	if prev := s.currentSession(r); prev != nil && prev.UserID == claims.UserID {
+		for id, title := range prev.Chats {
+			if _, ok := session.Chats[id]; !ok {
+				session.Chats[id] = title
+			}
+		}
+	}
+	token, err := s.signer.Sign(session, SessionTTL)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "web: sign session failed", logging.Err(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/dashboard",
+		MaxAge:   int(SessionTTL / time.Second),
+		HttpOnly: true,
+		Secure:   isHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	target := "/dashboard/"
+	for id := range claims.Chats {
+		target = "/dashboard/chats/" + strconv.FormatInt(id, 10)
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/dashboard", MaxAge: -1, HttpOnly: true, Secure: isHTTPS(r), SameSite: http.SameSiteLaxMode})
+	http.Redirect(w, r, "/dashboard/", http.StatusSeeOther)
+}
+
+type sessionHandler func(w http.ResponseWriter, r *http.Request, session *auth.Claims)
+
+// session rejects requests without a valid session cookie.
+func (s *Server) session(next sessionHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session := s.currentSession(r)
+		if session == nil {
+			s.renderMessage(w, http.StatusUnauthorized, i18n.Default, i18n.DashboardExpired)
+			return
+		}
+		ctx := logging.With(r.Context(), logging.UserID(session.UserID))
+		if chatID, err := strconv.ParseInt(r.PathValue("chatID"), 10, 64); err == nil {
+			ctx = logging.With(ctx, logging.ChatID(chatID))
+		}
+		next(w, r.WithContext(ctx), session)
+	}
+}
+
+func (s *Server) currentSession(r *http.Request) *auth.Claims {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil
+	}
+	session, err := s.signer.Verify(c.Value, auth.PurposeSession)
+	if err != nil {
+		return nil
+	}
+	return session
+}
+
+// pageData is passed to every page template.
+type pageData struct {
+	Lang    i18n.Lang
+	Session *auth.Claims
+	Content any
+}
+
+// placeholderFuncs declares the template functions at parse time; render binds the
+// language- and timezone-dependent ones to the request.
+var placeholderFuncs = template.FuncMap{
+	"status":  statusKey,
+	"t":       func(string, ...any) string { return "" },
+	"time":    func(time.Time) string { return "" },
+	"percent": func(float64) string { return "" },
+	"decimal": func(float64) string { return "" },
+}
+
+// render executes page in the given language, formatting times in loc.
+func (s *Server) render(w http.ResponseWriter, status int, page string, lang i18n.Lang, loc *time.Location, session *auth.Claims, content any) {
+	tpl, err := s.pages[page].Clone()
+	if err != nil {
+		slog.Error("web: clone template failed", slog.String("page", page), logging.Err(err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	tpl.Funcs(template.FuncMap{
+		"t":       func(key string, args ...any) string { return i18n.T(lang, i18n.Key(key), args...) },
+		"time":    func(t time.Time) string { return utils.FormatTimeForPoll(t, loc) },
+		"percent": func(f float64) string { return strconv.FormatFloat(f*100, 'f', 0, 64) + "%" },
+		"decimal": func(f float64) string { return strconv.FormatFloat(f, 'f', 1, 64) },
+	})
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := tpl.Execute(w, pageData{Lang: lang, Session: session, Content: content}); err != nil {
+		slog.Error("web: render failed", slog.String("page", page), logging.Err(err))
+	}
+}
+
+func (s *Server) renderMessage(w http.ResponseWriter, status int, lang i18n.Lang, key i18n.Key) {
+	s.render(w, status, "message", lang, utils.LoadLocation(""), nil, i18n.T(lang, key))
+}
+
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
@@ -0,0 +1,62 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseURL(t *testing.T) {
+	for _, tt := range []struct {
+		url string
+		ok  bool
+	}{
+		{"https://lms.example.com/lineup", true},
+		{"https://93.184.216.34:8443/hook", true},
+		{"http://lms.example.com/lineup", false},
+		{"ftp://lms.example.com/lineup", false},
+		{"https:///lineup", false},
+		{"https://localhost/hook", false},
+		{"https://api.localhost./hook", false},
+		{"https://127.0.0.1:8080/hook", false},
+		{"https://10.0.0.5/hook", false},
+		{"https://172.16.3.4/hook", false},
+		{"https://192.168.1.1/hook", false},
+		{"https://169.254.169.254/latest/meta-data", false},
+		{"https://0.0.0.0/hook", false},
+		{"https://[::1]/hook", false},
+		{"https://[fd00::1]/hook", false},
+		{"https://[fe80::1]/hook", false},
+		{"https://[::ffff:127.0.0.1]/hook", false},
+	} {
+		_, err := ParseURL(tt.url)
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("ParseURL(%q) error = %v, want ok %v", tt.url, err, tt.ok)
+		}
+	}
+}
+
+func TestClientRefusesInternalAddresses(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached a loopback server")
+	}))
+	defer srv.Close()
+
+	// The check is on the dialled address, whatever the URL's host resolves from
+	client := NewClient(time.Second)
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("request to a loopback server succeeded")
+	}
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("error = %v, want ErrForbiddenAddress", err)
+	}
+}
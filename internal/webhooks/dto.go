@@ -0,0 +1,91 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// Event types delivered to webhooks.
+const (
+	EventPollCreated  = "poll.created"
+	EventVoteChanged  = "vote.changed"
+	EventPollFinished = "poll.finished"
+	EventQueueUpdated = "queue.updated"
+)
+
+// EventTypes lists all event types in the order they happen.
+var EventTypes = []string{EventPollCreated, EventVoteChanged, EventPollFinished, EventQueueUpdated}
+
+// WebhookDTO is an outgoing webhook configured for a chat.
+type WebhookDTO struct {
+	ID        int64
+	ChatID    int64
+	URL       string
+	Secret    string
+	Events    []string // subscribed event types, empty for all
+	CreatedAt time.Time
+}
+
+// Subscribed reports whether the webhook wants events of the given type.
+func (w *WebhookDTO) Subscribed(eventType string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, eventType)
+}
+
+// Event is the JSON body POSTed to webhooks.
+type Event struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	ChatID     int64           `json:"chat_id"`
+	PollID     string          `json:"poll_id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// PollData is the data of poll.created events.
+type PollData struct {
+	PollID            string     `json:"poll_id"`
+	Topic             string     `json:"topic"`
+	Status            string     `json:"status"`
+	Answers           []string   `json:"answers"`
+	ComingAnswerIndex int        `json:"coming_answer_index"`
+	StartedAt         time.Time  `json:"started_at"`
+	EndsAt            time.Time  `json:"ends_at"`
+	SessionStartAt    *time.Time `json:"session_start_at,omitempty"`
+	SlotSeconds       int        `json:"slot_seconds,omitempty"`
+	CreatorID         int64      `json:"creator_id"`
+	CreatorName       string     `json:"creator_name,omitempty"`
+}
+
+// VoteData is the data of vote.changed events. OptionIDs is empty when a vote is retracted.
+type VoteData struct {
+	UserID    int64  `json:"user_id"`
+	Username  string `json:"username,omitempty"`
+	Name      string `json:"name,omitempty"`
+	OptionIDs []int  `json:"option_ids"`
+}
+
+// Queue update reasons.
+const (
+	ReasonPublished = "published"
+	ReasonJoin      = "join"
+	ReasonLeave     = "leave"
+	ReasonReorder   = "reorder"
+)
+
+// QueueData is the data of poll.finished and queue.updated events.
+type QueueData struct {
+	Topic  string       `json:"topic"`
+	Reason string       `json:"reason"`
+	UserID int64        `json:"user_id,omitempty"` // who joined or left
+	Queue  []QueueEntry `json:"queue"`
+}
+
+// QueueEntry is a single queue position in QueueData.
+type QueueEntry struct {
+	Position  int        `json:"position"`
+	UserID    int64      `json:"user_id"`
+	Username  string     `json:"username,omitempty"`
+	Name      string     `json:"name,omitempty"`
+	SlotStart *time.Time `json:"slot_start,omitempty"`
+}
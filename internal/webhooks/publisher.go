@@ -0,0 +1,113 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/riverqueue/river"
+)
+
+// DeliverWebhookArgs defines the arguments for a job that POSTs an event to a single webhook.
+// This type is shared between service (for enqueue) and worker (for processing).
+type DeliverWebhookArgs struct {
+	WebhookID int64 `json:"webhook_id"`
+	Event     Event `json:"event"`
+}
+
+// Kind implements river.JobArgs to identify this job type.
+func (DeliverWebhookArgs) Kind() string { return "deliver_webhook" }
+
+// MaxAttempts is how many times a delivery is tried before it is given up.
+const MaxAttempts = 12
+
+// Publisher enqueues a delivery job for every webhook of the chat subscribed to an event.
+type Publisher interface {
+	Publish(ctx context.Context, chatID int64, eventType, pollID string, data any) error
+}
+
+type publisher[TTx any] struct {
+	repo   *Repository
+	client func(ctx context.Context) (*river.Client[TTx], error)
+}
+
+// NewPublisher creates a publisher that enqueues jobs with client.
+func NewPublisher[TTx any](repo *Repository, client *river.Client[TTx]) Publisher {
+	return &publisher[TTx]{repo: repo, client: func(context.Context) (*river.Client[TTx], error) { return client, nil }}
+}
+
+// NewJobPublisher creates a publisher for use inside River workers: it enqueues jobs
+// with the client that runs the current job.
+func NewJobPublisher[TTx any](repo *Repository) Publisher {
+	return &publisher[TTx]{repo: repo, client: river.ClientFromContextSafely[TTx]}
+}
+
+func (p *publisher[TTx]) Publish(ctx context.Context, chatID int64, eventType, pollID string, data any) error {
+	hooks, err := p.repo.ListWebhooks(ctx, chatID)
+	if err != nil {
+		return fmt.Errorf("failed to list webhooks: %w", err)
+	}
+
+	var params []river.InsertManyParams
+	event := Event{Type: eventType, ChatID: chatID, PollID: pollID, OccurredAt: time.Now().UTC()}
+	for _, h := range hooks {
+		if !h.Subscribed(eventType) {
+			continue
+		}
+		if event.ID == "" {
+			if event.ID, err = newEventID(); err != nil {
+				return err
+			}
+			if event.Data, err = json.Marshal(data); err != nil {
+				return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+			}
+		}
+		params = append(params, river.InsertManyParams{
+			Args:       DeliverWebhookArgs{WebhookID: h.ID, Event: event},
+			InsertOpts: &river.InsertOpts{MaxAttempts: MaxAttempts},
+		})
+	}
+	if len(params) == 0 {
+		return nil
+	}
+
+	client, err := p.client(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = client.InsertMany(ctx, params)
+	return err
+}
+
+func newEventID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewSecret generates a random webhook signing secret.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns the X-Lineup-Signature header value for a request body sent at timestamp:
+// "sha256=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook secret.
+func Sign(secret string, timestamp int64, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	h.Write([]byte("."))
+	h.Write(body)
+	return "sha256=" + hex.EncodeToString(h.Sum(nil))
+}
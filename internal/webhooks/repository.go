@@ -0,0 +1,69 @@
+package webhooks
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Repository struct {
+	DB *pgxpool.Pool
+}
+
+func NewRepository(db *pgxpool.Pool) *Repository {
+	return &Repository{DB: db}
+}
+
+const webhookColumns = `id, chat_id, url, secret, events, created_at`
+
+// AddWebhook stores w, replacing the secret and events of an existing webhook with the same URL.
+// ID and CreatedAt are filled in.
+func (s *Repository) AddWebhook(ctx context.Context, w *WebhookDTO) error {
+	if w.Events == nil {
+		w.Events = []string{}
+	}
+	return s.DB.QueryRow(ctx, `INSERT INTO chat_webhooks (chat_id, url, secret, events, created_at)
+	VALUES ($1,$2,$3,$4,NOW())
+	ON CONFLICT (chat_id, url) DO UPDATE SET secret=EXCLUDED.secret, events=EXCLUDED.events
+	RETURNING id, created_at`,
+		w.ChatID, w.URL, w.Secret, w.Events,
+	).Scan(&w.ID, &w.CreatedAt)
+}
+
+// GetWebhook returns a webhook by ID.
+func (s *Repository) GetWebhook(ctx context.Context, id int64) (*WebhookDTO, error) {
+	var w WebhookDTO
+	err := s.DB.QueryRow(ctx, `SELECT `+webhookColumns+` FROM chat_webhooks WHERE id=$1`, id).
+		Scan(&w.ID, &w.ChatID, &w.URL, &w.Secret, &w.Events, &w.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// ListWebhooks returns the chat's webhooks, oldest first.
+func (s *Repository) ListWebhooks(ctx context.Context, chatID int64) ([]WebhookDTO, error) {
+	rows, err := s.DB.Query(ctx, `SELECT `+webhookColumns+` FROM chat_webhooks WHERE chat_id=$1 ORDER BY id`, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := []WebhookDTO{}
+	for rows.Next() {
+		var w WebhookDTO
+		if err := rows.Scan(&w.ID, &w.ChatID, &w.URL, &w.Secret, &w.Events, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, w)
+	}
+	return res, rows.Err()
+}
+
+// DeleteWebhook removes a webhook of the chat. It reports whether the webhook existed.
+func (s *Repository) DeleteWebhook(ctx context.Context, chatID, id int64) (bool, error) {
+	tag, err := s.DB.Exec(ctx, `DELETE FROM chat_webhooks WHERE chat_id=$1 AND id=$2`, chatID, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
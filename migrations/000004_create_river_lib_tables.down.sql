@@ -1,137 +1,2 @@
--- River main migration 006 [down]
---
--- Drop `river_job.unique_states` and its index.
---
-
-DROP INDEX river_job_unique_idx;
-
-ALTER TABLE river_job
-    DROP COLUMN unique_states;
-
-CREATE UNIQUE INDEX IF NOT EXISTS river_job_kind_unique_key_idx ON river_job (kind, unique_key) WHERE unique_key IS NOT NULL;
-
---
--- Drop `river_job_state_in_bitmask` function.
---
-DROP FUNCTION river_job_state_in_bitmask;
-
--- River main migration 005 [down]
---
--- Revert to migration table based only on `(version)`.
---
--- If any non-main migrations are present, 005 is considered irreversible.
---
-
-DO
-$body$
-    BEGIN
-        -- Tolerate users who may be using their own migration system rather than
-        -- River's. If they are, they will have skipped version 001 containing
-        -- `CREATE TABLE river_migration`, so this table won't exist.
-        IF (SELECT to_regclass('river_migration') IS NOT NULL) THEN
-            IF EXISTS (SELECT *
-                       FROM river_migration
-                       WHERE line <> 'main') THEN
-                RAISE EXCEPTION 'Found non-main migration lines in the database; version 005 migration is irreversible because it would result in loss of migration information.';
-            END IF;
-
-            ALTER TABLE river_migration
-                RENAME TO river_migration_old;
-
-            CREATE TABLE river_migration
-            (
-                id         bigserial PRIMARY KEY,
-                created_at timestamptz NOT NULL DEFAULT NOW(),
-                version    bigint      NOT NULL,
-                CONSTRAINT version CHECK (version >= 1)
-            );
-
-            CREATE UNIQUE INDEX ON river_migration USING btree (version);
-
-            INSERT INTO river_migration
-                (created_at, version)
-            SELECT created_at, version
-            FROM river_migration_old;
-
-            DROP TABLE river_migration_old;
-        END IF;
-    END;
-$body$
-LANGUAGE 'plpgsql';
-
---
--- Drop `river_job.unique_key`.
---
-
-ALTER TABLE river_job
-    DROP COLUMN unique_key;
-
---
--- Drop `river_client` and derivative.
---
-
-DROP TABLE river_client_queue;
-DROP TABLE river_client;
-
--- River main migration 004 [down]
-ALTER TABLE river_job
-    ALTER COLUMN args DROP NOT NULL;
-
-ALTER TABLE river_job
-    ALTER COLUMN metadata DROP NOT NULL;
-ALTER TABLE river_job
-    ALTER COLUMN metadata DROP DEFAULT;
-
--- It is not possible to safely remove 'pending' from the river_job_state enum,
--- so leave it in place.
-
-ALTER TABLE river_job
-    DROP CONSTRAINT finalized_or_finalized_at_null;
-ALTER TABLE river_job
-    ADD CONSTRAINT finalized_or_finalized_at_null CHECK (
-        (state IN ('cancelled', 'completed', 'discarded') AND finalized_at IS NOT NULL) OR finalized_at IS NULL
-        );
-
-CREATE OR REPLACE FUNCTION river_job_notify()
-    RETURNS TRIGGER
-AS
-$$
-DECLARE
-    payload json;
-BEGIN
-    IF NEW.state = 'available' THEN
-        -- Notify will coalesce duplicate notifications within a transaction, so
-        -- keep these payloads generalized:
-        payload = json_build_object('queue', NEW.queue);
-        PERFORM
-            pg_notify('river_insert', payload::text);
-    END IF;
-    RETURN NULL;
-END;
-$$
-    LANGUAGE plpgsql;
-
-CREATE TRIGGER river_notify
-    AFTER INSERT
-    ON river_job
-    FOR EACH ROW
-EXECUTE PROCEDURE river_job_notify();
-
-DROP TABLE river_queue;
-
-ALTER TABLE river_leader
-    ALTER COLUMN name DROP DEFAULT,
-    DROP CONSTRAINT name_length,
-    ADD CONSTRAINT name_length CHECK (char_length(name) > 0 AND char_length(name) < 128);
-
--- River main migration 003 [down]
-ALTER TABLE river_job
-    ALTER COLUMN tags DROP NOT NULL,
-    ALTER COLUMN tags DROP DEFAULT;
-
--- River main migration 002 [down]
-DROP TABLE river_job;
-DROP FUNCTION river_job_notify;
-DROP TYPE river_job_state;
-
-DROP TABLE river_leader;
+-- River's schema is managed by rivermigrate, see internal/migrate.
+-- Databases migrated before that got River's tables here and are baselined at River version 6.
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS poll_drafts
+(
+    chat_id             BIGINT      NOT NULL,
+    user_id             BIGINT      NOT NULL,
+    intent              JSONB       NOT NULL,
+    missing             TEXT        NOT NULL,
+    question_message_id INT         NOT NULL,
+    expires_at          TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (chat_id, user_id)
+);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS chat_settings
+(
+    chat_id    BIGINT PRIMARY KEY,
+    language   TEXT        NOT NULL DEFAULT 'ru',
+    updated_at TIMESTAMPTZ NOT NULL
+);
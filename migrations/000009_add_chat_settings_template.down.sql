@@ -0,0 +1,4 @@
+ALTER TABLE chat_settings
+DROP COLUMN IF EXISTS template_name,
+DROP COLUMN IF EXISTS template_body,
+DROP COLUMN IF EXISTS template_parse_mode;
@@ -0,0 +1,4 @@
+ALTER TABLE chat_settings
+ADD COLUMN IF NOT EXISTS template_name TEXT NOT NULL DEFAULT 'default',
+ADD COLUMN IF NOT EXISTS template_body TEXT,
+ADD COLUMN IF NOT EXISTS template_parse_mode TEXT NOT NULL DEFAULT '';
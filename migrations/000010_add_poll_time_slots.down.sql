@@ -0,0 +1,6 @@
+ALTER TABLE polls
+DROP COLUMN IF EXISTS session_start_at,
+DROP COLUMN IF EXISTS slot_seconds;
+
+ALTER TABLE chat_settings
+DROP COLUMN IF EXISTS timezone;
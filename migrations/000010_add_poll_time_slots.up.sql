@@ -0,0 +1,6 @@
+ALTER TABLE polls
+ADD COLUMN IF NOT EXISTS session_start_at TIMESTAMPTZ,
+ADD COLUMN IF NOT EXISTS slot_seconds INT;
+
+ALTER TABLE chat_settings
+ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'Europe/Moscow';
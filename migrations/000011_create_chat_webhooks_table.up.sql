@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS chat_webhooks
+(
+    id         SERIAL PRIMARY KEY,
+    chat_id    BIGINT      NOT NULL,
+    url        TEXT        NOT NULL,
+    secret     TEXT        NOT NULL,
+    events     TEXT[]      NOT NULL DEFAULT ARRAY[]::TEXT[],
+    created_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (chat_id, url)
+);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS processed_updates
+(
+    update_id    BIGINT PRIMARY KEY,
+    processed_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS processed_updates_processed_at_idx ON processed_updates (processed_at);
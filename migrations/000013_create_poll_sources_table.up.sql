@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS poll_sources
+(
+    chat_id    BIGINT      NOT NULL,
+    message_id INT         NOT NULL,
+    poll_id    TEXT,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (chat_id, message_id)
+);
@@ -0,0 +1,11 @@
+UPDATE chat_settings SET language = 'ru' WHERE language IS NULL;
+UPDATE chat_settings SET timezone = 'Europe/Moscow' WHERE timezone IS NULL;
+UPDATE chat_settings SET template_name = 'default' WHERE template_name IS NULL;
+
+ALTER TABLE chat_settings
+ALTER COLUMN language SET DEFAULT 'ru',
+ALTER COLUMN language SET NOT NULL,
+ALTER COLUMN timezone SET DEFAULT 'Europe/Moscow',
+ALTER COLUMN timezone SET NOT NULL,
+ALTER COLUMN template_name SET DEFAULT 'default',
+ALTER COLUMN template_name SET NOT NULL;
@@ -0,0 +1,8 @@
+-- Unset settings are NULL, so that the configured chat defaults apply to them
+ALTER TABLE chat_settings
+ALTER COLUMN language DROP NOT NULL,
+ALTER COLUMN language DROP DEFAULT,
+ALTER COLUMN timezone DROP NOT NULL,
+ALTER COLUMN timezone DROP DEFAULT,
+ALTER COLUMN template_name DROP NOT NULL,
+ALTER COLUMN template_name DROP DEFAULT;
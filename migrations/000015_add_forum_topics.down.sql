@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS topic_settings;
+
+ALTER TABLE polls DROP COLUMN IF EXISTS message_thread_id;
@@ -0,0 +1,15 @@
+-- Forum topic the poll was requested in, 0 outside forum topics
+ALTER TABLE polls ADD COLUMN IF NOT EXISTS message_thread_id INT NOT NULL DEFAULT 0;
+
+-- Settings of a forum topic, NULL columns fall back to chat_settings
+CREATE TABLE IF NOT EXISTS topic_settings (
+    chat_id BIGINT NOT NULL,
+    thread_id INT NOT NULL,
+    language TEXT,
+    timezone TEXT,
+    template_name TEXT,
+    template_body TEXT,
+    template_parse_mode TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (chat_id, thread_id)
+);
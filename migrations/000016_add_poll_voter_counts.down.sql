@@ -0,0 +1,3 @@
+UPDATE polls SET status = 'cancelled' WHERE status = 'deleted';
+
+ALTER TABLE polls DROP COLUMN IF EXISTS voter_counts;
@@ -0,0 +1,2 @@
+-- Voters per answer as last reported by Telegram in a poll update, NULL until the first one
+ALTER TABLE polls ADD COLUMN IF NOT EXISTS voter_counts INT[];
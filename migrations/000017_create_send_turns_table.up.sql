@@ -0,0 +1,7 @@
+-- When the next Bot API request may be sent, shared by all processes of the bot:
+-- chat_id 0 holds the turn of all requests, other rows the turns of single chats
+CREATE TABLE IF NOT EXISTS send_turns
+(
+    chat_id BIGINT PRIMARY KEY,
+    next_at TIMESTAMPTZ NOT NULL
+);
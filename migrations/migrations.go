@@ -0,0 +1,9 @@
+// Package migrations embeds the SQL migrations of the database schema, applied by
+// internal/migrate. Files are named NNNNNN_description.up.sql and .down.sql, as
+// golang-migrate expects, so existing databases keep their recorded version.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS